@@ -0,0 +1,9 @@
+// Package assets 内嵌昵称生成使用的词库文件
+package assets
+
+import "embed"
+
+// Dataset 内嵌的词库，路径形如 dataset/nouns.txt
+//
+//go:embed dataset/*.txt
+var Dataset embed.FS
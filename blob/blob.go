@@ -0,0 +1,102 @@
+// Package blob 保存附件的文件内容。文件按内容的SHA-256寻址，相同的内容只保存一份，
+// 附件记录只保存寻址用的键。不再被任何附件引用的文件由 Sweep 定期清理。
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 表示文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// errInvalidKey 键不是内容的SHA-256，防止通过键访问存储目录之外的文件
+var errInvalidKey = errors.New("无效的文件键")
+
+// Store 附件文件的存储接口
+type Store interface {
+	// Put 保存文件内容，键为 Key(data)。文件已存在时不重复写入，但会刷新它的修改时间
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Open 读取文件内容，文件不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// List 依次对每个文件调用 fn，fn 返回错误时停止
+	List(ctx context.Context, fn func(key string, modTime time.Time) error) error
+}
+
+// Key 返回文件内容的键
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func checkKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return errInvalidKey
+	}
+	return nil
+}
+
+// DefaultDir 未配置附件存储时使用的本地目录
+var DefaultDir = filepath.Join("./data", "attachments")
+
+// Open 根据DSN选择存储实现：s3:// 开头使用S3兼容的对象存储（格式见 NewS3Store），
+// 其余视为本地目录，为空时使用 DefaultDir
+func Open(dsn string) (Store, error) {
+	if strings.HasPrefix(dsn, "s3://") {
+		return NewS3Store(dsn)
+	}
+	if dsn == "" {
+		dsn = DefaultDir
+	}
+	return NewDiskStore(dsn)
+}
+
+// SweepGrace 文件上传后至少保留的时间。上传的文件先写入存储再保存附件记录，
+// 清理时跳过较新的文件，以免删除附件记录尚未保存的文件
+const SweepGrace = time.Hour
+
+// Sweep 删除在 now 之前 SweepGrace 以上写入、且不在 inUse 中的文件，返回删除的数量。
+// inUse 在列出文件之后才调用，列出之后才被引用的文件也会被看到
+func Sweep(ctx context.Context, s Store, inUse func() (map[string]bool, error), now time.Time) (int, error) {
+	cutoff := now.Add(-SweepGrace)
+	var candidates []string
+	err := s.List(ctx, func(key string, modTime time.Time) error {
+		if modTime.Before(cutoff) {
+			candidates = append(candidates, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	keys, err := inUse()
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, key := range candidates {
+		if keys[key] {
+			continue
+		}
+		if err := s.Delete(ctx, key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
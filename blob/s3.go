@@ -0,0 +1,133 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store 把文件保存在S3兼容的对象存储（AWS S3、MinIO等）中
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	// prefix 对象名的前缀，为空或以 / 结尾
+	prefix string
+}
+
+// NewS3Store 根据DSN连接对象存储，格式为
+// s3://ACCESS_KEY:SECRET_KEY@host[:port]/bucket[/prefix][?region=...&insecure=true]，
+// insecure=true 时使用HTTP连接。存储桶不存在时自动创建
+func NewS3Store(dsn string) (*S3Store, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || bucket == "" {
+		return nil, errors.New("S3 DSN 缺少主机或存储桶")
+	}
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var creds *credentials.Credentials
+	if u.User != nil {
+		secret, _ := u.User.Password()
+		creds = credentials.NewStaticV4(u.User.Username(), secret, "")
+	} else {
+		// 没有在DSN中指定密钥时从环境变量读取
+		creds = credentials.NewEnvAWS()
+	}
+	query := u.Query()
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  creds,
+		Secure: query.Get("insecure") != "true",
+		Region: query.Get("region"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: query.Get("region")}); err != nil {
+			return nil, err
+		}
+	}
+	return &S3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *S3Store) object(key string) string {
+	return s.prefix + key
+}
+
+// Put 上传文件。对象存储不能只修改对象的修改时间，文件已存在时重新上传相同的内容
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, s.object(key), bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Open 读取对象
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, ErrNotFound
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, s.object(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject 在第一次读取时才发出请求，先获取对象信息以便区分对象不存在
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Delete 删除对象，对象不存在时对象存储也返回成功
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, s.object(key), minio.RemoveObjectOptions{})
+}
+
+// List 列出前缀下的对象，忽略不是附件文件的对象
+func (s *S3Store) List(ctx context.Context, fn func(key string, modTime time.Time) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	// 提前返回时停止列出
+	defer cancel()
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		key := path.Base(obj.Key)
+		if obj.Key != s.object(key) || checkKey(key) != nil {
+			continue
+		}
+		if err := fn(key, obj.LastModified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Mammoth777/nilbbs/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// runConfig 处理 config 子命令：print 输出生效的配置及每一项的来源
+func runConfig(args []string, cfg utils.AppConfig, sources utils.ConfigSources) int {
+	action := "print"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if action != "print" {
+		fmt.Printf("用法: nilbbs config [print]\n")
+		return 2
+	}
+
+	if err := utils.PrintConfig(os.Stdout, cfg, sources); err != nil {
+		return 1
+	}
+	return 0
+}
+
+// runHashPassword 处理 hash-password 子命令：从标准输入读取一行密码，输出用于 admin_password_hash 的 bcrypt 哈希
+func runHashPassword(in io.Reader, out io.Writer) int {
+	fmt.Fprint(os.Stderr, "输入管理密码: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "读取密码失败: %v\n", err)
+		return 1
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		fmt.Fprintln(os.Stderr, "密码不能为空")
+		return 2
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "生成哈希失败: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(out, string(hash))
+	return 0
+}
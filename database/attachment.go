@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+)
+
+// 附件查询使用的列，顺序与 scanAttachment 一致
+const attachmentColumns = "a.id, a.post_id, a.comment_id, a.blob_key, a.thumbnail_key, a.filename, a.content_type, a.size, a.width, a.height, a.created_at"
+
+// 扫描一行 attachmentColumns 到附件
+func (s *SQLStore) scanAttachment(row rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	var commentID sql.NullInt64
+	var thumbnailKey sql.NullString
+	var createdAt interface{}
+	err := row.Scan(&a.ID, &a.PostID, &commentID, &a.BlobKey, &thumbnailKey, &a.Filename, &a.ContentType,
+		&a.Size, &a.Width, &a.Height, &createdAt)
+	if err != nil {
+		return a, err
+	}
+	a.CommentID = commentID.Int64
+	a.ThumbnailKey = thumbnailKey.String
+	a.CreatedAt, err = s.d.parseTime(createdAt)
+	return a, err
+}
+
+// CreateAttachment 校验帖子或评论的编辑令牌和附件数量后保存附件
+func (s *SQLStore) CreateAttachment(a *models.Attachment, tokenHash string, limit int, now time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var stored string
+	var count int
+	if a.CommentID == 0 {
+		stored, _, err = s.postTokenHash(tx, a.PostID, now)
+		if err == nil {
+			err = tx.QueryRow(s.d.rebind("SELECT COUNT(*) FROM attachments WHERE post_id = ? AND comment_id IS NULL"),
+				a.PostID).Scan(&count)
+		}
+	} else {
+		stored, _, err = s.commentTokenHash(tx, a.PostID, a.CommentID, now)
+		if err == nil {
+			err = tx.QueryRow(s.d.rebind("SELECT COUNT(*) FROM attachments WHERE comment_id = ?"),
+				a.CommentID).Scan(&count)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !tokenMatches(stored, tokenHash) {
+		return 0, ErrForbidden
+	}
+	if count >= limit {
+		return 0, ErrAttachmentLimit
+	}
+
+	var id int64
+	err = tx.QueryRow(s.d.rebind(`
+		INSERT INTO attachments (post_id, comment_id, blob_key, thumbnail_key, filename, content_type, size, width, height, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		a.PostID, nullID(a.CommentID), a.BlobKey, nullString(a.ThumbnailKey), a.Filename, a.ContentType,
+		a.Size, a.Width, a.Height, s.d.timeValue(a.CreatedAt)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// ListAttachments 获取帖子及其评论的全部附件，按附件ID正序
+func (s *SQLStore) ListAttachments(postID int64) ([]models.Attachment, error) {
+	rows, err := s.query("SELECT "+attachmentColumns+" FROM attachments a WHERE a.post_id = ? ORDER BY a.id ASC", postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		a, err := s.scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachment 获取未过期帖子下的附件，所属的帖子或评论被隐藏或未通过审核时视为不存在
+func (s *SQLStore) GetAttachment(id int64, now time.Time) (*models.Attachment, error) {
+	a, err := s.scanAttachment(s.queryRow(`
+		SELECT `+attachmentColumns+` FROM attachments a
+		JOIN posts p ON p.id = a.post_id
+		LEFT JOIN comments c ON c.id = a.comment_id
+		WHERE a.id = ? AND p.delete_at > ? AND p.hidden_at IS NULL AND p.status = 'approved'
+			AND c.hidden_at IS NULL AND COALESCE(c.status, 'approved') = 'approved'
+	`, id, s.d.timeValue(now)))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// BlobKeys 查询所有附件引用的文件和缩略图的键
+func (s *SQLStore) BlobKeys() (map[string]bool, error) {
+	rows, err := s.query("SELECT blob_key, thumbnail_key FROM attachments")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		var thumbnailKey sql.NullString
+		if err := rows.Scan(&key, &thumbnailKey); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+		if thumbnailKey.Valid {
+			keys[thumbnailKey.String] = true
+		}
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,278 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+)
+
+// postTokenHash 在事务中查询未过期帖子的编辑令牌哈希和当前内容
+func (s *SQLStore) postTokenHash(tx *sql.Tx, id int64, now time.Time) (string, string, error) {
+	var hash sql.NullString
+	var content string
+	err := tx.QueryRow(s.d.rebind(`
+		SELECT edit_token_hash, content FROM posts
+		WHERE id = ? AND delete_at > ?
+	`), id, s.d.timeValue(now)).Scan(&hash, &content)
+	if err == sql.ErrNoRows {
+		return "", "", ErrNotFound
+	}
+	return hash.String, content, err
+}
+
+// commentTokenHash 在事务中查询未过期帖子下评论的编辑令牌哈希和当前内容
+func (s *SQLStore) commentTokenHash(tx *sql.Tx, postID, commentID int64, now time.Time) (string, string, error) {
+	var hash sql.NullString
+	var content string
+	err := tx.QueryRow(s.d.rebind(`
+		SELECT c.edit_token_hash, c.content FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.id = ? AND c.post_id = ? AND p.delete_at > ?
+	`), commentID, postID, s.d.timeValue(now)).Scan(&hash, &content)
+	if err == sql.ErrNoRows {
+		return "", "", ErrNotFound
+	}
+	return hash.String, content, err
+}
+
+// addRevision 在事务中保存修改前的内容，commentID 为 0 表示帖子本身
+func (s *SQLStore) addRevision(tx *sql.Tx, postID, commentID int64, content string, now time.Time) error {
+	_, err := tx.Exec(s.d.rebind(
+		"INSERT INTO revisions (post_id, comment_id, content, replaced_at) VALUES (?, ?, ?, ?)"),
+		postID, nullID(commentID), content, s.d.timeValue(now))
+	return err
+}
+
+// UpdatePost 校验编辑令牌后修改帖子内容并重建引用，修改前的内容保存到修改历史，
+// 再在同一事务中执行 review 中的管理操作
+func (s *SQLStore) UpdatePost(id int64, content, tokenHash string, review []models.AuditEntry, now time.Time) error {
+	if err := checkReview(review); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stored, old, err := s.postTokenHash(tx, id, now)
+	if err != nil {
+		return err
+	}
+	if !tokenMatches(stored, tokenHash) {
+		return ErrForbidden
+	}
+	// 内容没有变化时不记录修改
+	if old != content {
+		if err := s.addRevision(tx, id, 0, old, now); err != nil {
+			return err
+		}
+		_, err = tx.Exec(s.d.rebind("UPDATE posts SET content = ?, edited_at = ? WHERE id = ?"),
+			content, s.d.timeValue(now), id)
+		if err != nil {
+			return err
+		}
+		if err := s.saveQuotes(tx, id, 0, content); err != nil {
+			return err
+		}
+	}
+	if err := s.applyReview(tx, review, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeletePost 校验编辑令牌后删除帖子及其评论
+func (s *SQLStore) DeletePost(id int64, tokenHash string, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stored, _, err := s.postTokenHash(tx, id, now)
+	if err != nil {
+		return err
+	}
+	if !tokenMatches(stored, tokenHash) {
+		return ErrForbidden
+	}
+
+	if _, err := s.deletePosts(tx, []int64{id}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateComment 校验编辑令牌后修改评论内容并重建引用，修改前的内容保存到修改历史，
+// 再在同一事务中执行 review 中的管理操作
+func (s *SQLStore) UpdateComment(postID, commentID int64, content, tokenHash string, review []models.AuditEntry, now time.Time) error {
+	if err := checkReview(review); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stored, old, err := s.commentTokenHash(tx, postID, commentID, now)
+	if err != nil {
+		return err
+	}
+	if !tokenMatches(stored, tokenHash) {
+		return ErrForbidden
+	}
+	// 内容没有变化时不记录修改
+	if old != content {
+		if err := s.addRevision(tx, postID, commentID, old, now); err != nil {
+			return err
+		}
+		_, err = tx.Exec(s.d.rebind("UPDATE comments SET content = ?, edited_at = ? WHERE id = ?"),
+			content, s.d.timeValue(now), commentID)
+		if err != nil {
+			return err
+		}
+		if err := s.saveQuotes(tx, postID, commentID, content); err != nil {
+			return err
+		}
+	}
+	if err := s.applyReview(tx, review, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteComment 校验编辑令牌后删除评论，同时减少帖子的评论数。
+// 有回复的评论只清空内容保留为占位，以免回复失去上下文
+func (s *SQLStore) DeleteComment(postID, commentID int64, tokenHash string, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stored, _, err := s.commentTokenHash(tx, postID, commentID, now)
+	if err != nil {
+		return err
+	}
+	if !tokenMatches(stored, tokenHash) {
+		return ErrForbidden
+	}
+	if err := s.deleteComment(tx, postID, commentID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteComment 在事务中删除评论及其修改历史、附件和引用，并重新统计帖子的评论数；有回复的评论保留为占位
+func (s *SQLStore) deleteComment(tx *sql.Tx, postID, commentID int64, now time.Time) error {
+	// 删除评论时一并删除它的修改历史、附件、收到的举报和它对其他评论的引用
+	if _, err := tx.Exec(s.d.rebind("DELETE FROM revisions WHERE comment_id = ?"), commentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(s.d.rebind("DELETE FROM attachments WHERE comment_id = ?"), commentID); err != nil {
+		return err
+	}
+	if err := s.deleteReports(tx, postID, commentID); err != nil {
+		return err
+	}
+	if err := s.saveQuotes(tx, postID, commentID, ""); err != nil {
+		return err
+	}
+	replies, err := s.countReplies(tx, commentID)
+	if err != nil {
+		return err
+	}
+	if replies > 0 {
+		_, err = tx.Exec(s.d.rebind("UPDATE comments SET content = '', edit_token_hash = NULL, deleted_at = ? WHERE id = ?"),
+			s.d.timeValue(now), commentID)
+	} else {
+		err = s.removeComment(tx, commentID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.refreshCommentStats(tx, postID)
+}
+
+// countReplies 查询评论的直接回复数量
+func (s *SQLStore) countReplies(tx *sql.Tx, commentID int64) (int, error) {
+	var n int
+	err := tx.QueryRow(s.d.rebind("SELECT COUNT(*) FROM comments WHERE parent_comment_id = ?"), commentID).Scan(&n)
+	return n, err
+}
+
+// removeComment 删除没有回复的评论，随后依次删除因此不再有回复的已删除占位
+func (s *SQLStore) removeComment(tx *sql.Tx, commentID int64) error {
+	for {
+		var parentID sql.NullInt64
+		err := tx.QueryRow(s.d.rebind("SELECT parent_comment_id FROM comments WHERE id = ?"), commentID).Scan(&parentID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(s.d.rebind("DELETE FROM comments WHERE id = ?"), commentID); err != nil {
+			return err
+		}
+		if !parentID.Valid {
+			return nil
+		}
+
+		var deleted bool
+		err = tx.QueryRow(s.d.rebind("SELECT deleted_at IS NOT NULL FROM comments WHERE id = ?"), parentID.Int64).Scan(&deleted)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		replies, err := s.countReplies(tx, parentID.Int64)
+		if err != nil || !deleted || replies > 0 {
+			return err
+		}
+		commentID = parentID.Int64
+	}
+}
+
+// ListRevisions 查询未过期帖子及其评论的修改历史，按修改时间正序
+func (s *SQLStore) ListRevisions(postID int64, now time.Time) ([]models.Revision, error) {
+	var exists int
+	err := s.queryRow("SELECT 1 FROM posts WHERE id = ? AND delete_at > ? AND hidden_at IS NULL AND status = 'approved'",
+		postID, s.d.timeValue(now)).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 隐藏和未通过审核的评论不能通过修改历史看到内容
+	rows, err := s.query(`
+		SELECT r.id, r.comment_id, r.content, r.replaced_at
+		FROM revisions r
+		LEFT JOIN comments c ON c.id = r.comment_id
+		WHERE r.post_id = ? AND c.hidden_at IS NULL AND COALESCE(c.status, 'approved') = 'approved'
+		ORDER BY r.replaced_at ASC, r.id ASC
+	`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.Revision
+	for rows.Next() {
+		rev := models.Revision{PostID: postID}
+		var commentID sql.NullInt64
+		var replacedAt interface{}
+		if err := rows.Scan(&rev.ID, &commentID, &rev.Content, &replacedAt); err != nil {
+			return nil, err
+		}
+		rev.CommentID = commentID.Int64
+		if rev.ReplacedAt, err = s.d.parseTime(replacedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
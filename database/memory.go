@@ -0,0 +1,1073 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// MemoryStore 基于内存的存储实现，主要用于测试
+type MemoryStore struct {
+	mu             sync.RWMutex
+	posts          map[int64]models.Post
+	comments       map[int64][]models.Comment
+	revisions      map[int64][]models.Revision
+	nextPostID     int64
+	nextCommentID  int64
+	nextRevisionID int64
+	// attachments 帖子及其评论的附件，键为帖子ID
+	attachments      map[int64][]models.Attachment
+	nextAttachmentID int64
+	// pinnedAt 置顶帖子的置顶时间
+	pinnedAt    map[int64]time.Time
+	auditLog    []models.AuditEntry
+	nextAuditID int64
+	// reports 帖子及其评论收到的举报，键为帖子ID
+	reports      map[int64][]models.Report
+	nextReportID int64
+}
+
+// NewMemoryStore 创建空的内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		posts:     make(map[int64]models.Post),
+		comments:  make(map[int64][]models.Comment),
+		revisions: make(map[int64][]models.Revision),
+
+		attachments: make(map[int64][]models.Attachment),
+		pinnedAt:    make(map[int64]time.Time),
+		reports:     make(map[int64][]models.Report),
+	}
+}
+
+// Close 内存存储无需关闭
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// CreatePost 保存新帖子，posterID 不为空时用帖子ID计算发帖人ID，并写入 audit 中的审计日志
+func (s *MemoryStore) CreatePost(post *models.Post, posterID PosterIDFunc, audit []models.AuditEntry) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := *post
+	p.ID = s.nextPostID + 1
+	if posterID != nil {
+		var err error
+		if p.PosterID, err = posterID(p.ID); err != nil {
+			return 0, err
+		}
+	}
+	s.nextPostID = p.ID
+	p.Format = contentFormat(p.Format)
+	p.Comments = nil
+	p.LastActivityAt = p.CreatedAt
+	p.CommentCount = 0
+	p.Locked, p.Pinned = false, false
+	p.Status = reviewStatus(p.Status)
+	s.posts[p.ID] = p
+	for i := range audit {
+		audit[i].PostID = p.ID
+		s.addAudit(&audit[i], p.CreatedAt)
+	}
+	return p.ID, nil
+}
+
+// ListPosts 获取未过期的帖子，按指定方式排序，隐藏、未通过审核和置顶的帖子不在其中
+func (s *MemoryStore) ListPosts(now time.Time, order PostSort, page Page) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var posts []models.Post
+	for _, p := range s.posts {
+		if p.DeleteAt.After(now) && postVisible(p) && !p.Pinned {
+			posts = append(posts, p)
+		}
+	}
+	_, desc := order.column()
+	// before 判断在该排序方式下 a 是否排在 (t, id) 之前
+	before := func(a *models.Post, t time.Time, id int64) bool {
+		key := order.sortKey(a)
+		if key.Equal(t) {
+			if desc {
+				return a.ID > id
+			}
+			return a.ID < id
+		}
+		if desc {
+			return key.After(t)
+		}
+		return key.Before(t)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return before(&posts[i], order.sortKey(&posts[j]), posts[j].ID)
+	})
+
+	var result []models.Post
+	for _, p := range posts {
+		// 跳过游标及排在游标之前的帖子
+		if page.After != nil && (before(&p, page.After.Time, page.After.ID) ||
+			p.ID == page.After.ID && order.sortKey(&p).Equal(page.After.Time)) {
+			continue
+		}
+		if page.Limit > 0 && len(result) >= page.Limit {
+			break
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// ListPinnedPosts 获取未过期且未隐藏的置顶帖子，后置顶的在前
+func (s *MemoryStore) ListPinnedPosts(now time.Time) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var posts []models.Post
+	for _, p := range s.posts {
+		if p.Pinned && postVisible(p) && p.DeleteAt.After(now) {
+			posts = append(posts, p)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		a, b := s.pinnedAt[posts[i].ID], s.pinnedAt[posts[j].ID]
+		if a.Equal(b) {
+			return posts[i].ID > posts[j].ID
+		}
+		return a.After(b)
+	})
+	return posts, nil
+}
+
+// GetPost 获取未过期且未隐藏的单个帖子
+func (s *MemoryStore) GetPost(id int64, now time.Time) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, err := s.visiblePost(id, now)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListComments 按顶层评论分页获取帖子的评论，每条顶层评论后面紧跟它的全部回复，未通过审核的评论不在其中
+func (s *MemoryStore) ListComments(postID int64, page Page) ([]models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var comments []models.Comment
+	for _, c := range s.comments[postID] {
+		if c.Status == models.StatusApproved {
+			comments = append(comments, c)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		if comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].ID < comments[j].ID
+		}
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+
+	var roots []models.Comment
+	threads := make(map[int64]bool)
+	for _, c := range comments {
+		if c.ParentCommentID != 0 {
+			continue
+		}
+		if page.After != nil && !c.CreatedAt.After(page.After.Time) &&
+			!(c.CreatedAt.Equal(page.After.Time) && c.ID > page.After.ID) {
+			continue
+		}
+		if page.Limit > 0 && len(roots) >= page.Limit {
+			break
+		}
+		roots = append(roots, c)
+		threads[c.ID] = true
+	}
+	if len(roots) == 0 {
+		return nil, nil
+	}
+
+	var replies []models.Comment
+	for _, c := range comments {
+		if c.ParentCommentID != 0 && threads[c.ThreadID] {
+			replies = append(replies, c)
+		}
+	}
+	return orderThreads(roots, replies), nil
+}
+
+// GetComment 获取帖子下的单条评论
+func (s *MemoryStore) GetComment(postID, commentID int64) (*models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.comments[postID] {
+		if c.ID == commentID {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateComment 保存新评论，同时更新帖子的评论数和最后活动时间
+func (s *MemoryStore) CreateComment(comment *models.Comment, audit []models.AuditEntry) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.visiblePost(comment.PostID, comment.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	if p.Locked {
+		return 0, ErrLocked
+	}
+
+	s.nextCommentID++
+	c := *comment
+	c.ID = s.nextCommentID
+	c.Format = contentFormat(c.Format)
+	c.Status = reviewStatus(c.Status)
+	s.comments[c.PostID] = append(s.comments[c.PostID], c)
+
+	// 等待审核和被隐藏的评论不计入评论数和最后活动时间
+	if commentVisible(c) {
+		p.CommentCount++
+		if c.CreatedAt.After(p.LastActivityAt) {
+			p.LastActivityAt = c.CreatedAt
+		}
+		s.posts[p.ID] = p
+	}
+	for i := range audit {
+		audit[i].PostID, audit[i].CommentID = c.PostID, c.ID
+		s.addAudit(&audit[i], c.CreatedAt)
+	}
+	return c.ID, nil
+}
+
+// UpdatePostDeleteTime 根据最新评论或创建时间重新计算帖子的删除时间
+func (s *MemoryStore) UpdatePostDeleteTime(postID int64, daysToKeep int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[postID]; !ok {
+		return ErrNotFound
+	}
+	s.extendDeleteTime(postID, daysToKeep)
+	return nil
+}
+
+// 把帖子的删除时间推迟到最新活动时间之后 daysToKeep 天，调用方需持有锁
+func (s *MemoryStore) extendDeleteTime(postID int64, daysToKeep int) {
+	p := s.posts[postID]
+	// 等待审核和被隐藏的评论不能让帖子一直保留
+	latest := p.CreatedAt
+	for _, c := range s.comments[postID] {
+		if commentCounted(c) && c.CreatedAt.After(latest) {
+			latest = c.CreatedAt
+		}
+	}
+	// 管理员设置的更晚的删除时间保留
+	if deleteAt := latest.AddDate(0, 0, daysToKeep); deleteAt.After(p.DeleteAt) {
+		p.DeleteAt = deleteAt
+	}
+	s.posts[postID] = p
+}
+
+// 查找未过期的帖子，调用方需持有锁
+func (s *MemoryStore) livePost(id int64, now time.Time) (models.Post, error) {
+	p, ok := s.posts[id]
+	if !ok || !p.DeleteAt.After(now) {
+		return p, ErrNotFound
+	}
+	return p, nil
+}
+
+// 查找未过期、未隐藏且已通过审核的帖子，调用方需持有锁
+func (s *MemoryStore) visiblePost(id int64, now time.Time) (models.Post, error) {
+	p, err := s.livePost(id, now)
+	if err == nil && !postVisible(p) {
+		return p, ErrNotFound
+	}
+	return p, err
+}
+
+// 判断帖子是否公开显示：未隐藏且已通过审核
+func postVisible(p models.Post) bool {
+	return !p.Hidden && p.Status == models.StatusApproved
+}
+
+// 判断评论是否公开显示
+func commentVisible(c models.Comment) bool {
+	return !c.Hidden && c.Status == models.StatusApproved
+}
+
+// 判断评论是否计入帖子的评论数和最后活动时间：公开显示且未删除
+func commentCounted(c models.Comment) bool {
+	return commentVisible(c) && !c.Deleted
+}
+
+// 评论是否公开发生变化后重新统计帖子的评论数和最后活动时间，调用方需持有锁
+func (s *MemoryStore) refreshCommentStats(postID int64) {
+	p, ok := s.posts[postID]
+	if !ok {
+		return
+	}
+	p.CommentCount = 0
+	p.LastActivityAt = p.CreatedAt
+	for _, c := range s.comments[postID] {
+		if !commentCounted(c) {
+			continue
+		}
+		p.CommentCount++
+		if c.CreatedAt.After(p.LastActivityAt) {
+			p.LastActivityAt = c.CreatedAt
+		}
+	}
+	s.posts[postID] = p
+}
+
+// 判断评论是否被隐藏或未通过审核，调用方需持有锁
+func (s *MemoryStore) commentHidden(postID, commentID int64) bool {
+	for _, c := range s.comments[postID] {
+		if c.ID == commentID {
+			return !commentVisible(c)
+		}
+	}
+	return false
+}
+
+// 查找未过期帖子下评论的下标，调用方需持有锁
+func (s *MemoryStore) liveComment(postID, commentID int64, now time.Time) (int, error) {
+	if _, err := s.livePost(postID, now); err != nil {
+		return 0, err
+	}
+	for i, c := range s.comments[postID] {
+		if c.ID == commentID {
+			return i, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// UpdatePost 校验编辑令牌后修改帖子内容，再执行 review 中的管理操作
+func (s *MemoryStore) UpdatePost(id int64, content, tokenHash string, review []models.AuditEntry, now time.Time) error {
+	if err := checkReview(review); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.livePost(id, now)
+	if err != nil {
+		return err
+	}
+	if !tokenMatches(p.EditTokenHash, tokenHash) {
+		return ErrForbidden
+	}
+	if p.Content != content {
+		s.addRevision(id, 0, p.Content, now)
+		p.Content = content
+		p.Edited, p.EditedAt = true, &now
+		s.posts[id] = p
+	}
+	return s.applyReview(review, now)
+}
+
+// DeletePost 校验编辑令牌后删除帖子及其评论
+func (s *MemoryStore) DeletePost(id int64, tokenHash string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.livePost(id, now)
+	if err != nil {
+		return err
+	}
+	if !tokenMatches(p.EditTokenHash, tokenHash) {
+		return ErrForbidden
+	}
+	s.deletePost(id)
+	return nil
+}
+
+// 删除帖子及其评论、修改历史、附件和举报，调用方需持有锁
+func (s *MemoryStore) deletePost(id int64) {
+	delete(s.posts, id)
+	delete(s.comments, id)
+	delete(s.revisions, id)
+	delete(s.attachments, id)
+	delete(s.pinnedAt, id)
+	delete(s.reports, id)
+}
+
+// UpdateComment 校验编辑令牌后修改评论内容，再执行 review 中的管理操作
+func (s *MemoryStore) UpdateComment(postID, commentID int64, content, tokenHash string, review []models.AuditEntry, now time.Time) error {
+	if err := checkReview(review); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.liveComment(postID, commentID, now)
+	if err != nil {
+		return err
+	}
+	comments := s.comments[postID]
+	if !tokenMatches(comments[i].EditTokenHash, tokenHash) {
+		return ErrForbidden
+	}
+	if comments[i].Content != content {
+		s.addRevision(postID, commentID, comments[i].Content, now)
+		comments[i].Content = content
+		comments[i].Edited, comments[i].EditedAt = true, &now
+	}
+	return s.applyReview(review, now)
+}
+
+// DeleteComment 校验编辑令牌后删除评论，同时减少帖子的评论数；有回复的评论保留为占位
+func (s *MemoryStore) DeleteComment(postID, commentID int64, tokenHash string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.liveComment(postID, commentID, now)
+	if err != nil {
+		return err
+	}
+	comments := s.comments[postID]
+	if !tokenMatches(comments[i].EditTokenHash, tokenHash) {
+		return ErrForbidden
+	}
+	s.deleteComment(postID, commentID, i)
+	return nil
+}
+
+// 删除下标为 i 的评论及其修改历史、附件和举报，并重新统计帖子的评论数；有回复的评论保留为占位，调用方需持有锁
+func (s *MemoryStore) deleteComment(postID, commentID int64, i int) {
+	comments := s.comments[postID]
+	// 删除评论时一并删除它的修改历史
+	var kept []models.Revision
+	for _, r := range s.revisions[postID] {
+		if r.CommentID != commentID {
+			kept = append(kept, r)
+		}
+	}
+	s.revisions[postID] = kept
+	s.removeAttachments(postID, commentID)
+	s.deleteReports(postID, commentID)
+	if s.hasReplies(postID, commentID) {
+		comments[i].Content = ""
+		comments[i].EditTokenHash = ""
+		comments[i].Deleted = true
+	} else {
+		s.removeComment(postID, i)
+	}
+	s.refreshCommentStats(postID)
+}
+
+// 判断评论是否有回复，调用方需持有锁
+func (s *MemoryStore) hasReplies(postID, commentID int64) bool {
+	for _, c := range s.comments[postID] {
+		if c.ParentCommentID == commentID {
+			return true
+		}
+	}
+	return false
+}
+
+// 删除下标为 i 的评论，随后依次删除因此不再有回复的已删除占位，调用方需持有锁
+func (s *MemoryStore) removeComment(postID int64, i int) {
+	for {
+		comments := s.comments[postID]
+		parentID := comments[i].ParentCommentID
+		s.comments[postID] = append(comments[:i:i], comments[i+1:]...)
+		if parentID == 0 || s.hasReplies(postID, parentID) {
+			return
+		}
+		i = -1
+		for j, c := range s.comments[postID] {
+			if c.ID == parentID && c.Deleted {
+				i = j
+			}
+		}
+		if i < 0 {
+			return
+		}
+	}
+}
+
+// 保存修改前的内容，调用方需持有锁
+func (s *MemoryStore) addRevision(postID, commentID int64, content string, now time.Time) {
+	s.nextRevisionID++
+	s.revisions[postID] = append(s.revisions[postID], models.Revision{
+		ID: s.nextRevisionID, PostID: postID, CommentID: commentID, Content: content, ReplacedAt: now,
+	})
+}
+
+// ListRevisions 获取未过期帖子及其评论的修改历史，按修改时间正序
+func (s *MemoryStore) ListRevisions(postID int64, now time.Time) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := s.visiblePost(postID, now); err != nil {
+		return nil, err
+	}
+	// 隐藏的评论不能通过修改历史看到内容
+	var revisions []models.Revision
+	for _, r := range s.revisions[postID] {
+		if r.CommentID == 0 || !s.commentHidden(postID, r.CommentID) {
+			revisions = append(revisions, r)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		if revisions[i].ReplacedAt.Equal(revisions[j].ReplacedAt) {
+			return revisions[i].ID < revisions[j].ID
+		}
+		return revisions[i].ReplacedAt.Before(revisions[j].ReplacedAt)
+	})
+	return revisions, nil
+}
+
+// DeleteOldPosts 删除已过期的帖子及其评论和修改历史
+func (s *MemoryStore) DeleteOldPosts(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int64
+	for id, p := range s.posts {
+		if p.DeleteAt.Before(now) {
+			s.deletePost(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Search 在未过期的帖子和评论中搜索同时包含所有关键词的内容，按时间倒序
+func (s *MemoryStore) Search(now time.Time, terms []string, limit int) ([]models.SearchResult, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matches := func(content string) bool {
+		lower := strings.ToLower(content)
+		for _, term := range terms {
+			if !strings.Contains(lower, strings.ToLower(term)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var results []models.SearchResult
+	for _, p := range s.posts {
+		if !p.DeleteAt.After(now) || !postVisible(p) {
+			continue
+		}
+		if matches(p.Content) {
+			results = append(results, models.SearchResult{Type: models.SearchTypePost, PostID: p.ID,
+				Author: p.Author, Content: p.Content, CreatedAt: p.CreatedAt})
+		}
+		for _, c := range s.comments[p.ID] {
+			if commentVisible(c) && matches(c.Content) {
+				results = append(results, models.SearchResult{Type: models.SearchTypeComment, PostID: p.ID,
+					CommentID: c.ID, Author: c.Author, Content: c.Content, CreatedAt: c.CreatedAt})
+			}
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// ResolveQuotes 查询被引用的评论所在的帖子和被引用的帖子，已过期的帖子及其评论不在结果中
+func (s *MemoryStore) ResolveQuotes(commentIDs, postIDs []int64, now time.Time) (QuoteTargets, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	targets := QuoteTargets{Comments: make(map[int64]int64), Posts: make(map[int64]bool)}
+	wanted := make(map[int64]bool, len(commentIDs))
+	for _, id := range commentIDs {
+		wanted[id] = true
+	}
+	for postID, comments := range s.comments {
+		if _, err := s.visiblePost(postID, now); err != nil {
+			continue
+		}
+		for _, c := range comments {
+			if wanted[c.ID] && commentVisible(c) {
+				targets.Comments[c.ID] = postID
+			}
+		}
+	}
+	for _, id := range postIDs {
+		if _, err := s.visiblePost(id, now); err == nil {
+			targets.Posts[id] = true
+		}
+	}
+	return targets, nil
+}
+
+// ListBacklinks 解析未过期帖子的正文和评论，找出引用了这些评论的内容，按来源的帖子ID和评论ID排序
+func (s *MemoryStore) ListBacklinks(commentIDs []int64, now time.Time) (map[int64][]models.Backlink, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[int64]bool, len(commentIDs))
+	for _, id := range commentIDs {
+		wanted[id] = true
+	}
+	backlinks := make(map[int64][]models.Backlink)
+	add := func(postID, commentID int64, content string) {
+		for _, ref := range utils.ParseQuotes(content) {
+			if !ref.Post && ref.ID != commentID && wanted[ref.ID] {
+				backlinks[ref.ID] = append(backlinks[ref.ID], models.Backlink{PostID: postID, CommentID: commentID})
+			}
+		}
+	}
+	for id, p := range s.posts {
+		if !p.DeleteAt.After(now) || !postVisible(p) {
+			continue
+		}
+		add(id, 0, p.Content)
+		for _, c := range s.comments[id] {
+			if commentVisible(c) {
+				add(id, c.ID, c.Content)
+			}
+		}
+	}
+	for _, links := range backlinks {
+		sort.Slice(links, func(i, j int) bool {
+			if links[i].PostID == links[j].PostID {
+				return links[i].CommentID < links[j].CommentID
+			}
+			return links[i].PostID < links[j].PostID
+		})
+	}
+	return backlinks, nil
+}
+
+// CreateAttachment 校验帖子或评论的编辑令牌和附件数量后保存附件
+func (s *MemoryStore) CreateAttachment(a *models.Attachment, tokenHash string, limit int, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stored string
+	if a.CommentID == 0 {
+		p, err := s.livePost(a.PostID, now)
+		if err != nil {
+			return 0, err
+		}
+		stored = p.EditTokenHash
+	} else {
+		i, err := s.liveComment(a.PostID, a.CommentID, now)
+		if err != nil {
+			return 0, err
+		}
+		stored = s.comments[a.PostID][i].EditTokenHash
+	}
+	if !tokenMatches(stored, tokenHash) {
+		return 0, ErrForbidden
+	}
+	count := 0
+	for _, existing := range s.attachments[a.PostID] {
+		if existing.CommentID == a.CommentID {
+			count++
+		}
+	}
+	if count >= limit {
+		return 0, ErrAttachmentLimit
+	}
+
+	s.nextAttachmentID++
+	saved := *a
+	saved.ID = s.nextAttachmentID
+	s.attachments[a.PostID] = append(s.attachments[a.PostID], saved)
+	return saved.ID, nil
+}
+
+// 删除评论的附件，调用方需持有锁
+func (s *MemoryStore) removeAttachments(postID, commentID int64) {
+	var kept []models.Attachment
+	for _, a := range s.attachments[postID] {
+		if a.CommentID != commentID {
+			kept = append(kept, a)
+		}
+	}
+	s.attachments[postID] = kept
+}
+
+// ListAttachments 获取帖子及其评论的全部附件，按附件ID正序
+func (s *MemoryStore) ListAttachments(postID int64) ([]models.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.Attachment(nil), s.attachments[postID]...), nil
+}
+
+// GetAttachment 获取未过期帖子下的附件，所属的帖子或评论被隐藏时视为不存在
+func (s *MemoryStore) GetAttachment(id int64, now time.Time) (*models.Attachment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for postID, attachments := range s.attachments {
+		for _, a := range attachments {
+			if a.ID != id {
+				continue
+			}
+			if _, err := s.visiblePost(postID, now); err != nil {
+				return nil, err
+			}
+			if a.CommentID != 0 && s.commentHidden(postID, a.CommentID) {
+				return nil, ErrNotFound
+			}
+			return &a, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// BlobKeys 返回所有附件引用的文件和缩略图的键
+func (s *MemoryStore) BlobKeys() (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make(map[string]bool)
+	for _, attachments := range s.attachments {
+		for _, a := range attachments {
+			keys[a.BlobKey] = true
+			if a.ThumbnailKey != "" {
+				keys[a.ThumbnailKey] = true
+			}
+		}
+	}
+	return keys, nil
+}
+
+// Moderate 执行管理操作并记录审计日志
+func (s *MemoryStore) Moderate(e *models.AuditEntry, now time.Time) error {
+	if err := checkModeration(e); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.moderate(e, now)
+}
+
+// 执行已校验的管理操作并写入审计日志，调用方需持有锁
+func (s *MemoryStore) moderate(e *models.AuditEntry, now time.Time) error {
+	p, err := s.livePost(e.PostID, now)
+	if err != nil {
+		return err
+	}
+	i := -1
+	if e.CommentID != 0 {
+		if i, err = s.liveComment(e.PostID, e.CommentID, now); err != nil {
+			return err
+		}
+		if s.comments[e.PostID][i].Deleted {
+			return ErrNotFound
+		}
+	}
+
+	// 审核操作先检查当前状态，不允许时不修改也不记录
+	if change, ok := postStatuses[e.Action]; ok {
+		if !change.allows(p.Status) {
+			return ErrNotPending
+		}
+		p.Status = change.status
+		s.posts[p.ID] = p
+	} else if change, ok := commentStatuses[e.Action]; ok {
+		c := &s.comments[e.PostID][i]
+		if !change.allows(c.Status) {
+			return ErrNotPending
+		}
+		c.Status = change.status
+		s.refreshCommentStats(e.PostID)
+		// 通过审核的评论和新发表的评论一样推迟帖子的删除时间
+		if change.status == models.StatusApproved {
+			s.extendDeleteTime(e.PostID, utils.CurrentConfig().InactiveDaysBeforeDelete)
+		}
+	}
+
+	switch e.Action {
+	case models.ActionDeletePost:
+		s.deletePost(e.PostID)
+	case models.ActionDeleteComment:
+		s.deleteComment(e.PostID, e.CommentID, i)
+	case models.ActionHideComment, models.ActionUnhideComment:
+		s.comments[e.PostID][i].Hidden = e.Action == models.ActionHideComment
+		s.refreshCommentStats(e.PostID)
+	case models.ActionHidePost, models.ActionUnhidePost:
+		p.Hidden = e.Action == models.ActionHidePost
+		s.posts[p.ID] = p
+	case models.ActionLockPost, models.ActionUnlockPost:
+		p.Locked = e.Action == models.ActionLockPost
+		s.posts[p.ID] = p
+	case models.ActionPinPost, models.ActionUnpinPost:
+		// 重复置顶保留原来的置顶时间
+		if e.Action == models.ActionPinPost && !p.Pinned {
+			s.pinnedAt[p.ID] = now
+		}
+		p.Pinned = e.Action == models.ActionPinPost
+		s.posts[p.ID] = p
+	case models.ActionSetDeleteAt:
+		p.DeleteAt = *e.DeleteAt
+		s.posts[p.ID] = p
+	}
+	if clearsReports(e.Action) {
+		s.deleteReports(e.PostID, e.CommentID)
+	}
+	s.addAudit(e, now)
+	return nil
+}
+
+// 执行随修改一起提交的管理操作，调用方需持有锁
+func (s *MemoryStore) applyReview(review []models.AuditEntry, now time.Time) error {
+	for i := range review {
+		if err := s.moderate(&review[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 写入审计日志，填入日志的ID和时间，调用方需持有锁
+func (s *MemoryStore) addAudit(e *models.AuditEntry, now time.Time) {
+	s.nextAuditID++
+	e.ID = s.nextAuditID
+	e.CreatedAt = now
+	s.auditLog = append(s.auditLog, *e)
+}
+
+// ListAuditLog 按操作时间倒序获取审计日志
+func (s *MemoryStore) ListAuditLog(page Page) ([]models.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := append([]models.AuditEntry(nil), s.auditLog...)
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].ID > entries[j].ID
+		}
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+
+	var result []models.AuditEntry
+	for _, e := range entries {
+		if page.After != nil && !e.CreatedAt.Before(page.After.Time) &&
+			!(e.CreatedAt.Equal(page.After.Time) && e.ID < page.After.ID) {
+			continue
+		}
+		if page.Limit > 0 && len(result) >= page.Limit {
+			break
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// CreateReport 保存举报，举报次数达到阈值时自动隐藏内容
+func (s *MemoryStore) CreateReport(r *models.Report, threshold int, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 只能举报公开可见的内容
+	p, err := s.visiblePost(r.PostID, now)
+	if err != nil {
+		return false, err
+	}
+	i := -1
+	if r.CommentID != 0 {
+		if i, err = s.liveComment(r.PostID, r.CommentID, now); err != nil {
+			return false, err
+		}
+		if c := s.comments[r.PostID][i]; c.Deleted || !commentVisible(c) {
+			return false, ErrNotFound
+		}
+	}
+
+	count := 1
+	for _, existing := range s.reports[r.PostID] {
+		if existing.CommentID != r.CommentID {
+			continue
+		}
+		if existing.Reporter == r.Reporter {
+			return false, ErrAlreadyReported
+		}
+		count++
+	}
+	s.nextReportID++
+	saved := *r
+	saved.ID = s.nextReportID
+	saved.CreatedAt = now
+	s.reports[r.PostID] = append(s.reports[r.PostID], saved)
+
+	if threshold <= 0 || count < threshold {
+		return false, nil
+	}
+	if i >= 0 {
+		s.comments[r.PostID][i].Hidden = true
+		s.refreshCommentStats(r.PostID)
+	} else {
+		p.Hidden = true
+		s.posts[p.ID] = p
+	}
+	s.addAudit(autoHideEntry(r.PostID, r.CommentID, count), now)
+	return true, nil
+}
+
+// 删除帖子本身（commentID 为 0）或评论收到的举报，调用方需持有锁
+func (s *MemoryStore) deleteReports(postID, commentID int64) {
+	var kept []models.Report
+	for _, r := range s.reports[postID] {
+		if r.CommentID != commentID {
+			kept = append(kept, r)
+		}
+	}
+	s.reports[postID] = kept
+}
+
+// ListReports 按举报次数倒序获取举报队列，次数相同时最近被举报的在前
+func (s *MemoryStore) ListReports(now time.Time, limit int) ([]models.ReportSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var summaries []models.ReportSummary
+	for postID, reports := range s.reports {
+		p, err := s.livePost(postID, now)
+		if err != nil {
+			continue
+		}
+		index := make(map[int64]int)
+		start := len(summaries)
+		for _, r := range reports {
+			i, ok := index[r.CommentID]
+			if !ok {
+				summary := models.ReportSummary{
+					PostID: postID, CommentID: r.CommentID, Reasons: make(map[string]int),
+					Content: p.Content, Author: p.Author, Hidden: p.Hidden,
+					FirstReportedAt: r.CreatedAt, LastReportedAt: r.CreatedAt,
+				}
+				if r.CommentID != 0 {
+					ci, err := s.liveComment(postID, r.CommentID, now)
+					if err != nil {
+						continue
+					}
+					c := s.comments[postID][ci]
+					summary.Content, summary.Author, summary.Hidden = c.Content, c.Author, c.Hidden
+				}
+				i = len(summaries) - start
+				index[r.CommentID] = i
+				summaries = append(summaries, summary)
+			}
+			summary := &summaries[start+i]
+			summary.Count++
+			summary.Reasons[r.Reason]++
+			if r.CreatedAt.Before(summary.FirstReportedAt) {
+				summary.FirstReportedAt = r.CreatedAt
+			}
+			if r.CreatedAt.After(summary.LastReportedAt) {
+				summary.LastReportedAt = r.CreatedAt
+			}
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if !a.LastReportedAt.Equal(b.LastReportedAt) {
+			return a.LastReportedAt.After(b.LastReportedAt)
+		}
+		if a.PostID != b.PostID {
+			return a.PostID < b.PostID
+		}
+		return a.CommentID < b.CommentID
+	})
+	if limit > 0 && len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries, nil
+}
+
+// GetOwnPost 校验编辑令牌后获取帖子，被隐藏或未通过审核的帖子也会返回
+func (s *MemoryStore) GetOwnPost(id int64, tokenHash string, now time.Time) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, err := s.livePost(id, now)
+	if err != nil {
+		return nil, err
+	}
+	if !tokenMatches(p.EditTokenHash, tokenHash) {
+		return nil, ErrForbidden
+	}
+	return &p, nil
+}
+
+// GetOwnComment 校验编辑令牌后获取未过期帖子下未删除的评论，被隐藏或未通过审核的评论也会返回
+func (s *MemoryStore) GetOwnComment(postID, commentID int64, tokenHash string, now time.Time) (*models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, err := s.liveComment(postID, commentID, now)
+	if err != nil {
+		return nil, err
+	}
+	c := s.comments[postID][i]
+	if c.Deleted {
+		return nil, ErrNotFound
+	}
+	if !tokenMatches(c.EditTokenHash, tokenHash) {
+		return nil, ErrForbidden
+	}
+	return &c, nil
+}
+
+// ListPending 按创建时间正序获取未过期的帖子和评论中等待审核的部分
+func (s *MemoryStore) ListPending(now time.Time, limit int) ([]models.PendingItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var items []models.PendingItem
+	for id, p := range s.posts {
+		if !p.DeleteAt.After(now) {
+			continue
+		}
+		if p.Status == models.StatusPending {
+			items = append(items, models.PendingItem{PostID: id, Content: p.Content, Author: p.Author,
+				CreatedAt: p.CreatedAt, DeleteAt: p.DeleteAt})
+		}
+		for _, c := range s.comments[id] {
+			if c.Status == models.StatusPending && !c.Deleted {
+				items = append(items, models.PendingItem{PostID: id, CommentID: c.ID, Content: c.Content,
+					Author: c.Author, CreatedAt: c.CreatedAt, DeleteAt: p.DeleteAt})
+			}
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.PostID != b.PostID {
+			return a.PostID < b.PostID
+		}
+		return a.CommentID < b.CommentID
+	})
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 迁移脚本按数据库类型存放，文件名格式为 <版本号>_<名称>.sql
+//
+//go:embed migrations
+var migrationFS embed.FS
+
+// Migration 一个版本化的数据库迁移
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// SchemaTooNewError 数据库版本比当前程序支持的版本新
+type SchemaTooNewError struct {
+	Current int
+	Latest  int
+}
+
+func (e *SchemaTooNewError) Error() string {
+	return fmt.Sprintf("数据库结构版本 %d 高于程序支持的最新版本 %d，请升级程序", e.Current, e.Latest)
+}
+
+// 读取指定数据库类型的迁移脚本，按版本号排序
+func loadMigrations(dir string) ([]Migration, error) {
+	entries, err := migrationFS.ReadDir(path.Join("migrations", dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("迁移文件名无效: %s", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("迁移文件名无效: %s", name)
+		}
+		data, err := migrationFS.ReadFile(path.Join("migrations", dir, name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: rest, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("迁移版本号重复: %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrator 管理数据库的版本化迁移
+type Migrator struct {
+	s          *SQLStore
+	migrations []Migration
+}
+
+// Migrator 返回该存储的迁移管理器
+func (s *SQLStore) Migrator() (*Migrator, error) {
+	migrations, err := loadMigrations(s.d.name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{s: s, migrations: migrations}, nil
+}
+
+// Latest 程序内置的最新迁移版本号
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// 确保schema_version表存在
+func (m *Migrator) ensureVersionTable() error {
+	_, err := m.s.exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// Current 返回数据库当前的结构版本，未执行过迁移时为0
+func (m *Migrator) Current() (int, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := m.s.queryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Status 返回当前版本和尚未执行的迁移
+func (m *Migrator) Status() (int, []Migration, error) {
+	current, err := m.Current()
+	if err != nil {
+		return 0, nil, err
+	}
+	if current > m.Latest() {
+		return current, nil, &SchemaTooNewError{Current: current, Latest: m.Latest()}
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if mig.Version > current {
+			pending = append(pending, mig)
+		}
+	}
+	return current, pending, nil
+}
+
+// Up 依次执行所有尚未执行的迁移，每个迁移在单独的事务中完成
+func (m *Migrator) Up() ([]Migration, error) {
+	_, pending, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, mig := range pending {
+		if err := m.apply(mig); err != nil {
+			return pending[:i], fmt.Errorf("执行迁移 %04d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("已执行数据库迁移 %04d_%s", mig.Version, mig.Name)
+	}
+	return pending, nil
+}
+
+func (m *Migrator) apply(mig Migration) error {
+	tx, err := m.s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(mig.SQL); err != nil {
+		return err
+	}
+	_, err = tx.Exec(m.s.d.rebind("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)"),
+		mig.Version, mig.Name, m.s.d.timeValue(time.Now()))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Migrate 执行所有尚未执行的迁移，数据库版本比程序新时拒绝继续
+func (s *SQLStore) Migrate() error {
+	m, err := s.Migrator()
+	if err != nil {
+		return err
+	}
+	if _, err := m.Up(); err != nil {
+		return err
+	}
+	if err := s.d.prepare(s); err != nil {
+		return err
+	}
+	log.Println("数据库表结构初始化完成")
+	return nil
+}
@@ -0,0 +1,19 @@
+-- 帖子表 - 不包含标题字段，delete_at记录预计删除时间
+CREATE TABLE IF NOT EXISTS posts (
+	id BIGSERIAL PRIMARY KEY,
+	content TEXT NOT NULL,
+	author TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delete_at TIMESTAMPTZ
+);
+
+-- 评论表
+CREATE TABLE IF NOT EXISTS comments (
+	id BIGSERIAL PRIMARY KEY,
+	content TEXT NOT NULL,
+	post_id BIGINT NOT NULL REFERENCES posts(id),
+	author TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_post_id ON comments (post_id);
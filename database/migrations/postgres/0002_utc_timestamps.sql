@@ -0,0 +1,2 @@
+-- TIMESTAMPTZ 列本身存储的就是绝对时间，无需转换数据，保留此版本以便与SQLite的版本号保持一致
+SELECT 1;
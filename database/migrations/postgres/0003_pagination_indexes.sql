@@ -0,0 +1,4 @@
+-- 游标分页按 (created_at, id) 排序
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_posts_delete_at ON posts (delete_at);
+CREATE INDEX IF NOT EXISTS idx_comments_post_created_at ON comments (post_id, created_at, id);
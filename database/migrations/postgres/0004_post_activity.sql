@@ -0,0 +1,10 @@
+-- 记录帖子的评论数和最后活动时间，用于按活跃度排序和帖子摘要
+ALTER TABLE posts ADD COLUMN last_activity_at TIMESTAMPTZ;
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE posts SET
+	comment_count = (SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id),
+	last_activity_at = COALESCE((SELECT MAX(created_at) FROM comments WHERE comments.post_id = posts.id), created_at);
+
+CREATE INDEX IF NOT EXISTS idx_posts_last_activity_at ON posts (last_activity_at, id);
+CREATE INDEX IF NOT EXISTS idx_posts_delete_at_id ON posts (delete_at, id);
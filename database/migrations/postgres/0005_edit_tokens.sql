@@ -0,0 +1,4 @@
+-- 作者编辑令牌的哈希，只有持有令牌的人可以修改或删除自己的帖子和评论
+-- 之前创建的帖子和评论没有令牌，无法修改
+ALTER TABLE posts ADD COLUMN edit_token_hash TEXT;
+ALTER TABLE comments ADD COLUMN edit_token_hash TEXT;
@@ -0,0 +1,16 @@
+-- 帖子和评论的修改历史，每次修改前的内容保存为一条记录
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMPTZ;
+ALTER TABLE comments ADD COLUMN edited_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS revisions (
+	id BIGSERIAL PRIMARY KEY,
+	post_id BIGINT NOT NULL REFERENCES posts(id),
+	-- 为空表示帖子本身的修改
+	comment_id BIGINT,
+	-- 修改前的内容
+	content TEXT NOT NULL,
+	-- 这一版本被替换的时间
+	replaced_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revisions_post_id ON revisions (post_id, replaced_at, id);
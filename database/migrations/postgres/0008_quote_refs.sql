@@ -0,0 +1,14 @@
+-- 内容中 >>id 对评论的引用，用于显示“被回复”的反向链接
+-- 在创建和修改帖子或评论时根据内容重建，迁移前已有的内容不会补建
+CREATE TABLE IF NOT EXISTS quote_refs (
+	-- 引用所在的帖子
+	post_id BIGINT NOT NULL,
+	-- 引用所在的评论，为空表示帖子正文中的引用
+	comment_id BIGINT,
+	-- 被引用的评论，可以属于其他帖子
+	target_comment_id BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_refs_target_comment_id ON quote_refs (target_comment_id);
+CREATE INDEX IF NOT EXISTS idx_quote_refs_post_id ON quote_refs (post_id);
+CREATE INDEX IF NOT EXISTS idx_quote_refs_comment_id ON quote_refs (comment_id);
@@ -0,0 +1,3 @@
+-- 内容格式：plain 为纯文本，markdown 为作者选择的Markdown子集，已有内容都是纯文本
+ALTER TABLE posts ADD COLUMN format TEXT NOT NULL DEFAULT 'plain';
+ALTER TABLE comments ADD COLUMN format TEXT NOT NULL DEFAULT 'plain';
@@ -0,0 +1,13 @@
+-- 读者对帖子和评论的举报，每个客户端对同一内容只计一次
+CREATE TABLE IF NOT EXISTS reports (
+	id BIGSERIAL PRIMARY KEY,
+	post_id BIGINT NOT NULL REFERENCES posts(id),
+	-- 为 0 表示举报帖子本身，不使用 NULL 以便唯一索引去重
+	comment_id BIGINT NOT NULL DEFAULT 0,
+	reason TEXT NOT NULL,
+	-- 举报者客户端标识的哈希
+	reporter TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_target_reporter ON reports (post_id, comment_id, reporter);
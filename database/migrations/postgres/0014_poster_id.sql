@@ -0,0 +1,3 @@
+-- 发帖人ID：由客户端IP、帖子ID和日期计算的短哈希，在同一帖子中相同，在不同帖子之间无法关联。已有的内容没有ID
+ALTER TABLE posts ADD COLUMN poster_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN poster_id TEXT NOT NULL DEFAULT '';
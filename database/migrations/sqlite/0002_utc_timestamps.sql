@@ -0,0 +1,7 @@
+-- 之前的版本以中国标准时间(UTC+8)字符串存储时间，统一改为UTC存储
+UPDATE posts SET
+	created_at = strftime('%Y-%m-%d %H:%M:%S', created_at, '-8 hours'),
+	delete_at = strftime('%Y-%m-%d %H:%M:%S', delete_at, '-8 hours');
+
+UPDATE comments SET
+	created_at = strftime('%Y-%m-%d %H:%M:%S', created_at, '-8 hours');
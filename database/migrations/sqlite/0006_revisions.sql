@@ -0,0 +1,17 @@
+-- 帖子和评论的修改历史，每次修改前的内容保存为一条记录
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN edited_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	-- 为空表示帖子本身的修改
+	comment_id INTEGER,
+	-- 修改前的内容
+	content TEXT NOT NULL,
+	-- 这一版本被替换的时间
+	replaced_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (post_id) REFERENCES posts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_revisions_post_id ON revisions (post_id, replaced_at, id);
@@ -0,0 +1,10 @@
+-- 评论回复：parent_comment_id 为回复的评论，thread_id 为所在讨论串的顶层评论，顶层评论两者都为空
+-- depth 为嵌套层级，顶层评论为 0
+ALTER TABLE comments ADD COLUMN parent_comment_id INTEGER;
+ALTER TABLE comments ADD COLUMN thread_id INTEGER;
+ALTER TABLE comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+-- 有回复的评论被删除时保留占位，deleted_at 为删除时间
+ALTER TABLE comments ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_comments_thread_id ON comments (post_id, thread_id);
+CREATE INDEX IF NOT EXISTS idx_comments_parent_comment_id ON comments (parent_comment_id);
@@ -0,0 +1,23 @@
+-- 帖子和评论的附件，文件内容保存在附件存储中，按内容的SHA-256寻址
+CREATE TABLE IF NOT EXISTS attachments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	-- 为空表示帖子本身的附件
+	comment_id INTEGER,
+	-- 文件和缩略图在附件存储中的键，没有缩略图时 thumbnail_key 为空
+	blob_key TEXT NOT NULL,
+	thumbnail_key TEXT,
+	-- 上传时的文件名，只用于显示和下载
+	filename TEXT NOT NULL,
+	-- 根据内容判断出的类型
+	content_type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	-- 图片的尺寸，其他文件为 0
+	width INTEGER NOT NULL DEFAULT 0,
+	height INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (post_id) REFERENCES posts(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_post_id ON attachments (post_id, id);
+CREATE INDEX IF NOT EXISTS idx_attachments_comment_id ON attachments (comment_id);
@@ -0,0 +1,24 @@
+-- 管理员的处理：隐藏的帖子和评论不再公开显示，锁定的帖子不能再评论，置顶的帖子显示在列表最前面
+ALTER TABLE posts ADD COLUMN hidden_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN locked_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN pinned_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN hidden_at TIMESTAMP;
+
+-- 管理操作的审计日志，帖子被删除后日志仍然保留
+CREATE TABLE IF NOT EXISTS audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	action TEXT NOT NULL,
+	post_id INTEGER NOT NULL,
+	-- 针对帖子本身的操作为空
+	comment_id INTEGER,
+	-- 修改删除时间时的新值
+	delete_at TIMESTAMP,
+	reason TEXT NOT NULL DEFAULT '',
+	-- 执行操作的管理员会话和IP
+	actor TEXT NOT NULL,
+	ip TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_posts_pinned_at ON posts (pinned_at);
@@ -0,0 +1,14 @@
+-- 读者对帖子和评论的举报，每个客户端对同一内容只计一次
+CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id INTEGER NOT NULL,
+	-- 为 0 表示举报帖子本身，不使用 NULL 以便唯一索引去重
+	comment_id INTEGER NOT NULL DEFAULT 0,
+	reason TEXT NOT NULL,
+	-- 举报者客户端标识的哈希
+	reporter TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (post_id) REFERENCES posts(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_target_reporter ON reports (post_id, comment_id, reporter);
@@ -0,0 +1,7 @@
+-- 先审后发的审核状态：pending 等待审核，approved 已公开，rejected 被拒绝。已有的内容都已公开
+ALTER TABLE posts ADD COLUMN status TEXT NOT NULL DEFAULT 'approved';
+ALTER TABLE comments ADD COLUMN status TEXT NOT NULL DEFAULT 'approved';
+
+-- 审核队列只查询等待审核的内容
+CREATE INDEX IF NOT EXISTS idx_posts_pending ON posts (created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_comments_pending ON comments (created_at) WHERE status = 'pending';
@@ -0,0 +1,247 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+)
+
+// ErrInvalidAction 表示未知的管理操作，或操作缺少必要的参数
+var ErrInvalidAction = errors.New("无效的管理操作")
+
+// moderationFlag 隐藏、锁定和置顶操作修改的列：设置时写入操作时间，取消时清空
+type moderationFlag struct {
+	column string
+	set    bool
+}
+
+// 针对帖子的标记操作
+var postFlags = map[string]moderationFlag{
+	models.ActionHidePost:   {"hidden_at", true},
+	models.ActionUnhidePost: {"hidden_at", false},
+	models.ActionLockPost:   {"locked_at", true},
+	models.ActionUnlockPost: {"locked_at", false},
+	models.ActionPinPost:    {"pinned_at", true},
+	models.ActionUnpinPost:  {"pinned_at", false},
+}
+
+// 针对评论的标记操作
+var commentFlags = map[string]moderationFlag{
+	models.ActionHideComment:   {"hidden_at", true},
+	models.ActionUnhideComment: {"hidden_at", false},
+}
+
+// checkModeration 检查操作是否已知，针对评论的操作必须指定评论，其他操作不能指定评论
+func checkModeration(e *models.AuditEntry) error {
+	_, commentFlag := commentFlags[e.Action]
+	_, commentStatus := commentStatuses[e.Action]
+	onComment := commentFlag || commentStatus || e.Action == models.ActionDeleteComment ||
+		e.Action == models.ActionDismissCommentReports
+	_, postFlag := postFlags[e.Action]
+	_, postStatus := postStatuses[e.Action]
+	onPost := postFlag || postStatus || e.Action == models.ActionDeletePost || e.Action == models.ActionSetDeleteAt ||
+		e.Action == models.ActionDismissPostReports
+	switch {
+	case onComment && e.CommentID != 0:
+	case onPost && e.CommentID == 0:
+	default:
+		return ErrInvalidAction
+	}
+	if e.Action == models.ActionSetDeleteAt && e.DeleteAt == nil {
+		return ErrInvalidAction
+	}
+	return nil
+}
+
+// clearsReports 判断操作是否清空目标的举报：管理员取消隐藏说明内容已经审核过，之前的举报不再计入自动隐藏
+func clearsReports(action string) bool {
+	switch action {
+	case models.ActionDismissPostReports, models.ActionDismissCommentReports,
+		models.ActionUnhidePost, models.ActionUnhideComment:
+		return true
+	}
+	return false
+}
+
+// 审计日志查询使用的列，顺序与 scanAuditEntry 一致
+const auditColumns = "id, action, post_id, comment_id, delete_at, reason, actor, ip, created_at"
+
+// Moderate 执行管理操作并记录审计日志
+func (s *SQLStore) Moderate(e *models.AuditEntry, now time.Time) error {
+	if err := checkModeration(e); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.moderate(tx, e, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// moderate 在事务中执行已校验的管理操作并写入审计日志
+func (s *SQLStore) moderate(tx *sql.Tx, e *models.AuditEntry, now time.Time) error {
+	// 操作的帖子必须未过期，隐藏的帖子也可以处理；评论必须属于该帖子且未被删除
+	var exists int
+	err := tx.QueryRow(s.d.rebind("SELECT 1 FROM posts WHERE id = ? AND delete_at > ?"),
+		e.PostID, s.d.timeValue(now)).Scan(&exists)
+	if err == nil && e.CommentID != 0 {
+		err = tx.QueryRow(s.d.rebind("SELECT 1 FROM comments WHERE id = ? AND post_id = ? AND deleted_at IS NULL"),
+			e.CommentID, e.PostID).Scan(&exists)
+	}
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if flag, ok := postFlags[e.Action]; ok {
+		err = s.setFlag(tx, "posts", flag, e.PostID, now)
+	} else if flag, ok := commentFlags[e.Action]; ok {
+		err = s.setFlag(tx, "comments", flag, e.CommentID, now)
+	} else if change, ok := postStatuses[e.Action]; ok {
+		err = s.setStatus(tx, "posts", change, e.PostID)
+	} else if change, ok := commentStatuses[e.Action]; ok {
+		err = s.setStatus(tx, "comments", change, e.CommentID)
+	} else {
+		switch e.Action {
+		case models.ActionDeletePost:
+			_, err = s.deletePosts(tx, []int64{e.PostID})
+		case models.ActionDeleteComment:
+			err = s.deleteComment(tx, e.PostID, e.CommentID, now)
+		case models.ActionSetDeleteAt:
+			_, err = tx.Exec(s.d.rebind("UPDATE posts SET delete_at = ? WHERE id = ?"),
+				s.d.timeValue(*e.DeleteAt), e.PostID)
+		}
+	}
+	if err == nil && clearsReports(e.Action) {
+		err = s.deleteReports(tx, e.PostID, e.CommentID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.insertAudit(tx, e, now)
+}
+
+// checkReview 检查随修改一起提交的管理操作，在修改内容之前调用
+func checkReview(review []models.AuditEntry) error {
+	for i := range review {
+		if err := checkModeration(&review[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyReview 在修改内容的事务中执行随修改一起提交的管理操作
+func (s *SQLStore) applyReview(tx *sql.Tx, review []models.AuditEntry, now time.Time) error {
+	for i := range review {
+		if err := s.moderate(tx, &review[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertAudit 在事务中写入审计日志，填入日志的ID和时间
+func (s *SQLStore) insertAudit(tx *sql.Tx, e *models.AuditEntry, now time.Time) error {
+	var deleteAt interface{}
+	if e.DeleteAt != nil {
+		deleteAt = s.d.timeValue(*e.DeleteAt)
+	}
+	err := tx.QueryRow(s.d.rebind(`
+		INSERT INTO audit_log (action, post_id, comment_id, delete_at, reason, actor, ip, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		e.Action, e.PostID, nullID(e.CommentID), deleteAt, e.Reason, e.Actor, e.IP, s.d.timeValue(now)).Scan(&e.ID)
+	if err != nil {
+		return err
+	}
+	e.CreatedAt = now
+	return nil
+}
+
+// setFlag 设置或清空帖子或评论的标记列，已设置的标记保留原来的时间
+func (s *SQLStore) setFlag(tx *sql.Tx, table string, flag moderationFlag, id int64, now time.Time) error {
+	var err error
+	if flag.set {
+		_, err = tx.Exec(s.d.rebind("UPDATE "+table+" SET "+flag.column+" = COALESCE("+flag.column+", ?) WHERE id = ?"),
+			s.d.timeValue(now), id)
+	} else {
+		_, err = tx.Exec(s.d.rebind("UPDATE "+table+" SET "+flag.column+" = NULL WHERE id = ?"), id)
+	}
+	if err != nil || table != "comments" {
+		return err
+	}
+	return s.refreshCommentStatsOf(tx, id)
+}
+
+// 计入帖子评论数和最后活动时间的评论：已通过审核、未隐藏且未删除，c 为评论表的别名
+const countedCommentWhere = "c.status = 'approved' AND c.hidden_at IS NULL AND c.deleted_at IS NULL"
+
+// refreshCommentStats 评论是否公开发生变化后，重新统计帖子的评论数和最后活动时间
+func (s *SQLStore) refreshCommentStats(tx *sql.Tx, postID int64) error {
+	_, err := tx.Exec(s.d.rebind(`
+		UPDATE posts SET
+			comment_count = (SELECT COUNT(*) FROM comments c WHERE c.post_id = ? AND `+countedCommentWhere+`),
+			last_activity_at = COALESCE((SELECT MAX(c.created_at) FROM comments c WHERE c.post_id = ? AND `+countedCommentWhere+`), created_at)
+		WHERE id = ?`), postID, postID, postID)
+	return err
+}
+
+// refreshCommentStatsOf 重新统计评论所属帖子的评论数和最后活动时间
+func (s *SQLStore) refreshCommentStatsOf(tx *sql.Tx, commentID int64) error {
+	var postID int64
+	if err := tx.QueryRow(s.d.rebind("SELECT post_id FROM comments WHERE id = ?"), commentID).Scan(&postID); err != nil {
+		return err
+	}
+	return s.refreshCommentStats(tx, postID)
+}
+
+// ListAuditLog 按操作时间倒序查询审计日志
+func (s *SQLStore) ListAuditLog(page Page) ([]models.AuditEntry, error) {
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	var args []interface{}
+	if page.After != nil {
+		query += " WHERE created_at < ? OR (created_at = ? AND id < ?)"
+		after := s.d.timeValue(page.After.Time)
+		args = append(args, after, after, page.After.ID)
+	}
+	query += " ORDER BY created_at DESC, id DESC" + limitClause(page.Limit)
+
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var commentID sql.NullInt64
+		var deleteAt, createdAt interface{}
+		err := rows.Scan(&e.ID, &e.Action, &e.PostID, &commentID, &deleteAt, &e.Reason, &e.Actor, &e.IP, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		e.CommentID = commentID.Int64
+		if deleteAt != nil {
+			t, err := s.d.parseTime(deleteAt)
+			if err != nil {
+				return nil, err
+			}
+			e.DeleteAt = &t
+		}
+		if e.CreatedAt, err = s.d.parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
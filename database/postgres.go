@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresDialect PostgreSQL使用$n占位符和TIMESTAMPTZ列
+type postgresDialect struct{}
+
+func (postgresDialect) name() string {
+	return "postgres"
+}
+
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) timeValue(t time.Time) interface{} {
+	return t.UTC()
+}
+
+func (postgresDialect) likeOperator() string {
+	return "ILIKE"
+}
+
+func (postgresDialect) prepare(s *SQLStore) error {
+	return nil
+}
+
+func (postgresDialect) checkpoint(db *sql.DB) error {
+	return nil
+}
+
+func (postgresDialect) parseTime(v interface{}) (time.Time, error) {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("无法解析的时间值: %v", v)
+}
+
+// NewPostgresStore 连接PostgreSQL数据库并执行尚未执行的迁移
+func NewPostgresStore(dsn string) (*SQLStore, error) {
+	s, err := openPostgres(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Migrate(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 连接PostgreSQL数据库，不执行迁移
+func openPostgres(dsn string) (*SQLStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// 测试连接
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Println("成功连接到PostgreSQL数据库")
+	return &SQLStore{db: db, d: postgresDialect{}}, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// saveQuotes 在事务中根据内容重建帖子正文（commentID 为 0）或评论对评论的引用，内容为空时只删除
+func (s *SQLStore) saveQuotes(tx *sql.Tx, postID, commentID int64, content string) error {
+	var err error
+	if commentID == 0 {
+		_, err = tx.Exec(s.d.rebind("DELETE FROM quote_refs WHERE post_id = ? AND comment_id IS NULL"), postID)
+	} else {
+		_, err = tx.Exec(s.d.rebind("DELETE FROM quote_refs WHERE comment_id = ?"), commentID)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range utils.ParseQuotes(content) {
+		// 只记录对评论的引用，评论引用自己时忽略
+		if ref.Post || ref.ID == commentID {
+			continue
+		}
+		_, err := tx.Exec(s.d.rebind("INSERT INTO quote_refs (post_id, comment_id, target_comment_id) VALUES (?, ?, ?)"),
+			postID, nullID(commentID), ref.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveQuotes 查询被引用的评论所在的帖子和被引用的帖子，已过期或隐藏的帖子及其评论、隐藏的评论不在结果中
+func (s *SQLStore) ResolveQuotes(commentIDs, postIDs []int64, now time.Time) (QuoteTargets, error) {
+	targets := QuoteTargets{Comments: make(map[int64]int64), Posts: make(map[int64]bool)}
+
+	if len(commentIDs) > 0 {
+		placeholders, args := inPlaceholders(commentIDs)
+		rows, err := s.query(`
+			SELECT c.id, c.post_id FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			WHERE c.id IN (`+placeholders+`) AND p.delete_at > ? AND p.hidden_at IS NULL AND p.status = 'approved'
+				AND c.hidden_at IS NULL AND c.status = 'approved'
+		`, append(args, s.d.timeValue(now))...)
+		if err != nil {
+			return targets, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id, postID int64
+			if err := rows.Scan(&id, &postID); err != nil {
+				return targets, err
+			}
+			targets.Comments[id] = postID
+		}
+		if err := rows.Err(); err != nil {
+			return targets, err
+		}
+	}
+
+	if len(postIDs) > 0 {
+		placeholders, args := inPlaceholders(postIDs)
+		rows, err := s.query(`SELECT id FROM posts WHERE id IN (`+placeholders+`) AND delete_at > ? AND hidden_at IS NULL AND status = 'approved'`,
+			append(args, s.d.timeValue(now))...)
+		if err != nil {
+			return targets, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return targets, err
+			}
+			targets.Posts[id] = true
+		}
+		if err := rows.Err(); err != nil {
+			return targets, err
+		}
+	}
+	return targets, nil
+}
+
+// ListBacklinks 查询引用了这些评论的帖子正文和评论，按来源的帖子ID和评论ID排序，隐藏的来源不在结果中
+func (s *SQLStore) ListBacklinks(commentIDs []int64, now time.Time) (map[int64][]models.Backlink, error) {
+	backlinks := make(map[int64][]models.Backlink)
+	if len(commentIDs) == 0 {
+		return backlinks, nil
+	}
+
+	placeholders, args := inPlaceholders(commentIDs)
+	rows, err := s.query(`
+		SELECT q.target_comment_id, q.post_id, q.comment_id FROM quote_refs q
+		JOIN posts p ON p.id = q.post_id
+		LEFT JOIN comments c ON c.id = q.comment_id
+		WHERE q.target_comment_id IN (`+placeholders+`) AND p.delete_at > ? AND p.hidden_at IS NULL AND p.status = 'approved'
+			AND c.hidden_at IS NULL AND COALESCE(c.status, 'approved') = 'approved'
+		ORDER BY q.post_id ASC, COALESCE(q.comment_id, 0) ASC
+	`, append(args, s.d.timeValue(now))...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var target int64
+		var link models.Backlink
+		var commentID sql.NullInt64
+		if err := rows.Scan(&target, &link.PostID, &commentID); err != nil {
+			return nil, err
+		}
+		link.CommentID = commentID.Int64
+		backlinks[target] = append(backlinks[target], link)
+	}
+	return backlinks, rows.Err()
+}
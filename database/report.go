@@ -0,0 +1,173 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+)
+
+// autoHideEntry 举报达到阈值时自动隐藏内容的审计日志
+func autoHideEntry(postID, commentID int64, count int) *models.AuditEntry {
+	action := models.ActionHidePost
+	if commentID != 0 {
+		action = models.ActionHideComment
+	}
+	return &models.AuditEntry{
+		Action:    action,
+		PostID:    postID,
+		CommentID: commentID,
+		Reason:    fmt.Sprintf("被举报 %d 次，自动隐藏", count),
+		Actor:     models.ActorAuto,
+	}
+}
+
+// CreateReport 保存举报，举报次数达到阈值时自动隐藏内容
+func (s *SQLStore) CreateReport(r *models.Report, threshold int, now time.Time) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// 只能举报公开可见的内容
+	var exists int
+	err = tx.QueryRow(s.d.rebind("SELECT 1 FROM posts WHERE id = ? AND delete_at > ? AND hidden_at IS NULL AND status = 'approved'"),
+		r.PostID, s.d.timeValue(now)).Scan(&exists)
+	if err == nil && r.CommentID != 0 {
+		err = tx.QueryRow(s.d.rebind("SELECT 1 FROM comments WHERE id = ? AND post_id = ? AND deleted_at IS NULL AND hidden_at IS NULL AND status = 'approved'"),
+			r.CommentID, r.PostID).Scan(&exists)
+	}
+	if err == sql.ErrNoRows {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// 唯一索引保证每个举报者对同一内容只有一条举报
+	result, err := tx.Exec(s.d.rebind(`
+		INSERT INTO reports (post_id, comment_id, reason, reporter, created_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`),
+		r.PostID, r.CommentID, r.Reason, r.Reporter, s.d.timeValue(now))
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return false, err
+	} else if n == 0 {
+		return false, ErrAlreadyReported
+	}
+
+	hidden := false
+	if threshold > 0 {
+		var count int
+		err := tx.QueryRow(s.d.rebind("SELECT COUNT(*) FROM reports WHERE post_id = ? AND comment_id = ?"),
+			r.PostID, r.CommentID).Scan(&count)
+		if err != nil {
+			return false, err
+		}
+		if count >= threshold {
+			table, id := "posts", r.PostID
+			if r.CommentID != 0 {
+				table, id = "comments", r.CommentID
+			}
+			if err := s.setFlag(tx, table, moderationFlag{"hidden_at", true}, id, now); err != nil {
+				return false, err
+			}
+			if err := s.insertAudit(tx, autoHideEntry(r.PostID, r.CommentID, count), now); err != nil {
+				return false, err
+			}
+			hidden = true
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return hidden, nil
+}
+
+// deleteReports 删除帖子本身（commentID 为 0）或评论收到的举报
+func (s *SQLStore) deleteReports(tx *sql.Tx, postID, commentID int64) error {
+	_, err := tx.Exec(s.d.rebind("DELETE FROM reports WHERE post_id = ? AND comment_id = ?"), postID, commentID)
+	return err
+}
+
+// ListReports 按举报次数倒序获取举报队列，次数相同时最近被举报的在前
+func (s *SQLStore) ListReports(now time.Time, limit int) ([]models.ReportSummary, error) {
+	rows, err := s.query(`
+		SELECT r.post_id, r.comment_id, r.n, r.first_at, r.last_at,
+			COALESCE(c.content, p.content), COALESCE(c.author, p.author),
+			CASE WHEN r.comment_id = 0 THEN p.hidden_at IS NOT NULL ELSE c.hidden_at IS NOT NULL END
+		FROM (
+			SELECT post_id, comment_id, COUNT(*) AS n, MIN(created_at) AS first_at, MAX(created_at) AS last_at
+			FROM reports GROUP BY post_id, comment_id
+		) r
+		JOIN posts p ON p.id = r.post_id
+		LEFT JOIN comments c ON c.id = r.comment_id AND c.post_id = r.post_id
+		WHERE p.delete_at > ? AND (r.comment_id = 0 OR (c.id IS NOT NULL AND c.deleted_at IS NULL))
+		ORDER BY r.n DESC, r.last_at DESC, r.post_id, r.comment_id`+limitClause(limit),
+		s.d.timeValue(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.ReportSummary
+	for rows.Next() {
+		var r models.ReportSummary
+		var firstAt, lastAt interface{}
+		err := rows.Scan(&r.PostID, &r.CommentID, &r.Count, &firstAt, &lastAt, &r.Content, &r.Author, &r.Hidden)
+		if err != nil {
+			return nil, err
+		}
+		if r.FirstReportedAt, err = s.d.parseTime(firstAt); err != nil {
+			return nil, err
+		}
+		if r.LastReportedAt, err = s.d.parseTime(lastAt); err != nil {
+			return nil, err
+		}
+		r.Reasons = make(map[string]int)
+		summaries = append(summaries, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, nil
+	}
+	return summaries, s.countReportReasons(summaries)
+}
+
+// countReportReasons 统计队列中每项的各举报原因次数
+func (s *SQLStore) countReportReasons(summaries []models.ReportSummary) error {
+	type target struct{ postID, commentID int64 }
+	index := make(map[target]int, len(summaries))
+	postIDs := make([]int64, 0, len(summaries))
+	for i, r := range summaries {
+		index[target{r.PostID, r.CommentID}] = i
+		postIDs = append(postIDs, r.PostID)
+	}
+
+	placeholders, args := inPlaceholders(postIDs)
+	rows, err := s.query(`
+		SELECT post_id, comment_id, reason, COUNT(*) FROM reports
+		WHERE post_id IN (`+placeholders+`) GROUP BY post_id, comment_id, reason`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t target
+		var reason string
+		var n int
+		if err := rows.Scan(&t.postID, &t.commentID, &reason, &n); err != nil {
+			return err
+		}
+		if i, ok := index[t]; ok {
+			summaries[i].Reasons[reason] = n
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// statusChange 审核操作把内容改为 status，from 为允许操作的原状态，为空时不限制
+type statusChange struct {
+	status string
+	from   []string
+}
+
+// allows 判断内容当前的状态是否允许该操作
+func (c statusChange) allows(current string) bool {
+	return len(c.from) == 0 || slices.Contains(c.from, current)
+}
+
+// 针对帖子的审核操作
+var postStatuses = map[string]statusChange{
+	models.ActionApprovePost: {models.StatusApproved, []string{models.StatusPending, models.StatusRejected}},
+	models.ActionRejectPost:  {models.StatusRejected, []string{models.StatusPending}},
+	models.ActionHoldPost:    {models.StatusPending, nil},
+}
+
+// 针对评论的审核操作
+var commentStatuses = map[string]statusChange{
+	models.ActionApproveComment: {models.StatusApproved, []string{models.StatusPending, models.StatusRejected}},
+	models.ActionRejectComment:  {models.StatusRejected, []string{models.StatusPending}},
+	models.ActionHoldComment:    {models.StatusPending, nil},
+}
+
+// setStatus 在事务中修改帖子或评论的审核状态，当前状态不允许该操作时返回 ErrNotPending
+func (s *SQLStore) setStatus(tx *sql.Tx, table string, change statusChange, id int64) error {
+	var current string
+	if err := tx.QueryRow(s.d.rebind("SELECT status FROM "+table+" WHERE id = ?"), id).Scan(&current); err != nil {
+		return err
+	}
+	if !change.allows(current) {
+		return ErrNotPending
+	}
+	_, err := tx.Exec(s.d.rebind("UPDATE "+table+" SET status = ? WHERE id = ?"), change.status, id)
+	if err != nil || table != "comments" {
+		return err
+	}
+	var postID int64
+	if err := tx.QueryRow(s.d.rebind("SELECT post_id FROM comments WHERE id = ?"), id).Scan(&postID); err != nil {
+		return err
+	}
+	if err := s.refreshCommentStats(tx, postID); err != nil || change.status != models.StatusApproved {
+		return err
+	}
+	// 通过审核的评论和新发表的评论一样推迟帖子的删除时间
+	return s.extendDeleteTime(tx, postID, utils.CurrentConfig().InactiveDaysBeforeDelete)
+}
+
+// GetOwnPost 校验编辑令牌后查询帖子，被隐藏或未通过审核的帖子也会返回
+func (s *SQLStore) GetOwnPost(id int64, tokenHash string, now time.Time) (*models.Post, error) {
+	var stored sql.NullString
+	err := s.queryRow("SELECT edit_token_hash FROM posts WHERE id = ? AND delete_at > ?",
+		id, s.d.timeValue(now)).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !tokenMatches(stored.String, tokenHash) {
+		return nil, ErrForbidden
+	}
+
+	post, err := s.scanPost(s.queryRow(`SELECT `+postColumns+` FROM posts WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// GetOwnComment 校验编辑令牌后查询未过期帖子下未删除的评论，被隐藏或未通过审核的评论也会返回
+func (s *SQLStore) GetOwnComment(postID, commentID int64, tokenHash string, now time.Time) (*models.Comment, error) {
+	var stored sql.NullString
+	err := s.queryRow(`
+		SELECT c.edit_token_hash FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.id = ? AND c.post_id = ? AND p.delete_at > ? AND c.deleted_at IS NULL
+	`, commentID, postID, s.d.timeValue(now)).Scan(&stored)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !tokenMatches(stored.String, tokenHash) {
+		return nil, ErrForbidden
+	}
+	return s.GetComment(postID, commentID)
+}
+
+// ListPending 按创建时间正序获取未过期的帖子和评论中等待审核的部分
+func (s *SQLStore) ListPending(now time.Time, limit int) ([]models.PendingItem, error) {
+	nowValue := s.d.timeValue(now)
+	rows, err := s.query(`
+		SELECT id AS post_id, 0 AS comment_id, content, author, created_at, delete_at
+		FROM posts
+		WHERE status = 'pending' AND delete_at > ?
+		UNION ALL
+		SELECT c.post_id, c.id, c.content, c.author, c.created_at, p.delete_at
+		FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE c.status = 'pending' AND c.deleted_at IS NULL AND p.delete_at > ?
+		ORDER BY created_at ASC, post_id ASC, comment_id ASC`+limitClause(limit),
+		nowValue, nowValue)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.PendingItem
+	for rows.Next() {
+		var item models.PendingItem
+		var createdAt, deleteAt interface{}
+		if err := rows.Scan(&item.PostID, &item.CommentID, &item.Content, &item.Author, &createdAt, &deleteAt); err != nil {
+			return nil, err
+		}
+		if item.CreatedAt, err = s.d.parseTime(createdAt); err != nil {
+			return nil, err
+		}
+		if item.DeleteAt, err = s.d.parseTime(deleteAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
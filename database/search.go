@@ -0,0 +1,97 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+)
+
+// 搜索关键词最多个数，多余的忽略
+const maxSearchTerms = 8
+
+// SearchTerms 将搜索语句按空白拆分为关键词，去掉重复的关键词
+func SearchTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(query) {
+		key := strings.ToLower(term)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// 转义LIKE模式中的特殊字符，配合 ESCAPE '\' 使用
+func likePattern(term string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(term) + "%"
+}
+
+// Search 在未过期且未隐藏的帖子和评论中搜索同时包含所有关键词的内容，按时间倒序
+func (s *SQLStore) Search(now time.Time, terms []string, limit int) ([]models.SearchResult, error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if s.fts {
+		return s.searchFTS(now, terms, limit)
+	}
+
+	like := s.d.likeOperator()
+	nowValue := s.d.timeValue(now)
+	var postConds, commentConds string
+	postArgs := []interface{}{nowValue}
+	commentArgs := []interface{}{nowValue}
+	for _, term := range terms {
+		postConds += ` AND p.content ` + like + ` ? ESCAPE '\'`
+		commentConds += ` AND c.content ` + like + ` ? ESCAPE '\'`
+		postArgs = append(postArgs, likePattern(term))
+		commentArgs = append(commentArgs, likePattern(term))
+	}
+
+	query := `
+		SELECT p.id AS post_id, 0 AS comment_id, p.content, p.author, p.created_at
+		FROM posts p
+		WHERE p.delete_at > ? AND p.hidden_at IS NULL AND p.status = 'approved'` + postConds + `
+		UNION ALL
+		SELECT c.post_id, c.id, c.content, c.author, c.created_at
+		FROM comments c JOIN posts p ON p.id = c.post_id
+		WHERE p.delete_at > ? AND p.hidden_at IS NULL AND p.status = 'approved'
+			AND c.hidden_at IS NULL AND c.status = 'approved'` + commentConds + `
+		ORDER BY created_at DESC` + limitClause(limit)
+	return s.scanSearchResults(query, append(postArgs, commentArgs...)...)
+}
+
+// 扫描搜索结果，列顺序为 post_id, comment_id, content, author, created_at
+func (s *SQLStore) scanSearchResults(query string, args ...interface{}) ([]models.SearchResult, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var r models.SearchResult
+		var createdAt interface{}
+		if err := rows.Scan(&r.PostID, &r.CommentID, &r.Content, &r.Author, &createdAt); err != nil {
+			return nil, err
+		}
+		r.CreatedAt, err = s.d.parseTime(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		r.Type = models.SearchTypePost
+		if r.CommentID != 0 {
+			r.Type = models.SearchTypeComment
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,570 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// dialect 描述不同SQL数据库之间的差异
+type dialect interface {
+	// name 数据库类型，对应 migrations 下的目录名
+	name() string
+	// rebind 将查询中的 ? 占位符转换为数据库使用的形式
+	rebind(query string) string
+	// timeValue 将时间转换为写入数据库的值，统一使用UTC
+	timeValue(t time.Time) interface{}
+	// parseTime 解析从数据库读出的时间值，返回UTC时间
+	parseTime(v interface{}) (time.Time, error)
+	// likeOperator 不区分大小写的LIKE运算符
+	likeOperator() string
+	// prepare 在迁移完成后执行数据库特有的初始化
+	prepare(s *SQLStore) error
+	// checkpoint 关闭连接前把未落盘的数据写回数据库
+	checkpoint(db *sql.DB) error
+}
+
+// SQLStore 基于database/sql的存储实现，SQLite和PostgreSQL共用
+type SQLStore struct {
+	db *sql.DB
+	d  dialect
+	// fts 是否可以使用SQLite的FTS5全文索引
+	fts bool
+}
+
+// Close 写回未落盘的数据并关闭数据库连接
+func (s *SQLStore) Close() error {
+	return errors.Join(s.d.checkpoint(s.db), s.db.Close())
+}
+
+func (s *SQLStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.d.rebind(query), args...)
+}
+
+func (s *SQLStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(s.d.rebind(query), args...)
+}
+
+func (s *SQLStore) queryRow(query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRow(s.d.rebind(query), args...)
+}
+
+// 分页条数限制，0 表示不限制
+func limitClause(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return " LIMIT " + strconv.Itoa(limit)
+}
+
+// 为SQL IN语句准备参数占位符
+func inPlaceholders(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","), args
+}
+
+// 空字符串写入为NULL
+func nullString(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+// 隐藏的内容写入隐藏时间，未隐藏时写入为NULL
+func (s *SQLStore) hiddenAt(hidden bool, t time.Time) interface{} {
+	if !hidden {
+		return nil
+	}
+	return s.d.timeValue(t)
+}
+
+// 帖子查询使用的列，顺序与 scanPost 一致
+const postColumns = "id, content, author, created_at, delete_at, last_activity_at, comment_count, edited_at, format, " +
+	"hidden_at IS NOT NULL, locked_at IS NOT NULL, pinned_at IS NOT NULL, status, poster_id"
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// 扫描一行 postColumns 到帖子
+func (s *SQLStore) scanPost(row rowScanner) (models.Post, error) {
+	var post models.Post
+	var createdAt, deleteAt, lastActivityAt, editedAt interface{}
+	err := row.Scan(&post.ID, &post.Content, &post.Author, &createdAt, &deleteAt, &lastActivityAt, &post.CommentCount, &editedAt, &post.Format,
+		&post.Hidden, &post.Locked, &post.Pinned, &post.Status, &post.PosterID)
+	if err != nil {
+		return post, err
+	}
+	s.setPostTimes(&post, createdAt, deleteAt, lastActivityAt)
+	post.EditedAt = s.editedTime(editedAt)
+	post.Edited = post.EditedAt != nil
+	return post, nil
+}
+
+// 解析可为空的修改时间，没有修改过时返回 nil
+func (s *SQLStore) editedTime(v interface{}) *time.Time {
+	if v == nil {
+		return nil
+	}
+	t, err := s.d.parseTime(v)
+	if err != nil {
+		log.Printf("解析修改时间失败: %v", err)
+		return nil
+	}
+	return &t
+}
+
+// CreatePost 存储新帖子，包含删除时间，同时记录正文中的引用；Hidden 为 true 时帖子创建后即隐藏。
+// posterID 不为空时在同一事务中保存由帖子ID计算的发帖人ID，audit 在同一事务中写入审计日志
+func (s *SQLStore) CreatePost(post *models.Post, posterID PosterIDFunc, audit []models.AuditEntry) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow(s.d.rebind(
+		"INSERT INTO posts (content, author, created_at, delete_at, last_activity_at, edit_token_hash, format, hidden_at, status, poster_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id"),
+		post.Content, post.Author, s.d.timeValue(post.CreatedAt), s.d.timeValue(post.DeleteAt),
+		s.d.timeValue(post.CreatedAt), nullString(post.EditTokenHash), contentFormat(post.Format),
+		s.hiddenAt(post.Hidden, post.CreatedAt), reviewStatus(post.Status), post.PosterID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if posterID != nil {
+		poster, err := posterID(id)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(s.d.rebind("UPDATE posts SET poster_id = ? WHERE id = ?"), poster, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.saveQuotes(tx, id, 0, post.Content); err != nil {
+		return 0, err
+	}
+	for i := range audit {
+		audit[i].PostID = id
+		if err := s.insertAudit(tx, &audit[i], post.CreatedAt); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// ListPosts 查询未过期的帖子，使用delete_at字段判断；隐藏、未通过审核和置顶的帖子不在其中
+func (s *SQLStore) ListPosts(now time.Time, sort PostSort, page Page) ([]models.Post, error) {
+	column, desc := sort.column()
+	query := `SELECT ` + postColumns + ` FROM posts WHERE delete_at > ? AND hidden_at IS NULL AND status = 'approved' AND pinned_at IS NULL`
+	args := []interface{}{s.d.timeValue(now)}
+	if page.After != nil {
+		if desc {
+			query += ` AND (` + column + ` < ? OR (` + column + ` = ? AND id < ?))`
+		} else {
+			query += ` AND (` + column + ` > ? OR (` + column + ` = ? AND id > ?))`
+		}
+		after := s.d.timeValue(page.After.Time)
+		args = append(args, after, after, page.After.ID)
+	}
+	if desc {
+		query += ` ORDER BY ` + column + ` DESC, id DESC`
+	} else {
+		query += ` ORDER BY ` + column + ` ASC, id ASC`
+	}
+	query += limitClause(page.Limit)
+	return s.queryPosts(query, args...)
+}
+
+// ListPinnedPosts 查询未过期且未隐藏的置顶帖子，后置顶的在前
+func (s *SQLStore) ListPinnedPosts(now time.Time) ([]models.Post, error) {
+	return s.queryPosts(`
+		SELECT `+postColumns+` FROM posts
+		WHERE delete_at > ? AND hidden_at IS NULL AND status = 'approved' AND pinned_at IS NOT NULL
+		ORDER BY pinned_at DESC, id DESC
+	`, s.d.timeValue(now))
+}
+
+// 查询多个帖子
+func (s *SQLStore) queryPosts(query string, args ...interface{}) ([]models.Post, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		post, err := s.scanPost(rows)
+		if err != nil {
+			log.Printf("扫描帖子数据失败: %v", err)
+			continue
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// GetPost 查询单个帖子，使用delete_at字段判断是否过期，隐藏和未通过审核的帖子视为不存在
+func (s *SQLStore) GetPost(id int64, now time.Time) (*models.Post, error) {
+	post, err := s.scanPost(s.queryRow(`
+		SELECT `+postColumns+`
+		FROM posts
+		WHERE id = ? AND delete_at > ? AND hidden_at IS NULL AND status = 'approved'
+	`, id, s.d.timeValue(now)))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// 解析帖子的时间字段，解析失败时使用后备值
+func (s *SQLStore) setPostTimes(post *models.Post, createdAt, deleteAt, lastActivityAt interface{}) {
+	t, err := s.d.parseTime(createdAt)
+	if err != nil {
+		log.Printf("解析创建时间失败: %v", err)
+		// 使用当前时间作为后备
+		t = time.Now().UTC()
+	}
+	post.CreatedAt = t
+
+	// 解析删除时间
+	dt, err := s.d.parseTime(deleteAt)
+	if err != nil {
+		log.Printf("解析删除时间失败: %v", err)
+		// 使用创建时间加上默认过期天数作为后备
+		dt = t.AddDate(0, 0, utils.CurrentConfig().InactiveDaysBeforeDelete)
+	}
+	post.DeleteAt = dt
+
+	// 解析最后活动时间，没有时使用创建时间
+	at, err := s.d.parseTime(lastActivityAt)
+	if err != nil {
+		at = t
+	}
+	post.LastActivityAt = at
+}
+
+// 评论查询使用的列，顺序与 scanComment 一致
+const commentColumns = "id, post_id, content, author, created_at, edited_at, parent_comment_id, thread_id, depth, deleted_at, format, " +
+	"hidden_at IS NOT NULL, status, poster_id"
+
+// 扫描一行 commentColumns 到评论
+func (s *SQLStore) scanComment(row rowScanner) (models.Comment, error) {
+	var comment models.Comment
+	var createdAt, editedAt, deletedAt interface{}
+	var parentID, threadID sql.NullInt64
+	err := row.Scan(&comment.ID, &comment.PostID, &comment.Content, &comment.Author, &createdAt, &editedAt,
+		&parentID, &threadID, &comment.Depth, &deletedAt, &comment.Format, &comment.Hidden, &comment.Status, &comment.PosterID)
+	if err != nil {
+		return comment, err
+	}
+	t, err := s.d.parseTime(createdAt)
+	if err != nil {
+		log.Printf("解析评论时间失败: %v", err)
+		// 使用当前时间作为后备
+		t = time.Now().UTC()
+	}
+	comment.CreatedAt = t
+	comment.EditedAt = s.editedTime(editedAt)
+	comment.Edited = comment.EditedAt != nil
+	comment.ParentCommentID = parentID.Int64
+	comment.ThreadID = threadID.Int64
+	comment.Deleted = deletedAt != nil
+	return comment, nil
+}
+
+// 查询多条评论
+func (s *SQLStore) queryComments(query string, args ...interface{}) ([]models.Comment, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []models.Comment
+	for rows.Next() {
+		comment, err := s.scanComment(rows)
+		if err != nil {
+			log.Printf("扫描评论数据失败: %v", err)
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+// ListComments 按顶层评论分页查询帖子的评论，每条顶层评论后面紧跟它的全部回复，未通过审核的评论不在其中
+func (s *SQLStore) ListComments(postID int64, page Page) ([]models.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments
+		WHERE post_id = ? AND parent_comment_id IS NULL AND status = 'approved'`
+	args := []interface{}{postID}
+	if page.After != nil {
+		query += ` AND (created_at > ? OR (created_at = ? AND id > ?))`
+		after := s.d.timeValue(page.After.Time)
+		args = append(args, after, after, page.After.ID)
+	}
+	query += ` ORDER BY created_at ASC, id ASC` + limitClause(page.Limit)
+
+	roots, err := s.queryComments(query, args...)
+	if err != nil || len(roots) == 0 {
+		return roots, err
+	}
+
+	// 查询这些讨论串中的所有回复
+	ids := make([]int64, len(roots))
+	for i, c := range roots {
+		ids[i] = c.ID
+	}
+	placeholders, idArgs := inPlaceholders(ids)
+	replies, err := s.queryComments(`
+		SELECT `+commentColumns+`
+		FROM comments
+		WHERE post_id = ? AND thread_id IN (`+placeholders+`) AND status = 'approved'
+		ORDER BY created_at ASC, id ASC
+	`, append([]interface{}{postID}, idArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	return orderThreads(roots, replies), nil
+}
+
+// GetComment 查询帖子下的单条评论
+func (s *SQLStore) GetComment(postID, commentID int64) (*models.Comment, error) {
+	comment, err := s.scanComment(s.queryRow(`
+		SELECT `+commentColumns+`
+		FROM comments
+		WHERE id = ? AND post_id = ?
+	`, commentID, postID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// 可为空的ID，0 写入为NULL
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// CreateComment 存储新评论，同时更新帖子的评论数和最后活动时间，并记录内容中的引用；audit 在同一事务中写入审计日志
+func (s *SQLStore) CreateComment(comment *models.Comment, audit []models.AuditEntry) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 已过期但还没被清理任务删除的帖子同样不能评论，否则评论会延长它的删除时间
+	var hidden, locked bool
+	err = tx.QueryRow(s.d.rebind("SELECT hidden_at IS NOT NULL OR status <> 'approved', locked_at IS NOT NULL FROM posts WHERE id = ? AND delete_at > ?"),
+		comment.PostID, s.d.timeValue(comment.CreatedAt)).Scan(&hidden, &locked)
+	if err == sql.ErrNoRows || hidden {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if locked {
+		return 0, ErrLocked
+	}
+
+	// 等待审核和被隐藏的评论不计入评论数和最后活动时间，以免从帖子列表看出它们的存在
+	createdAt := s.d.timeValue(comment.CreatedAt)
+	if reviewStatus(comment.Status) == models.StatusApproved && !comment.Hidden {
+		_, err = tx.Exec(s.d.rebind(`
+			UPDATE posts
+			SET comment_count = comment_count + 1,
+				last_activity_at = CASE WHEN last_activity_at IS NULL OR last_activity_at < ? THEN ? ELSE last_activity_at END
+			WHERE id = ?
+		`), createdAt, createdAt, comment.PostID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var id int64
+	err = tx.QueryRow(s.d.rebind(
+		`INSERT INTO comments (content, post_id, author, created_at, edit_token_hash, parent_comment_id, thread_id, depth, format, hidden_at, status, poster_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		comment.Content, comment.PostID, comment.Author, createdAt, nullString(comment.EditTokenHash),
+		nullID(comment.ParentCommentID), nullID(comment.ThreadID), comment.Depth, contentFormat(comment.Format),
+		s.hiddenAt(comment.Hidden, comment.CreatedAt), reviewStatus(comment.Status), comment.PosterID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.saveQuotes(tx, comment.PostID, id, comment.Content); err != nil {
+		return 0, err
+	}
+	for i := range audit {
+		audit[i].PostID, audit[i].CommentID = comment.PostID, id
+		if err := s.insertAudit(tx, &audit[i], comment.CreatedAt); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
+}
+
+// DeleteOldPosts 删除已过期的帖子（当前时间已经超过帖子的delete_at时间）
+func (s *SQLStore) DeleteOldPosts(now time.Time) (int64, error) {
+	// 开始事务
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 查找已过期的帖子ID：
+	// 当前时间已经超过帖子的delete_at时间
+	rows, err := tx.Query(s.d.rebind(`
+		SELECT p.id FROM posts p
+		WHERE p.delete_at < ?
+	`), s.d.timeValue(now))
+	if err != nil {
+		return 0, err
+	}
+
+	var inactivePostIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		inactivePostIDs = append(inactivePostIDs, id)
+	}
+	rows.Close()
+
+	if len(inactivePostIDs) == 0 {
+		// 没有不活跃的帖子需要删除
+		return 0, tx.Commit()
+	}
+
+	count, err := s.deletePosts(tx, inactivePostIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	// 提交事务
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// 返回删除的帖子数量
+	return count, nil
+}
+
+// deletePosts 在事务中删除帖子及其评论、修改历史、引用和附件记录，返回删除的帖子数量。
+// 附件的文件可能被其他附件共用，由清理任务在确认不再使用后删除
+func (s *SQLStore) deletePosts(tx *sql.Tx, ids []int64) (int64, error) {
+	placeholders, args := inPlaceholders(ids)
+
+	// 删除这些帖子及其评论的附件记录
+	_, err := tx.Exec(s.d.rebind("DELETE FROM attachments WHERE post_id IN ("+placeholders+")"), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// 删除这些帖子及其评论的修改历史
+	_, err = tx.Exec(s.d.rebind("DELETE FROM revisions WHERE post_id IN ("+placeholders+")"), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// 删除这些帖子及其评论收到的举报
+	_, err = tx.Exec(s.d.rebind("DELETE FROM reports WHERE post_id IN ("+placeholders+")"), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// 删除这些帖子中的引用，其他帖子对它们的引用保留，显示为失效链接
+	_, err = tx.Exec(s.d.rebind("DELETE FROM quote_refs WHERE post_id IN ("+placeholders+")"), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// 删除这些帖子的评论
+	_, err = tx.Exec(s.d.rebind("DELETE FROM comments WHERE post_id IN ("+placeholders+")"), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	// 删除帖子
+	result, err := tx.Exec(s.d.rebind("DELETE FROM posts WHERE id IN ("+placeholders+")"), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// UpdatePostDeleteTime 更新帖子的删除时间（基于最新评论或创建时间）
+func (s *SQLStore) UpdatePostDeleteTime(postID int64, daysToKeep int) error {
+	// 开始事务
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.extendDeleteTime(tx, postID, daysToKeep); err != nil {
+		return err
+	}
+
+	// 提交事务
+	return tx.Commit()
+}
+
+// extendDeleteTime 在事务中把帖子的删除时间推迟到最新活动时间之后 daysToKeep 天
+func (s *SQLStore) extendDeleteTime(tx *sql.Tx, postID int64, daysToKeep int) error {
+	// 查找该帖子的最新活动时间，没有公开的评论时使用帖子的创建时间；
+	// 等待审核和被隐藏的评论不能让帖子一直保留
+	var latest interface{}
+	err := tx.QueryRow(s.d.rebind(`
+		SELECT COALESCE((SELECT MAX(c.created_at) FROM comments c WHERE c.post_id = ? AND `+countedCommentWhere+`), created_at)
+		FROM posts
+		WHERE id = ?
+	`), postID, postID).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	latestActivityTime, err := s.d.parseTime(latest)
+	if err != nil {
+		return err
+	}
+
+	// 计算新的删除时间（最新活动时间 + daysToKeep天）
+	newDeleteTime := latestActivityTime.AddDate(0, 0, daysToKeep)
+
+	// 更新帖子的delete_at字段，管理员设置的更晚的删除时间保留
+	_, err = tx.Exec(s.d.rebind(`
+		UPDATE posts
+		SET delete_at = ?
+		WHERE id = ? AND delete_at < ?
+	`), s.d.timeValue(newDeleteTime), postID, s.d.timeValue(newDeleteTime))
+	return err
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteTimeLayout SQLite中时间统一以UTC存储，格式与CURRENT_TIMESTAMP一致
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// sqliteDialect SQLite中时间以UTC字符串形式存储
+type sqliteDialect struct{}
+
+func (sqliteDialect) name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) timeValue(t time.Time) interface{} {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func (sqliteDialect) likeOperator() string {
+	// SQLite的LIKE对ASCII字符不区分大小写
+	return "LIKE"
+}
+
+func (sqliteDialect) prepare(s *SQLStore) error {
+	return s.ensureSQLiteSearchIndex()
+}
+
+func (sqliteDialect) checkpoint(db *sql.DB) error {
+	// 使用WAL日志时把WAL写回数据库文件并清空，非WAL模式下不做任何事
+	_, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
+
+func (sqliteDialect) parseTime(v interface{}) (time.Time, error) {
+	switch tv := v.(type) {
+	case time.Time:
+		// 驱动会把TIMESTAMP列解析为UTC时间
+		return tv.UTC(), nil
+	case string:
+		return parseSQLiteTimeString(tv)
+	case []byte:
+		return parseSQLiteTimeString(string(tv))
+	}
+	return time.Time{}, fmt.Errorf("无法解析的时间值: %v", v)
+}
+
+func parseSQLiteTimeString(s string) (time.Time, error) {
+	if t, err := time.Parse(sqliteTimeLayout, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t.UTC(), err
+}
+
+// NewSQLiteStore 打开SQLite数据库并执行尚未执行的迁移
+func NewSQLiteStore(dbPath string) (*SQLStore, error) {
+	s, err := openSQLite(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Migrate(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// 打开SQLite数据库，不执行迁移
+func openSQLite(dbPath string) (*SQLStore, error) {
+	// 确保数据目录存在
+	dbDir := filepath.Dir(dbPath)
+	if _, err := os.Stat(dbDir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dbDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 测试连接
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Println("成功连接到SQLite数据库")
+	return &SQLStore{db: db, d: sqliteDialect{}}, nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Mammoth777/nilbbs/models"
+)
+
+// SQLite的全文搜索使用FTS5的trigram分词器：按三个字符切分，不依赖空格分词，中文也能按子串匹配。
+// search_index 的rowid由来源决定：帖子为 id*2，评论为 id*2+1，便于触发器按rowid删除。
+// FTS5需要以 sqlite_fts5 构建标签编译，未启用时搜索退回到LIKE查询。
+
+// 同步 search_index 的触发器，帖子和评论被删除（包括过期清理）时索引同时删除
+var sqliteSearchTriggers = []struct {
+	name string
+	sql  string
+}{
+	{"search_index_posts_ai", `CREATE TRIGGER search_index_posts_ai AFTER INSERT ON posts BEGIN
+		INSERT INTO search_index (rowid, content, post_id, comment_id) VALUES (new.id * 2, new.content, new.id, 0);
+	END`},
+	{"search_index_posts_au", `CREATE TRIGGER search_index_posts_au AFTER UPDATE OF content ON posts BEGIN
+		DELETE FROM search_index WHERE rowid = old.id * 2;
+		INSERT INTO search_index (rowid, content, post_id, comment_id) VALUES (new.id * 2, new.content, new.id, 0);
+	END`},
+	{"search_index_posts_ad", `CREATE TRIGGER search_index_posts_ad AFTER DELETE ON posts BEGIN
+		DELETE FROM search_index WHERE rowid = old.id * 2;
+	END`},
+	{"search_index_comments_ai", `CREATE TRIGGER search_index_comments_ai AFTER INSERT ON comments BEGIN
+		INSERT INTO search_index (rowid, content, post_id, comment_id) VALUES (new.id * 2 + 1, new.content, new.post_id, new.id);
+	END`},
+	{"search_index_comments_au", `CREATE TRIGGER search_index_comments_au AFTER UPDATE OF content ON comments BEGIN
+		DELETE FROM search_index WHERE rowid = old.id * 2 + 1;
+		INSERT INTO search_index (rowid, content, post_id, comment_id) VALUES (new.id * 2 + 1, new.content, new.post_id, new.id);
+	END`},
+	{"search_index_comments_ad", `CREATE TRIGGER search_index_comments_ad AFTER DELETE ON comments BEGIN
+		DELETE FROM search_index WHERE rowid = old.id * 2 + 1;
+	END`},
+}
+
+// 检查并建立全文索引。索引不是版本化迁移的一部分，因为它取决于程序编译时是否启用了FTS5：
+// 未启用时删除触发器，避免写入帖子时访问无法加载的虚拟表；启用后如果触发器缺失则重建整个索引。
+func (s *SQLStore) ensureSQLiteSearchIndex() error {
+	var enabled bool
+	if err := s.db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return err
+	}
+
+	if !enabled {
+		for _, trigger := range sqliteSearchTriggers {
+			if _, err := s.db.Exec("DROP TRIGGER IF EXISTS " + trigger.name); err != nil {
+				return err
+			}
+		}
+		log.Println("SQLite未启用FTS5，全文搜索使用LIKE查询")
+		s.fts = false
+		return nil
+	}
+
+	var triggers int
+	err := s.db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'search_index_%'").Scan(&triggers)
+	if err != nil {
+		return err
+	}
+	if triggers != len(sqliteSearchTriggers) {
+		if err := s.rebuildSQLiteSearchIndex(); err != nil {
+			return err
+		}
+		log.Println("全文索引已重建")
+	}
+	s.fts = true
+	return nil
+}
+
+// 重新创建全文索引及其触发器，并导入现有的帖子和评论
+func (s *SQLStore) rebuildSQLiteSearchIndex() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+			content, post_id UNINDEXED, comment_id UNINDEXED, tokenize = 'trigram'
+		)`,
+		`DELETE FROM search_index`,
+		`INSERT INTO search_index (rowid, content, post_id, comment_id) SELECT id * 2, content, id, 0 FROM posts`,
+		`INSERT INTO search_index (rowid, content, post_id, comment_id) SELECT id * 2 + 1, content, post_id, id FROM comments`,
+	}
+	for _, trigger := range sqliteSearchTriggers {
+		stmts = append(stmts, "DROP TRIGGER IF EXISTS "+trigger.name, trigger.sql)
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 使用FTS5搜索。trigram分词器只能匹配至少三个字符的关键词，较短的关键词改用LIKE过滤
+func (s *SQLStore) searchFTS(now time.Time, terms []string, limit int) ([]models.SearchResult, error) {
+	var phrases []string
+	var likeConds string
+	var likeArgs []interface{}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= 3 {
+			phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+		} else {
+			likeConds += ` AND search_index.content LIKE ? ESCAPE '\'`
+			likeArgs = append(likeArgs, likePattern(term))
+		}
+	}
+
+	query := `
+		SELECT search_index.post_id, search_index.comment_id, search_index.content,
+			COALESCE(c.author, p.author), COALESCE(c.created_at, p.created_at) AS created_at
+		FROM search_index
+		JOIN posts p ON p.id = search_index.post_id
+		LEFT JOIN comments c ON search_index.comment_id > 0 AND c.id = search_index.comment_id
+		WHERE p.delete_at > ? AND p.hidden_at IS NULL AND p.status = 'approved'
+			AND (c.id IS NULL OR (c.hidden_at IS NULL AND c.status = 'approved'))`
+	args := []interface{}{s.d.timeValue(now)}
+	order := ` ORDER BY created_at DESC`
+	if len(phrases) > 0 {
+		query += ` AND search_index MATCH ?`
+		args = append(args, strings.Join(phrases, " AND "))
+		order = ` ORDER BY search_index.rank, created_at DESC`
+	}
+	query += likeConds + order + limitClause(limit)
+	return s.scanSearchResults(query, append(args, likeArgs...)...)
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"crypto/subtle"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/markup"
+	"github.com/Mammoth777/nilbbs/models"
+)
+
+// ErrNotFound 表示请求的记录不存在或已过期
+var ErrNotFound = errors.New("记录不存在")
+
+// ErrForbidden 表示编辑令牌与记录不符，或记录没有编辑令牌
+var ErrForbidden = errors.New("编辑令牌无效")
+
+// ErrAttachmentLimit 表示帖子或评论的附件数量已达上限
+var ErrAttachmentLimit = errors.New("附件数量已达上限")
+
+// ErrLocked 表示帖子已被管理员锁定，不能再评论
+var ErrLocked = errors.New("帖子已锁定")
+
+// ErrAlreadyReported 表示同一客户端已经举报过该帖子或评论
+var ErrAlreadyReported = errors.New("已经举报过")
+
+// ErrNotPending 表示审核的内容不在审核队列中：只能通过等待审核或已被拒绝的内容，只能拒绝等待审核的内容
+var ErrNotPending = errors.New("内容不在审核队列中")
+
+// PosterIDFunc 由新帖子的ID计算发帖人ID，发帖人ID由帖子ID计算，帖子保存后才能确定
+type PosterIDFunc func(postID int64) (string, error)
+
+// Store 帖子和评论的存储接口，处理器只通过它访问数据。
+// 除非特别说明，“隐藏的”内容也包括未通过审核（等待审核或被拒绝）的内容
+type Store interface {
+	// CreatePost 保存新帖子（包含创建时间和删除时间），返回帖子ID；Hidden 为 true 时帖子创建后即隐藏，
+	// Status 为空时视为已通过审核。posterID 不为空时用新帖子的ID计算发帖人ID，在同一事务中保存，
+	// 计算失败时不保存帖子；为空时使用 post.PosterID。audit 是新帖子已经按其保存的管理操作（如命中过滤规则），
+	// 填入帖子ID后在同一事务中写入审计日志
+	CreatePost(post *models.Post, posterID PosterIDFunc, audit []models.AuditEntry) (int64, error)
+	// ListPosts 获取在 now 时刻尚未过期的帖子，按指定方式排序分页；隐藏和置顶的帖子不在其中
+	ListPosts(now time.Time, sort PostSort, page Page) ([]models.Post, error)
+	// ListPinnedPosts 获取在 now 时刻尚未过期的置顶帖子，后置顶的在前，隐藏的帖子不在其中
+	ListPinnedPosts(now time.Time) ([]models.Post, error)
+	// GetPost 获取在 now 时刻尚未过期的单个帖子，不存在或被隐藏时返回 ErrNotFound
+	GetPost(id int64, now time.Time) (*models.Post, error)
+	// ListComments 获取帖子的评论，按顶层评论的创建时间正序分页，Limit 只限制顶层评论的数量；
+	// 每条顶层评论后面紧跟它的全部回复，回复按深度优先排列，同一层按创建时间正序
+	ListComments(postID int64, page Page) ([]models.Comment, error)
+	// GetComment 获取帖子下的单条评论，评论不存在或不属于该帖子时返回 ErrNotFound
+	GetComment(postID, commentID int64) (*models.Comment, error)
+	// CreateComment 保存新评论（包含回复关系和层级）并更新帖子的评论数和最后活动时间，返回评论ID；
+	// Hidden 和 Status 与 CreatePost 相同，评论数和最后活动时间只统计公开的评论。帖子不存在、已过期或被隐藏时返回 ErrNotFound，被锁定时返回 ErrLocked
+	// audit 与 CreatePost 相同，填入帖子和评论的ID
+	CreateComment(comment *models.Comment, audit []models.AuditEntry) (int64, error)
+	// UpdatePostDeleteTime 根据最新的公开评论或创建时间重新计算帖子的删除时间，只会推迟，不会提前管理员设置的删除时间
+	UpdatePostDeleteTime(postID int64, daysToKeep int) error
+	// Search 在 now 时刻尚未过期的帖子和评论中搜索同时包含所有关键词的内容
+	Search(now time.Time, terms []string, limit int) ([]models.SearchResult, error)
+	// UpdatePost 修改在 now 时刻尚未过期的帖子的内容并记录修改历史，tokenHash 与帖子的编辑令牌不符时返回 ErrForbidden。
+	// review 中的管理操作（如放回审核队列、悄悄隐藏）与修改在同一事务中执行并记录审计日志，修改后的内容不会先公开
+	UpdatePost(id int64, content, tokenHash string, review []models.AuditEntry, now time.Time) error
+	// DeletePost 删除在 now 时刻尚未过期的帖子及其评论和附件记录，tokenHash 与帖子的编辑令牌不符时返回 ErrForbidden
+	DeletePost(id int64, tokenHash string, now time.Time) error
+	// UpdateComment 修改未过期帖子下的评论内容并记录修改历史，tokenHash 与评论的编辑令牌不符时返回 ErrForbidden；
+	// review 与 UpdatePost 相同
+	UpdateComment(postID, commentID int64, content, tokenHash string, review []models.AuditEntry, now time.Time) error
+	// DeleteComment 删除未过期帖子下的评论及其附件记录并更新帖子的评论数，有回复的评论保留为内容为空的占位；
+	// tokenHash 与评论的编辑令牌不符时返回 ErrForbidden
+	DeleteComment(postID, commentID int64, tokenHash string, now time.Time) error
+	// ListRevisions 获取在 now 时刻尚未过期的帖子及其评论的修改历史，按修改时间正序，不包括隐藏的评论；
+	// 帖子不存在或被隐藏时返回 ErrNotFound
+	ListRevisions(postID int64, now time.Time) ([]models.Revision, error)
+	// ResolveQuotes 查询被引用的评论和帖子中在 now 时刻仍然存在、未过期且未被隐藏的部分
+	ResolveQuotes(commentIDs, postIDs []int64, now time.Time) (QuoteTargets, error)
+	// ListBacklinks 查询引用了这些评论、且在 now 时刻未过期也未被隐藏的帖子正文和评论，键为被引用的评论ID
+	ListBacklinks(commentIDs []int64, now time.Time) (map[int64][]models.Backlink, error)
+	// CreateAttachment 为未过期的帖子（CommentID 为 0）或其下的评论保存附件，返回附件ID；
+	// tokenHash 与帖子或评论的编辑令牌不符时返回 ErrForbidden，已有 limit 个附件时返回 ErrAttachmentLimit
+	CreateAttachment(a *models.Attachment, tokenHash string, limit int, now time.Time) (int64, error)
+	// ListAttachments 获取帖子及其评论的全部附件，按上传顺序
+	ListAttachments(postID int64) ([]models.Attachment, error)
+	// GetAttachment 获取在 now 时刻尚未过期的帖子下的附件，不存在或所属的帖子、评论被隐藏时返回 ErrNotFound
+	GetAttachment(id int64, now time.Time) (*models.Attachment, error)
+	// BlobKeys 返回所有附件引用的文件和缩略图的键，用于清理不再使用的文件
+	BlobKeys() (map[string]bool, error)
+	// Moderate 对未过期的帖子或其下的评论执行管理操作，并在同一事务中记录审计日志，填入日志的ID和时间；
+	// 通过审核的评论与新评论一样推迟帖子的删除时间；
+	// 帖子或评论不存在、已过期或已删除时返回 ErrNotFound，审核的内容不在审核队列中时返回 ErrNotPending
+	Moderate(entry *models.AuditEntry, now time.Time) error
+	// GetOwnPost 供作者查看自己的帖子，被隐藏或未通过审核的帖子也会返回；
+	// 帖子不存在或已过期时返回 ErrNotFound，tokenHash 与编辑令牌不符时返回 ErrForbidden
+	GetOwnPost(id int64, tokenHash string, now time.Time) (*models.Post, error)
+	// GetOwnComment 供作者查看自己在未过期帖子下未删除的评论，其他同 GetOwnPost
+	GetOwnComment(postID, commentID int64, tokenHash string, now time.Time) (*models.Comment, error)
+	// ListPending 获取未过期的帖子和评论中等待审核的部分，按创建时间正序，最多 limit 项（0 表示不限制）
+	ListPending(now time.Time, limit int) ([]models.PendingItem, error)
+	// ListAuditLog 获取审计日志，按操作时间倒序分页
+	ListAuditLog(page Page) ([]models.AuditEntry, error)
+	// CreateReport 保存对未过期、未隐藏的帖子（CommentID 为 0）或其下未删除、未隐藏的评论的举报，
+	// 同一举报者重复举报时返回 ErrAlreadyReported。举报次数达到 threshold（大于 0）时自动隐藏内容，
+	// 在同一事务中以 models.ActorAuto 记录审计日志，并返回 true
+	CreateReport(r *models.Report, threshold int, now time.Time) (bool, error)
+	// ListReports 获取未过期的帖子和未删除的评论收到的举报，按举报次数倒序，最多 limit 项（0 表示不限制）
+	ListReports(now time.Time, limit int) ([]models.ReportSummary, error)
+	// DeleteOldPosts 删除在 now 时刻已过期的帖子及其评论、修改历史、附件记录和举报，返回删除的帖子数量；审计日志保留
+	DeleteOldPosts(now time.Time) (int64, error)
+	// Close 关闭存储
+	Close() error
+}
+
+// QuoteTargets 引用目标的查询结果，不在其中的目标视为已删除或已过期
+type QuoteTargets struct {
+	// Comments 评论所在的帖子，键为评论ID
+	Comments map[int64]int64
+	// Posts 存在的帖子
+	Posts map[int64]bool
+}
+
+// contentFormat 未指定格式的内容按纯文本保存
+func contentFormat(format string) string {
+	if format == "" {
+		return markup.FormatPlain
+	}
+	return format
+}
+
+// reviewStatus 未指定审核状态的内容视为已通过审核
+func reviewStatus(status string) string {
+	if status == "" {
+		return models.StatusApproved
+	}
+	return status
+}
+
+// tokenMatches 判断请求中的令牌哈希是否与保存的哈希一致，没有保存令牌的记录不能修改
+func tokenMatches(stored, given string) bool {
+	return stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+}
+
+// PostSort 帖子列表的排序方式
+type PostSort string
+
+const (
+	// SortCreated 按创建时间倒序
+	SortCreated PostSort = "created"
+	// SortActivity 按最后活动时间（最新评论或创建时间）倒序
+	SortActivity PostSort = "activity"
+	// SortExpiring 按删除时间正序，即将过期的在前
+	SortExpiring PostSort = "expiring"
+)
+
+// ParsePostSort 解析排序方式，空字符串为默认的 SortCreated
+func ParsePostSort(s string) (PostSort, bool) {
+	switch PostSort(s) {
+	case "":
+		return SortCreated, true
+	case SortCreated, SortActivity, SortExpiring:
+		return PostSort(s), true
+	}
+	return "", false
+}
+
+// 排序使用的列，以及是否倒序
+func (s PostSort) column() (string, bool) {
+	switch s {
+	case SortActivity:
+		return "last_activity_at", true
+	case SortExpiring:
+		return "delete_at", false
+	}
+	return "created_at", true
+}
+
+// sortKey 帖子在该排序方式下的排序时间
+func (s PostSort) sortKey(p *models.Post) time.Time {
+	switch s {
+	case SortActivity:
+		return p.LastActivityAt
+	case SortExpiring:
+		return p.DeleteAt
+	}
+	return p.CreatedAt
+}
+
+// CursorAfter 返回指向该帖子之后的分页游标
+func (s PostSort) CursorAfter(p *models.Post) Cursor {
+	return Cursor{Time: s.sortKey(p), ID: p.ID}
+}
+
+// Cursor 分页游标，记录上一页最后一条记录的排序时间和ID
+type Cursor struct {
+	Time time.Time
+	ID   int64
+}
+
+// Page 游标分页参数。按 (时间, ID) 做键集分页，新增记录不会导致翻页时重复或遗漏
+type Page struct {
+	// Limit 最多返回的条数，0 表示不限制
+	Limit int
+	// After 只返回排在该游标之后的记录，nil 表示从第一条开始
+	After *Cursor
+}
+
+// DefaultSQLitePath 未配置DSN时使用的SQLite数据库文件
+var DefaultSQLitePath = filepath.Join("./data", "nilbbs.db")
+
+// Open 根据DSN选择存储实现并执行尚未执行的迁移，DSN格式见 OpenSQL
+func Open(dsn string) (Store, error) {
+	s, err := OpenSQL(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Migrate(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSQL 根据DSN打开数据库但不执行迁移：postgres:// 或 postgresql:// 使用PostgreSQL，
+// 其余视为SQLite数据库文件路径，为空时使用 DefaultSQLitePath
+func OpenSQL(dsn string) (*SQLStore, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return openPostgres(dsn)
+	}
+	if dsn == "" {
+		dsn = DefaultSQLitePath
+	}
+	return openSQLite(dsn)
+}
@@ -0,0 +1,37 @@
+package database
+
+import "github.com/Mammoth777/nilbbs/models"
+
+// orderThreads 把顶层评论和它们的回复排成深度优先的顺序：每条评论后面紧跟它的回复，
+// 同一层按创建时间正序。replies 需已按创建时间正序排列，父评论不在结果中的回复挂到所在讨论串的顶层评论下
+func orderThreads(roots, replies []models.Comment) []models.Comment {
+	present := make(map[int64]bool, len(roots)+len(replies))
+	for _, c := range roots {
+		present[c.ID] = true
+	}
+	for _, c := range replies {
+		present[c.ID] = true
+	}
+
+	children := make(map[int64][]models.Comment)
+	for _, c := range replies {
+		parent := c.ParentCommentID
+		if !present[parent] {
+			parent = c.ThreadID
+		}
+		children[parent] = append(children[parent], c)
+	}
+
+	result := make([]models.Comment, 0, len(roots)+len(replies))
+	var walk func(c models.Comment)
+	walk = func(c models.Comment) {
+		result = append(result, c)
+		for _, child := range children[c.ID] {
+			walk(child)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Mammoth777/nilbbs/assets"
+	"github.com/Mammoth777/nilbbs/nickname"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 内嵌的页面模板和静态文件，运行时不再依赖工作目录下的文件
+//
+//go:embed templates static
+var embeddedFiles embed.FS
+
+// 加载页面模板、静态文件和昵称词库，覆盖目录中的同名文件优先于内嵌文件
+func setupAssets(r *gin.Engine, overrideDir string) error {
+	files := utils.WithOverride(overrideDir, embeddedFiles)
+
+	tmpl, err := template.ParseFS(files, "templates/*")
+	if err != nil {
+		return err
+	}
+	r.SetHTMLTemplate(tmpl)
+
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		return err
+	}
+	r.StaticFS("/static", filesOnly{http.FS(static)})
+
+	if overrideDir != "" {
+		return nickname.Load(nicknameDatasets(overrideDir))
+	}
+	return nil
+}
+
+// nicknameDatasets 覆盖目录中的 assets/dataset 优先于内嵌的词库
+func nicknameDatasets(overrideDir string) fs.FS {
+	return utils.WithOverride(filepath.Join(overrideDir, "assets"), assets.Dataset)
+}
+
+// filesOnly 不允许列出目录，与 r.Static 的行为保持一致
+type filesOnly struct {
+	http.FileSystem
+}
+
+func (f filesOnly) Open(name string) (http.File, error) {
+	file, err := f.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := file.Stat(); err != nil || info.IsDir() {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}
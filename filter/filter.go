@@ -0,0 +1,323 @@
+// Package filter 实现发帖和评论前的内容过滤规则。
+//
+// 规则从 TOML 或 YAML 文件加载，每条规则按字面、正则或归一化文本匹配内容，命中后执行一个动作：
+// 拒绝发布、替换命中的文本、隐藏等待审核，或者悄悄隐藏（发布者看到的响应与正常发布相同）。
+// 归一化匹配先把全角字符等兼容字符转换为标准形式、转为小写，并去掉零宽字符等不可见的格式字符，
+// 用来识别全角的“ｂａｄ”或中间插入零宽空格的“b\u200bad”之类的变体。
+// 规则可以在运行中重新加载，命中次数按规则和动作计数。
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 匹配方式
+const (
+	// MatchLiteral 不区分大小写的字面匹配
+	MatchLiteral = "literal"
+	// MatchRegex 正则表达式匹配，语法为 Go 的 regexp（RE2）
+	MatchRegex = "regex"
+	// MatchNormalized 对内容和规则都做 Unicode 归一化后字面匹配
+	MatchNormalized = "normalized"
+)
+
+// 命中后的动作，按严重程度从低到高排列
+const (
+	// ActionReplace 把命中的文本替换为 Replacement
+	ActionReplace = "replace"
+	// ActionShadowHide 正常发布但隐藏，发布者不会得到提示
+	ActionShadowHide = "shadow_hide"
+	// ActionHold 放入审核队列并提示发布者等待管理员审核
+	ActionHold = "hold"
+	// ActionReject 拒绝发布
+	ActionReject = "reject"
+)
+
+// DefaultReplacement 替换规则未指定替换文本时使用
+const DefaultReplacement = "***"
+
+// 动作的严重程度，多条规则命中时执行最严重的动作
+var severity = map[string]int{
+	ActionReplace:    1,
+	ActionShadowHide: 2,
+	ActionHold:       3,
+	ActionReject:     4,
+}
+
+// Rule 规则文件中的一条规则
+type Rule struct {
+	// Name 规则名，用于日志和命中统计，为空时使用规则的序号
+	Name        string `toml:"name" yaml:"name"`
+	Match       string `toml:"match" yaml:"match"`
+	Pattern     string `toml:"pattern" yaml:"pattern"`
+	Action      string `toml:"action" yaml:"action"`
+	Replacement string `toml:"replacement" yaml:"replacement"`
+}
+
+// ruleFile 规则文件的结构
+type ruleFile struct {
+	Rules []Rule `toml:"rules" yaml:"rules"`
+}
+
+// compiledRule 编译后的规则
+type compiledRule struct {
+	Rule
+	re      *regexp.Regexp
+	literal string
+}
+
+// Filter 一组编译后的规则，可以并发使用
+type Filter struct {
+	rules []compiledRule
+}
+
+// Hit 命中的一条规则
+type Hit struct {
+	Rule   string
+	Action string
+}
+
+// Result 检查内容的结果
+type Result struct {
+	// Action 命中规则中最严重的动作，没有命中时为空
+	Action string
+	// Content 执行替换后的内容，没有替换规则命中时与原内容相同
+	Content string
+	// Hits 命中的规则，按规则顺序
+	Hits []Hit
+}
+
+// RuleNames 返回命中的规则名，用逗号分隔
+func (r Result) RuleNames() string {
+	names := make([]string, len(r.Hits))
+	for i, h := range r.Hits {
+		names[i] = h.Rule
+	}
+	return strings.Join(names, ", ")
+}
+
+// Hidden 判断内容是否需要隐藏
+func (r Result) Hidden() bool {
+	return r.Action == ActionHold || r.Action == ActionShadowHide
+}
+
+// New 编译规则，规则无效时返回错误
+func New(rules []Rule) (*Filter, error) {
+	f := &Filter{}
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("规则 %s: pattern 不能为空", r.Name)
+		}
+		if _, ok := severity[r.Action]; !ok {
+			return nil, fmt.Errorf("规则 %s: 无效的动作 %q", r.Name, r.Action)
+		}
+		if r.Action == ActionReplace && r.Replacement == "" {
+			r.Replacement = DefaultReplacement
+		}
+
+		c := compiledRule{Rule: r}
+		switch r.Match {
+		case MatchLiteral, "":
+			c.Match = MatchLiteral
+			c.re = regexp.MustCompile("(?i)" + regexp.QuoteMeta(r.Pattern))
+		case MatchRegex:
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s: 无效的正则表达式: %w", r.Name, err)
+			}
+			c.re = re
+		case MatchNormalized:
+			c.literal, _ = normalize(r.Pattern)
+			if c.literal == "" {
+				return nil, fmt.Errorf("规则 %s: 归一化后 pattern 为空", r.Name)
+			}
+		default:
+			return nil, fmt.Errorf("规则 %s: 无效的匹配方式 %q", r.Name, r.Match)
+		}
+		f.rules = append(f.rules, c)
+	}
+	return f, nil
+}
+
+// Parse 解析规则文件的内容，name 的扩展名决定格式：.toml、.yaml 或 .yml
+func Parse(name string, data []byte) (*Filter, error) {
+	var file ruleFile
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &file)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		return nil, fmt.Errorf("不支持的规则文件格式: %s，请使用 .toml、.yaml 或 .yml", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析规则文件 %s 失败: %w", name, err)
+	}
+	return New(file.Rules)
+}
+
+// LoadFile 读取并编译规则文件
+func LoadFile(path string) (*Filter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取规则文件失败: %w", err)
+	}
+	return Parse(path, data)
+}
+
+// Len 返回规则数量
+func (f *Filter) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.rules)
+}
+
+// span 内容中命中替换规则的字节范围
+type span struct {
+	start, end  int
+	replacement string
+}
+
+// Check 用全部规则检查内容
+func (f *Filter) Check(content string) Result {
+	result := Result{Content: content}
+	if f.Len() == 0 {
+		return result
+	}
+
+	// 只在有归一化规则时归一化内容，且只做一次
+	var normalized string
+	var offsets []byteRange
+	normalizedDone := false
+	var spans []span
+	for _, r := range f.rules {
+		var matches [][]int
+		if r.re != nil {
+			matches = r.re.FindAllStringIndex(content, -1)
+		} else {
+			if !normalizedDone {
+				normalized, offsets = normalize(content)
+				normalizedDone = true
+			}
+			matches = findNormalized(normalized, offsets, r.literal)
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		result.Hits = append(result.Hits, Hit{Rule: r.Name, Action: r.Action})
+		if severity[r.Action] > severity[result.Action] {
+			result.Action = r.Action
+		}
+		if r.Action == ActionReplace {
+			for _, m := range matches {
+				if m[1] > m[0] {
+					spans = append(spans, span{m[0], m[1], r.Replacement})
+				}
+			}
+		}
+	}
+	result.Content = replaceSpans(content, spans)
+	return result
+}
+
+// findNormalized 在归一化的文本中查找 literal，返回命中部分在原内容中的字节范围
+func findNormalized(normalized string, offsets []byteRange, literal string) [][]int {
+	var matches [][]int
+	for from := 0; ; {
+		i := strings.Index(normalized[from:], literal)
+		if i < 0 {
+			return matches
+		}
+		start := from + i
+		end := start + len(literal)
+		matches = append(matches, []int{offsets[start].start, offsets[end-1].end})
+		from = end
+	}
+}
+
+// replaceSpans 替换命中的范围，重叠的范围合并后使用先出现的替换文本
+func replaceSpans(content string, spans []span) string {
+	if len(spans) == 0 {
+		return content
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	pos := 0
+	for i := 0; i < len(spans); {
+		cur := spans[i]
+		for i++; i < len(spans) && spans[i].start < cur.end; i++ {
+			cur.end = max(cur.end, spans[i].end)
+		}
+		b.WriteString(content[pos:cur.start])
+		b.WriteString(cur.replacement)
+		pos = cur.end
+	}
+	b.WriteString(content[pos:])
+	return b.String()
+}
+
+// current 当前生效的规则，为空时不过滤
+var current atomic.Pointer[Filter]
+
+// ErrNoRules 规则文件中没有任何规则，通常是写错了顶层的 rules 键
+var ErrNoRules = errors.New("规则文件中没有规则")
+
+// Open 读取配置中的规则文件，path 为空时返回 nil，表示不过滤
+func Open(path string) (*Filter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if f.Len() == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoRules, path)
+	}
+	return f, nil
+}
+
+// Use 替换当前生效的规则，f 为 nil 时不过滤
+func Use(f *Filter) {
+	current.Store(f)
+}
+
+// Load 读取规则文件并替换当前规则，path 为空时清空规则；读取失败时保留当前规则
+func Load(path string) error {
+	f, err := Open(path)
+	if err != nil {
+		return err
+	}
+	Use(f)
+	return nil
+}
+
+// Current 返回当前生效的规则
+func Current() *Filter {
+	return current.Load()
+}
+
+// Check 用当前生效的规则检查内容，并记录命中次数
+func Check(content string) Result {
+	f := current.Load()
+	result := f.Check(content)
+	recordHits(result.Hits)
+	return result
+}
@@ -0,0 +1,71 @@
+package filter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// hits 按规则名和动作统计的命中次数，重新加载规则后继续累计
+var hits = struct {
+	sync.Mutex
+	counts map[Hit]uint64
+}{counts: make(map[Hit]uint64)}
+
+// recordHits 记录一次检查中命中的规则
+func recordHits(list []Hit) {
+	if len(list) == 0 {
+		return
+	}
+	hits.Lock()
+	defer hits.Unlock()
+	for _, h := range list {
+		hits.counts[h]++
+	}
+}
+
+// HitCounts 返回命中次数的快照
+func HitCounts() map[Hit]uint64 {
+	hits.Lock()
+	defer hits.Unlock()
+	counts := make(map[Hit]uint64, len(hits.counts))
+	for h, n := range hits.counts {
+		counts[h] = n
+	}
+	return counts
+}
+
+// WriteMetrics 以 Prometheus 文本格式输出规则数量和命中次数
+func WriteMetrics(w io.Writer) error {
+	counts := HitCounts()
+	keys := make([]Hit, 0, len(counts))
+	for h := range counts {
+		keys = append(keys, h)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Rule != keys[j].Rule {
+			return keys[i].Rule < keys[j].Rule
+		}
+		return keys[i].Action < keys[j].Action
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP nilbbs_filter_rules 当前生效的内容过滤规则数量\n")
+	b.WriteString("# TYPE nilbbs_filter_rules gauge\n")
+	fmt.Fprintf(&b, "nilbbs_filter_rules %d\n", Current().Len())
+	b.WriteString("# HELP nilbbs_filter_hits_total 内容过滤规则的命中次数\n")
+	b.WriteString("# TYPE nilbbs_filter_hits_total counter\n")
+	for _, h := range keys {
+		fmt.Fprintf(&b, "nilbbs_filter_hits_total{rule=\"%s\",action=\"%s\"} %d\n",
+			escapeLabel(h.Rule), escapeLabel(h.Action), counts[h])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabel 转义 Prometheus 标签值中的反斜杠、引号和换行
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
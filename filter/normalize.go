@@ -0,0 +1,39 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// byteRange 原文中的字节范围
+type byteRange struct {
+	start, end int
+}
+
+// ignorable 判断字符是否在归一化时去掉：零宽空格、零宽连接符、软连字符、方向控制符等格式字符，
+// 以及变体选择符和组合字形连接符。它们不可见，常被插在词语中间躲避过滤
+func ignorable(r rune) bool {
+	return unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Variation_Selector, r) || r == '\u034f'
+}
+
+// normalize 逐字符做 NFKC 归一化（全角字母数字、圈字符等转换为标准形式）并转为小写，去掉不可见的字符。
+// 同时返回结果中每个字节对应的原文字节范围，用于把命中位置映射回原文
+func normalize(s string) (string, []byteRange) {
+	var b strings.Builder
+	var offsets []byteRange
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !ignorable(r) {
+			n := strings.ToLower(norm.NFKC.String(s[i : i+size]))
+			b.WriteString(n)
+			for range len(n) {
+				offsets = append(offsets, byteRange{i, i + size})
+			}
+		}
+		i += size
+	}
+	return b.String(), offsets
+}
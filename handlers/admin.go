@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AdminSessionCookie 管理员会话的 Cookie 名称
+const AdminSessionCookie = "nilbbs_admin"
+
+// AdminSessionTTL 管理员会话的有效期
+const AdminSessionTTL = 12 * time.Hour
+
+// 请求上下文中执行操作的管理员，写入审计日志
+const adminActorKey = "admin_actor"
+
+// adminSession 一个登录会话，credentials 为登录时管理凭据的摘要，凭据修改后会话失效
+type adminSession struct {
+	expiresAt   time.Time
+	credentials [sha256.Size]byte
+}
+
+// AdminSessions 保存在内存中的管理员会话，只保存会话令牌的哈希；重启后需要重新登录
+type AdminSessions struct {
+	mu       sync.Mutex
+	sessions map[string]adminSession
+}
+
+// NewAdminSessions 创建空的会话表
+func NewAdminSessions() *AdminSessions {
+	return &AdminSessions{sessions: make(map[string]adminSession)}
+}
+
+// adminEnabled 判断是否配置了管理令牌或管理密码
+func adminEnabled(cfg utils.AppConfig) bool {
+	return cfg.AdminToken != "" || cfg.AdminPasswordHash != ""
+}
+
+// adminCredentials 当前管理凭据的摘要
+func adminCredentials(cfg utils.AppConfig) [sha256.Size]byte {
+	return sha256.Sum256([]byte(cfg.AdminToken + "\n" + cfg.AdminPasswordHash))
+}
+
+// checkAdminPassword 登录密码可以是管理密码，也可以是管理令牌
+func checkAdminPassword(cfg utils.AppConfig, password string) bool {
+	if password == "" {
+		return false
+	}
+	if cfg.AdminPasswordHash != "" &&
+		bcrypt.CompareHashAndPassword([]byte(cfg.AdminPasswordHash), []byte(password)) == nil {
+		return true
+	}
+	return cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(password), []byte(cfg.AdminToken)) == 1
+}
+
+// create 创建会话，返回会话令牌，同时清理已过期的会话
+func (s *AdminSessions) create(cfg utils.AppConfig, now time.Time) (string, error) {
+	token, hash, err := utils.NewEditToken()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for h, session := range s.sessions {
+		if !now.Before(session.expiresAt) {
+			delete(s.sessions, h)
+		}
+	}
+	s.sessions[hash] = adminSession{expiresAt: now.Add(AdminSessionTTL), credentials: adminCredentials(cfg)}
+	return token, nil
+}
+
+// lookup 查找未过期且凭据未修改的会话，返回会话令牌的哈希
+func (s *AdminSessions) lookup(cfg utils.AppConfig, token string, now time.Time) (string, bool) {
+	hash := utils.HashEditToken(token)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[hash]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(session.expiresAt) || session.credentials != adminCredentials(cfg) {
+		delete(s.sessions, hash)
+		return "", false
+	}
+	return hash, true
+}
+
+// remove 删除会话
+func (s *AdminSessions) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, utils.HashEditToken(token))
+}
+
+// RequireAdmin 校验管理员身份：登录会话的 Cookie，或请求头 Authorization: Bearer <管理令牌>。
+// 未配置管理令牌和管理密码时管理接口不可用
+func RequireAdmin(sessions *AdminSessions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := utils.CurrentConfig()
+		if !adminEnabled(cfg) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "管理接口未启用"})
+			return
+		}
+		if given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			if cfg.AdminToken == "" || subtle.ConstantTimeCompare([]byte(given), []byte(cfg.AdminToken)) != 1 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "管理令牌无效"})
+				return
+			}
+			c.Set(adminActorKey, "token")
+			c.Next()
+			return
+		}
+		if token, err := c.Cookie(AdminSessionCookie); err == nil {
+			if hash, ok := sessions.lookup(cfg, token, utils.NowUTC()); ok {
+				c.Set(adminActorKey, "session:"+hash[:8])
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "需要管理员登录"})
+	}
+}
+
+// adminLoginRequest 管理员登录的请求体
+type adminLoginRequest struct {
+	Password string `json:"password"`
+}
+
+// AdminLogin 使用管理密码或管理令牌登录，成功后设置会话 Cookie
+func AdminLogin(sessions *AdminSessions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := utils.CurrentConfig()
+		if !adminEnabled(cfg) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "管理接口未启用"})
+			return
+		}
+		var req adminLoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+		if !checkAdminPassword(cfg, req.Password) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
+			return
+		}
+
+		now := utils.NowUTC()
+		token, err := sessions.create(cfg, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return
+		}
+		setAdminCookie(c, token, int(AdminSessionTTL/time.Second))
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "登录成功",
+			"expires_at": now.Add(AdminSessionTTL).In(utils.DisplayZone()),
+		})
+	}
+}
+
+// AdminLogout 退出登录，删除会话和 Cookie
+func AdminLogout(sessions *AdminSessions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token, err := c.Cookie(AdminSessionCookie); err == nil {
+			sessions.remove(token)
+		}
+		setAdminCookie(c, "", -1)
+		c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+	}
+}
+
+// setAdminCookie 会话 Cookie 只发送给管理接口，脚本不可读取，也不随跨站请求发送；通过HTTPS访问时只在HTTPS下发送。
+// 与客户端IP相同，只有来自信任的反向代理的 X-Forwarded-Proto 才可信
+func setAdminCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil ||
+		(c.GetHeader("X-Forwarded-Proto") == "https" && utils.CurrentConfig().TrustsProxy(c.RemoteIP()))
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(AdminSessionCookie, value, maxAge, "/api/admin", "", secure, true)
+}
+
+// ReloadConfig 重新加载配置，返回已生效和需要重启才能生效的配置项
+func ReloadConfig(reload func() (utils.ReloadResult, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := reload()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Mammoth777/nilbbs/blob"
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/media"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 上传请求中除文件以外的表单内容允许的大小
+const multipartOverhead = 64 << 10
+
+// 附件文件名的最大字符数
+const maxFilenameLength = 200
+
+// UploadAttachment 使用帖子或评论的编辑令牌上传附件，文件放在 multipart 表单的 file 字段中
+func (h *Handler) UploadAttachment(c *gin.Context) {
+	postID, commentID, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	hash, ok := editTokenHash(c)
+	if !ok {
+		return
+	}
+	cfg := utils.CurrentConfig()
+	if cfg.MaxAttachments == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "附件上传已关闭"})
+		return
+	}
+
+	// 在读取表单之前限制请求体大小，超过时不会把整个请求读入内存或临时文件
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxUploadSize+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "文件过大"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少上传的文件"})
+		return
+	}
+	if header.Size > cfg.MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "文件过大"})
+		return
+	}
+	f, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传的文件失败"})
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传的文件失败"})
+		return
+	}
+
+	// 根据内容判断类型，图片重新编码以去掉元数据
+	file, err := media.Process(data)
+	switch err {
+	case nil:
+	case media.ErrUnsupportedType:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "不支持的文件类型"})
+		return
+	case media.ErrImageTooLarge:
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "图片尺寸过大"})
+		return
+	default:
+		log.Printf("处理附件失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+
+	now := utils.NowUTC()
+	attachment := models.Attachment{
+		PostID:      postID,
+		CommentID:   commentID,
+		Filename:    attachmentFilename(header.Filename),
+		ContentType: file.ContentType,
+		Size:        int64(len(file.Data)),
+		Width:       file.Width,
+		Height:      file.Height,
+		CreatedAt:   now,
+		BlobKey:     blob.Key(file.Data),
+	}
+
+	// 先保存文件再保存附件记录，保存记录失败时留下的文件由清理任务删除
+	ctx := c.Request.Context()
+	if err := h.blobs.Put(ctx, attachment.BlobKey, file.Data, file.ContentType); err != nil {
+		log.Printf("保存附件文件失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	if file.Thumbnail != nil {
+		attachment.ThumbnailKey = blob.Key(file.Thumbnail)
+		if err := h.blobs.Put(ctx, attachment.ThumbnailKey, file.Thumbnail, file.ThumbnailContentType); err != nil {
+			log.Printf("保存缩略图失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return
+		}
+	}
+
+	id, err := h.store.CreateAttachment(&attachment, hash, cfg.MaxAttachments, now)
+	if err == database.ErrAttachmentLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "附件数量已达上限"})
+		return
+	}
+	if err != nil {
+		respondEdit(c, err, "")
+		return
+	}
+	attachment.ID = id
+	setAttachmentURLs(&attachment)
+
+	loc, err := requestZone(c)
+	if err != nil {
+		loc = utils.DisplayZone()
+	}
+	attachment.CreatedAt = attachment.CreatedAt.In(loc)
+	c.JSON(http.StatusCreated, gin.H{
+		"message":    "附件上传成功",
+		"attachment": attachment,
+	})
+}
+
+// attachmentFilename 只保留文件名本身并去掉控制字符，过长时截断
+func attachmentFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// setAttachmentURLs 填入附件的下载地址，图片都有缩略图地址，较小的图片直接使用原图
+func setAttachmentURLs(a *models.Attachment) {
+	a.URL = "/api/attachments/" + strconv.FormatInt(a.ID, 10)
+	if media.IsImage(a.ContentType) {
+		a.ThumbnailURL = a.URL + "/thumbnail"
+	}
+}
+
+// attachAttachments 把帖子的附件分配到帖子本身和当前页的评论上，隐藏的评论不显示附件
+func (h *Handler) attachAttachments(post *models.Post) error {
+	attachments, err := h.store.ListAttachments(post.ID)
+	if err != nil {
+		return err
+	}
+	index := make(map[int64]int, len(post.Comments))
+	for i, comment := range post.Comments {
+		if !comment.Hidden {
+			index[comment.ID] = i
+		}
+	}
+	for _, a := range attachments {
+		setAttachmentURLs(&a)
+		if a.CommentID == 0 {
+			post.Attachments = append(post.Attachments, a)
+		} else if i, ok := index[a.CommentID]; ok {
+			post.Comments[i].Attachments = append(post.Comments[i].Attachments, a)
+		}
+	}
+	return nil
+}
+
+// GetAttachment 下载附件，帖子过期或被删除后附件也不能再访问
+func (h *Handler) GetAttachment(c *gin.Context) {
+	h.serveAttachment(c, false)
+}
+
+// GetAttachmentThumbnail 获取图片附件的缩略图
+func (h *Handler) GetAttachmentThumbnail(c *gin.Context) {
+	h.serveAttachment(c, true)
+}
+
+func (h *Handler) serveAttachment(c *gin.Context, thumbnail bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的附件ID"})
+		return
+	}
+	a, err := h.store.GetAttachment(id, utils.NowUTC())
+	if err == database.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在或已过期"})
+		return
+	}
+	if err != nil {
+		log.Printf("查询附件失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+
+	key, contentType, size := a.BlobKey, a.ContentType, a.Size
+	if thumbnail {
+		if !media.IsImage(a.ContentType) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "附件没有缩略图"})
+			return
+		}
+		if a.ThumbnailKey != "" {
+			key, contentType, size = a.ThumbnailKey, media.ThumbnailContentType(a.ContentType), -1
+		}
+	}
+
+	// 文件内容不会改变，用键作为ETag
+	etag := `"` + key + `"`
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "private, max-age=3600")
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	r, err := h.blobs.Open(c.Request.Context(), key)
+	if err == blob.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "附件不存在或已过期"})
+		return
+	}
+	if err != nil {
+		log.Printf("读取附件文件失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	defer r.Close()
+
+	// 只有图片在浏览器中直接显示，其他文件一律下载；禁止浏览器猜测类型和执行内容中的脚本
+	disposition := "attachment"
+	if media.IsImage(contentType) {
+		disposition = "inline"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, r, map[string]string{
+		"Content-Disposition":     mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}),
+		"X-Content-Type-Options":  "nosniff",
+		"Content-Security-Policy": "default-src 'none'; sandbox",
+	})
+}
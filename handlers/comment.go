@@ -6,13 +6,15 @@ import (
 	"strconv"
 
 	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/filter"
+	"github.com/Mammoth777/nilbbs/markup"
 	"github.com/Mammoth777/nilbbs/models"
 	"github.com/Mammoth777/nilbbs/utils"
 	"github.com/gin-gonic/gin"
 )
 
 // AddComment 添加评论
-func AddComment(c *gin.Context) {
+func (h *Handler) AddComment(c *gin.Context) {
 	postIDStr := c.Param("id")
 	postID, err := strconv.ParseInt(postIDStr, 10, 64)
 	if err != nil {
@@ -31,35 +33,125 @@ func AddComment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "评论内容不能为空"})
 		return
 	}
+	format, ok := markup.ParseFormat(comment.Format)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的内容格式"})
+		return
+	}
+	comment.Format = format
+	comment.ContentHTML = ""
+
+	// 发布前用过滤规则检查内容
+	result, ok := filterContent(c, comment.Content)
+	if !ok {
+		return
+	}
+	comment.Content = result.Content
+	comment.Status = initialStatus(utils.CurrentConfig().PremoderateComments(), result)
+	comment.Hidden = result.Action == filter.ActionShadowHide
 
 	// 设置默认作者名称（如果没有提供）
 	if comment.Author == "" {
 		comment.Author = "匿名用户"
 	}
 
-	// 使用CST时区创建当前时间
-	now := utils.NowCST()
+	// 使用UTC时间存储
+	comment.PostID = postID
+	comment.CreatedAt = utils.NowUTC()
+	comment.Deleted = false
+
+	// 回复评论时确定所在讨论串和层级
+	if !h.placeReply(c, &comment) {
+		return
+	}
+
+	// 生成编辑令牌，只保存哈希，令牌只在创建时返回一次
+	token, hash, err := utils.NewEditToken()
+	if err != nil {
+		log.Printf("生成编辑令牌失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	comment.EditTokenHash = hash
+
+	comment.PosterID, err = posterID(c, postID)
+	if err != nil {
+		log.Printf("计算发帖人ID失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
 
 	// 存储新评论
-	result, err := database.DB.Exec(
-		"INSERT INTO comments (content, post_id, author, created_at) VALUES (?, ?, ?, ?)",
-		comment.Content, postID, comment.Author, utils.FormatTimeCST(now))
+	commentID, err := h.store.CreateComment(&comment, filterReview(result, true))
+	if err == database.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在或已过期"})
+		return
+	}
+	if err == database.ErrLocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "帖子已锁定，不能评论"})
+		return
+	}
 	if err != nil {
 		log.Printf("创建评论失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
 		return
 	}
-
-	commentID, _ := result.LastInsertId()
 	
-	// 更新帖子的删除时间（基于最新评论时间）
-	if err := database.UpdatePostDeleteTime(postID, utils.Config.InactiveDaysBeforeDelete); err != nil {
-		log.Printf("更新帖子删除时间失败: %v", err)
-		// 不要因为更新删除时间失败而中断正常流程
+	// 更新帖子的删除时间（基于最新评论时间），等待审核的评论在通过审核时才推迟删除时间
+	if comment.Status == models.StatusApproved {
+		if err := h.store.UpdatePostDeleteTime(postID, utils.CurrentConfig().InactiveDaysBeforeDelete); err != nil {
+			log.Printf("更新帖子删除时间失败: %v", err)
+			// 不要因为更新删除时间失败而中断正常流程
+		}
 	}
 	
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "评论添加成功",
+	status, message := createdStatus(comment.Status, http.StatusCreated, "评论添加成功")
+	c.JSON(status, gin.H{
+		"message": message,
 		"comment_id": commentID,
+		"edit_token": token,
 	})
-}
\ No newline at end of file
+}
+
+// placeReply 根据 parent_comment_id 设置回复所在的讨论串和层级，父评论必须属于同一个帖子。
+// 超过最大层级时回复挂到允许的最深一层祖先评论下。请求无效时写入响应并返回 false
+func (h *Handler) placeReply(c *gin.Context, comment *models.Comment) bool {
+	comment.ThreadID, comment.Depth = 0, 0
+	if comment.ParentCommentID == 0 {
+		return true
+	}
+
+	parent, err := h.store.GetComment(comment.PostID, comment.ParentCommentID)
+	if err == database.ErrNotFound || (err == nil && parent.Status != models.StatusApproved) {
+		// 未通过审核的评论对其他人不可见，视为不存在
+		c.JSON(http.StatusBadRequest, gin.H{"error": "回复的评论不存在"})
+		return false
+	}
+	if err != nil {
+		log.Printf("查询评论失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return false
+	}
+	if parent.Deleted || parent.Hidden {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能回复已删除的评论"})
+		return false
+	}
+
+	maxDepth := utils.CurrentConfig().MaxCommentDepth
+	for parent.Depth >= maxDepth && parent.ParentCommentID != 0 {
+		parent, err = h.store.GetComment(comment.PostID, parent.ParentCommentID)
+		if err != nil {
+			log.Printf("查询评论失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return false
+		}
+	}
+
+	comment.ParentCommentID = parent.ID
+	comment.Depth = parent.Depth + 1
+	comment.ThreadID = parent.ThreadID
+	if comment.ThreadID == 0 {
+		comment.ThreadID = parent.ID
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// editRequest 修改帖子或评论的请求体
+type editRequest struct {
+	Content string `json:"content"`
+}
+
+// 读取请求头中的编辑令牌并计算哈希，缺少令牌时返回 false 并写入错误响应
+func editTokenHash(c *gin.Context) (string, bool) {
+	token := c.GetHeader(EditTokenHeader)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少编辑令牌"})
+		return "", false
+	}
+	return utils.HashEditToken(token), true
+}
+
+// 解析路径中的帖子ID和评论ID，评论ID参数为空时只解析帖子ID
+func editTarget(c *gin.Context) (postID, commentID int64, ok bool) {
+	postID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的帖子ID"})
+		return 0, 0, false
+	}
+	if s := c.Param("commentId"); s != "" {
+		commentID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的评论ID"})
+			return 0, 0, false
+		}
+	}
+	return postID, commentID, true
+}
+
+// 读取修改后的内容，内容为空时返回 false 并写入错误响应
+func editContent(c *gin.Context) (string, bool) {
+	var req editRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return "", false
+	}
+	if req.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "内容不能为空"})
+		return "", false
+	}
+	return req.Content, true
+}
+
+// 把存储返回的错误转换为响应
+func respondEdit(c *gin.Context, err error, message string) {
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"message": message})
+	case database.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "内容不存在或已过期"})
+	case database.ErrForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": "编辑令牌无效"})
+	default:
+		log.Printf("修改或删除内容失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+	}
+}
+
+// UpdatePost 使用编辑令牌修改帖子内容
+func (h *Handler) UpdatePost(c *gin.Context) {
+	postID, _, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	hash, ok := editTokenHash(c)
+	if !ok {
+		return
+	}
+	content, ok := editContent(c)
+	if !ok {
+		return
+	}
+	result, ok := filterContent(c, content)
+	if !ok {
+		return
+	}
+	review := editReview(postID, 0, utils.CurrentConfig().PremoderatePosts(), result)
+	err := h.store.UpdatePost(postID, result.Content, hash, review, utils.NowUTC())
+	respondReviewedEdit(c, err, review, "帖子修改成功")
+}
+
+// DeletePost 使用编辑令牌删除帖子及其评论
+func (h *Handler) DeletePost(c *gin.Context) {
+	postID, _, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	hash, ok := editTokenHash(c)
+	if !ok {
+		return
+	}
+	err := h.store.DeletePost(postID, hash, utils.NowUTC())
+	respondEdit(c, err, "帖子删除成功")
+}
+
+// UpdateComment 使用编辑令牌修改评论内容
+func (h *Handler) UpdateComment(c *gin.Context) {
+	postID, commentID, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	hash, ok := editTokenHash(c)
+	if !ok {
+		return
+	}
+	content, ok := editContent(c)
+	if !ok {
+		return
+	}
+	result, ok := filterContent(c, content)
+	if !ok {
+		return
+	}
+	review := editReview(postID, commentID, utils.CurrentConfig().PremoderateComments(), result)
+	err := h.store.UpdateComment(postID, commentID, result.Content, hash, review, utils.NowUTC())
+	respondReviewedEdit(c, err, review, "评论修改成功")
+}
+
+// DeleteComment 使用编辑令牌删除评论
+func (h *Handler) DeleteComment(c *gin.Context) {
+	postID, commentID, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	hash, ok := editTokenHash(c)
+	if !ok {
+		return
+	}
+	err := h.store.DeleteComment(postID, commentID, hash, utils.NowUTC())
+	respondEdit(c, err, "评论删除成功")
+}
+
+// ListRevisions 获取帖子及其评论的修改历史
+func (h *Handler) ListRevisions(c *gin.Context) {
+	postID, _, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+
+	revisions, err := h.store.ListRevisions(postID, utils.NowUTC())
+	if err == database.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在或已过期"})
+		return
+	}
+	if err != nil {
+		log.Printf("查询修改历史失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	if revisions == nil {
+		revisions = []models.Revision{}
+	}
+	for i := range revisions {
+		revisions[i].ReplacedAt = revisions[i].ReplacedAt.In(loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
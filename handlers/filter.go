@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Mammoth777/nilbbs/filter"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/gin-gonic/gin"
+)
+
+// filterContent 用当前的过滤规则检查内容，命中拒绝规则时写入错误响应并返回 false。
+// 命中替换规则时 result.Content 是替换后的内容
+func filterContent(c *gin.Context, content string) (filter.Result, bool) {
+	result := filter.Check(content)
+	if len(result.Hits) == 0 {
+		return result, true
+	}
+	log.Printf("内容命中过滤规则 %s，执行 %s", result.RuleNames(), result.Action)
+	if result.Action == filter.ActionReject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "内容包含不允许发布的词语"})
+		return result, false
+	}
+	return result, true
+}
+
+// filterReview 返回命中过滤规则的帖子或评论（onComment 为 true）对应的管理操作：放入审核队列或悄悄隐藏，
+// 没有命中这两类规则时返回 nil。帖子和评论的ID由调用方或存储填入；
+// 自动执行的操作不记录作者的IP，以免审计日志把IP和内容关联起来
+func filterReview(result filter.Result, onComment bool) []models.AuditEntry {
+	var postAction, commentAction string
+	switch result.Action {
+	case filter.ActionHold:
+		postAction, commentAction = models.ActionHoldPost, models.ActionHoldComment
+	case filter.ActionShadowHide:
+		postAction, commentAction = models.ActionHidePost, models.ActionHideComment
+	default:
+		return nil
+	}
+	action := postAction
+	if onComment {
+		action = commentAction
+	}
+	return []models.AuditEntry{{
+		Action: action,
+		Reason: "命中过滤规则: " + result.RuleNames(),
+		Actor:  models.ActorFilter,
+	}}
+}
+
+// FilterMetrics 以 Prometheus 文本格式输出内容过滤规则的数量和命中次数
+func FilterMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := filter.WriteMetrics(c.Writer); err != nil {
+		log.Printf("输出过滤规则指标失败: %v", err)
+	}
+}
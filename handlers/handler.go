@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/Mammoth777/nilbbs/blob"
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/markup"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 持有处理器所需的依赖
+type Handler struct {
+	store database.Store
+	// blobs 附件文件的存储
+	blobs blob.Store
+}
+
+// NewHandler 使用指定的存储和附件存储创建处理器
+func NewHandler(store database.Store, blobs blob.Store) *Handler {
+	return &Handler{store: store, blobs: blobs}
+}
+
+// TimezoneHeader 客户端指定时区的请求头，与查询参数 tz 作用相同
+const TimezoneHeader = "X-Timezone"
+
+// EditTokenHeader 修改或删除帖子和评论时携带编辑令牌的请求头
+const EditTokenHeader = "X-Edit-Token"
+
+// 获取请求指定的显示时区：优先使用查询参数 tz，其次是 X-Timezone 请求头，都没有时使用配置的默认时区
+func requestZone(c *gin.Context) (*time.Location, error) {
+	name := c.Query("tz")
+	if name == "" {
+		name = c.GetHeader(TimezoneHeader)
+	}
+	if name == "" {
+		return utils.DisplayZone(), nil
+	}
+	return utils.LoadZone(name)
+}
+
+// 存储中的时间都是UTC，返回给客户端前转换为显示时区
+func localizePost(post *models.Post, loc *time.Location) {
+	post.CreatedAt = post.CreatedAt.In(loc)
+	post.DeleteAt = post.DeleteAt.In(loc)
+	post.LastActivityAt = post.LastActivityAt.In(loc)
+	post.EditedAt = localizeOptional(post.EditedAt, loc)
+	localizeAttachments(post.Attachments, loc)
+	for i := range post.Comments {
+		post.Comments[i].CreatedAt = post.Comments[i].CreatedAt.In(loc)
+		post.Comments[i].EditedAt = localizeOptional(post.Comments[i].EditedAt, loc)
+		localizeAttachments(post.Comments[i].Attachments, loc)
+	}
+}
+
+// 转换附件的上传时间
+func localizeAttachments(attachments []models.Attachment, loc *time.Location) {
+	for i := range attachments {
+		attachments[i].CreatedAt = attachments[i].CreatedAt.In(loc)
+	}
+}
+
+// 转换可为空的时间
+func localizeOptional(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	local := t.In(loc)
+	return &local
+}
+
+// 隐藏的评论保留为占位，不返回内容、作者和发帖人ID
+func maskHiddenComments(comments []models.Comment) {
+	for i := range comments {
+		if comments[i].Hidden {
+			comments[i].Content = ""
+			comments[i].Author = ""
+			comments[i].PosterID = ""
+		}
+	}
+}
+
+// 按内容格式把帖子和评论渲染为HTML，已删除或隐藏的评论的占位没有内容
+func renderContent(post *models.Post) {
+	post.ContentHTML = markup.Render(post.Content, post.Format)
+	for i := range post.Comments {
+		if !post.Comments[i].Deleted && !post.Comments[i].Hidden {
+			post.Comments[i].ContentHTML = markup.Render(post.Comments[i].Content, post.Comments[i].Format)
+		}
+	}
+}
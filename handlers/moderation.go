@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 管理操作原因的最大字符数
+const maxReasonLength = 500
+
+// moderationRequest 管理操作的请求体，请求体可以为空；delete_at 只用于修改删除时间
+type moderationRequest struct {
+	Reason   string     `json:"reason"`
+	DeleteAt *time.Time `json:"delete_at"`
+}
+
+// Moderate 返回执行指定管理操作的处理器，帖子ID和评论ID取自路径，操作记录在审计日志中
+func (h *Handler) Moderate(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, commentID, ok := editTarget(c)
+		if !ok {
+			return
+		}
+		var req moderationRequest
+		if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+		if utf8.RuneCountInString(req.Reason) > maxReasonLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "原因过长"})
+			return
+		}
+
+		now := utils.NowUTC()
+		entry := models.AuditEntry{
+			Action:    action,
+			PostID:    postID,
+			CommentID: commentID,
+			Reason:    req.Reason,
+			Actor:     c.GetString(adminActorKey),
+			IP:        c.ClientIP(),
+		}
+		if action == models.ActionSetDeleteAt {
+			if req.DeleteAt == nil || !req.DeleteAt.After(now) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "删除时间必须晚于当前时间"})
+				return
+			}
+			deleteAt := req.DeleteAt.UTC()
+			entry.DeleteAt = &deleteAt
+		}
+
+		err := h.store.Moderate(&entry, now)
+		switch err {
+		case nil:
+		case database.ErrNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "内容不存在或已过期"})
+			return
+		case database.ErrInvalidAction:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的管理操作"})
+			return
+		case database.ErrNotPending:
+			c.JSON(http.StatusConflict, gin.H{"error": "内容不在审核队列中"})
+			return
+		default:
+			log.Printf("执行管理操作失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return
+		}
+		log.Printf("管理操作 %s: 帖子 %d 评论 %d，操作者 %s", action, postID, commentID, entry.Actor)
+
+		localizeAuditEntry(&entry, utils.DisplayZone())
+		c.JSON(http.StatusOK, gin.H{"message": "操作成功", "entry": entry})
+	}
+}
+
+// ListAuditLog 获取审计日志，按操作时间倒序分页
+func (h *Handler) ListAuditLog(c *gin.Context) {
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+	page, err := parsePage(c, defaultPostPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 多取一条用于判断是否还有下一页
+	limit := page.Limit
+	page.Limit++
+	entries, err := h.store.ListAuditLog(page)
+	if err != nil {
+		log.Printf("查询审计日志失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+
+	nextCursor := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+	for i := range entries {
+		localizeAuditEntry(&entries[i], loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":     entries,
+		"next_cursor": nextCursor,
+	})
+}
+
+// 转换审计日志中的时间
+func localizeAuditEntry(e *models.AuditEntry, loc *time.Location) {
+	e.CreatedAt = e.CreatedAt.In(loc)
+	e.DeleteAt = localizeOptional(e.DeleteAt, loc)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 帖子列表默认每页条数
+	defaultPostPageSize = 20
+	// 帖子详情中评论默认每页条数
+	defaultCommentPageSize = 50
+	// 每页最多条数
+	maxPageSize = 100
+)
+
+var errInvalidPage = errors.New("无效的分页参数")
+
+// 从查询参数 limit 和 cursor 解析分页参数
+func parsePage(c *gin.Context, defaultLimit int) (database.Page, error) {
+	page := database.Page{Limit: defaultLimit}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return page, errInvalidPage
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+		page.Limit = limit
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return page, errInvalidPage
+		}
+		page.After = after
+	}
+	return page, nil
+}
+
+// 游标对客户端是不透明的字符串，内容为 "<Unix纳秒>.<ID>" 的base64url编码
+func encodeCursor(t time.Time, id int64) string {
+	raw := strconv.FormatInt(t.UnixNano(), 10) + "." + strconv.FormatInt(id, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*database.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	nanosStr, idStr, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, errInvalidPage
+	}
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &database.Cursor{Time: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
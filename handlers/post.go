@@ -1,19 +1,21 @@
 package handlers
 
 import (
-	"database/sql"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/filter"
+	"github.com/Mammoth777/nilbbs/markup"
 	"github.com/Mammoth777/nilbbs/models"
 	"github.com/Mammoth777/nilbbs/utils"
 	"github.com/gin-gonic/gin"
 )
 
 // CreatePost 创建新帖子
-func CreatePost(c *gin.Context) {
+func (h *Handler) CreatePost(c *gin.Context) {
 	var post models.Post
 	if err := c.ShouldBindJSON(&post); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
@@ -25,117 +27,156 @@ func CreatePost(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "内容不能为空"})
 		return
 	}
+	format, ok := markup.ParseFormat(post.Format)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的内容格式"})
+		return
+	}
+	post.Format = format
+	post.ContentHTML = ""
+	post.Locked, post.Pinned = false, false
+
+	// 发布前用过滤规则检查内容
+	result, ok := filterContent(c, post.Content)
+	if !ok {
+		return
+	}
+	post.Content = result.Content
+	post.Status = initialStatus(utils.CurrentConfig().PremoderatePosts(), result)
+	post.Hidden = result.Action == filter.ActionShadowHide
 
 	// 设置默认作者名称（如果没有提供）
 	if post.Author == "" {
 		post.Author = "匿名用户"
 	}
 
-	// 使用CST时区创建当前时间
-	now := utils.NowCST()
+	// 使用UTC时间存储
+	now := utils.NowUTC()
 	
 	// 计算删除时间（当前时间 + 不活跃天数）
-	deleteAt := now.AddDate(0, 0, utils.Config.InactiveDaysBeforeDelete)
+	post.CreatedAt = now
+	post.DeleteAt = now.AddDate(0, 0, utils.CurrentConfig().InactiveDaysBeforeDelete)
+
+	// 生成编辑令牌，只保存哈希，令牌只在创建时返回一次
+	token, hash, err := utils.NewEditToken()
+	if err != nil {
+		log.Printf("生成编辑令牌失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	post.EditTokenHash = hash
 
-	// 存储新帖子，包含删除时间
-	result, err := database.DB.Exec(
-		"INSERT INTO posts (content, author, created_at, delete_at) VALUES (?, ?, ?, ?)",
-		post.Content, post.Author, utils.FormatTimeCST(now), utils.FormatTimeCST(deleteAt))
+	// 存储新帖子，包含删除时间；发帖人ID由帖子ID计算，在保存帖子的事务中设置
+	// 命中过滤规则的记录与帖子在同一事务中写入审计日志
+	postID, err := h.store.CreatePost(&post, func(id int64) (string, error) {
+		return posterID(c, id)
+	}, filterReview(result, false))
 	if err != nil {
 		log.Printf("创建帖子失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
 		return
 	}
 
-	postID, _ := result.LastInsertId()
-	
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "帖子创建成功",
-		"post_id": postID,
+	status, message := createdStatus(post.Status, http.StatusCreated, "帖子创建成功")
+	c.JSON(status, gin.H{
+		"message":    message,
+		"post_id":    postID,
+		"edit_token": token,
 	})
 }
 
 // GetAllPosts 获取所有帖子
-func GetAllPosts(c *gin.Context) {
-	// 获取当前时间，用于过滤已过期的帖子
-	now := utils.NowCST()
-	nowStr := utils.FormatTimeCST(now)
-	
-	// 查询未过期的帖子，使用delete_at字段判断
-	rows, err := database.DB.Query(`
-		SELECT id, content, author, created_at, delete_at
-		FROM posts
-		WHERE delete_at > ?
-		ORDER BY created_at DESC
-	`, nowStr)
+func (h *Handler) GetAllPosts(c *gin.Context) {
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+
+	sort, ok := database.ParsePostSort(c.Query("sort"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的排序方式"})
+		return
+	}
+
+	page, err := parsePage(c, defaultPostPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 查询未过期的帖子，多取一条用于判断是否还有下一页
+	now := utils.NowUTC()
+	limit := page.Limit
+	page.Limit++
+	posts, err := h.store.ListPosts(now, sort, page)
 	if err != nil {
 		log.Printf("查询帖子失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
 		return
 	}
-	defer rows.Close()
 
-	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		var createdAt string
-		var deleteAt string
-		err := rows.Scan(&post.ID, &post.Content, &post.Author, &createdAt, &deleteAt)
-		if err != nil {
-			log.Printf("扫描帖子数据失败: %v", err)
-			continue
-		}
-		
-		t, err := utils.ParseTimeCST(createdAt)
-		if err != nil {
-			log.Printf("解析创建时间失败: %v", err)
-			// 使用当前时间作为后备
-			t = utils.NowCST()
-		}
-		post.CreatedAt = t
-		
-		// 解析删除时间
-		dt, err := utils.ParseTimeCST(deleteAt)
+	nextCursor := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		cursor := sort.CursorAfter(&posts[limit-1])
+		nextCursor = encodeCursor(cursor.Time, cursor.ID)
+	}
+
+	// 置顶的帖子不参与分页，显示在第一页的最前面
+	if page.After == nil {
+		pinned, err := h.store.ListPinnedPosts(now)
 		if err != nil {
-			log.Printf("解析删除时间失败: %v", err)
-			// 使用创建时间加上默认过期天数作为后备
-			dt = t.AddDate(0, 0, utils.Config.InactiveDaysBeforeDelete)
+			log.Printf("查询置顶帖子失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return
 		}
-		post.DeleteAt = dt
-		
-		posts = append(posts, post)
+		posts = append(pinned, posts...)
+	}
+	for i := range posts {
+		posts[i].Excerpt = excerpt(posts[i].Content)
+		localizePost(&posts[i], loc)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"posts":       posts,
+		"next_cursor": nextCursor,
 	})
 }
 
 // GetPostByID 获取单个帖子及其评论
-func GetPostByID(c *gin.Context) {
+func (h *Handler) GetPostByID(c *gin.Context) {
 	postIDStr := c.Param("id")
 	postID, err := strconv.ParseInt(postIDStr, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的帖子ID"})
 		return
 	}
-	
-	// 获取当前时间，用于过滤已过期的帖子
-	now := utils.NowCST()
-	nowStr := utils.FormatTimeCST(now)
-	
-	// 查询帖子，使用delete_at字段判断是否过期
-	var post models.Post
-	var createdAt string
-	var deleteAt string
-	err = database.DB.QueryRow(`
-		SELECT id, content, author, created_at, delete_at
-		FROM posts
-		WHERE id = ? AND delete_at > ?
-	`, postID, nowStr).Scan(&post.ID, &post.Content, &post.Author, &createdAt, &deleteAt)
 
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+
+	page, err := parsePage(c, defaultCommentPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	// 查询帖子，已过期的帖子视为不存在
+	now := utils.NowUTC()
+	post, err := h.store.GetPost(postID, now)
+	if err == database.ErrNotFound && c.GetHeader(EditTokenHeader) != "" {
+		// 作者可以用编辑令牌查看自己等待审核或被拒绝的帖子，令牌不符时同样视为不存在
+		post, err = h.store.GetOwnPost(postID, utils.HashEditToken(c.GetHeader(EditTokenHeader)), now)
+		if err == database.ErrForbidden {
+			err = database.ErrNotFound
+		}
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if err == database.ErrNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"error": "帖子不存在或已过期"})
 			return
 		}
@@ -144,60 +185,62 @@ func GetPostByID(c *gin.Context) {
 		return
 	}
 
-	t, err := utils.ParseTimeCST(createdAt)
-	if err != nil {
-		log.Printf("解析帖子创建时间失败: %v", err)
-		// 使用当前时间作为后备
-		t = utils.NowCST()
-	}
-	post.CreatedAt = t
-	
-	// 解析删除时间
-	dt, err := utils.ParseTimeCST(deleteAt)
-	if err != nil {
-		log.Printf("解析删除时间失败: %v", err)
-		// 使用创建时间加上默认过期天数作为后备
-		dt = t.AddDate(0, 0, utils.Config.InactiveDaysBeforeDelete)
-	}
-	post.DeleteAt = dt
-
-	// 查询评论
-	rows, err := database.DB.Query(`
-		SELECT id, content, author, created_at 
-		FROM comments
-		WHERE post_id = ?
-		ORDER BY created_at ASC
-	`, postID)
+	// 查询评论，分页按顶层评论计算，多取一个讨论串用于判断是否还有下一页
+	limit := page.Limit
+	page.Limit++
+	comments, err := h.store.ListComments(postID, page)
 	if err != nil {
 		log.Printf("查询评论失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
 		return
 	}
-	defer rows.Close()
 
-	var comments []models.Comment
-	for rows.Next() {
-		var comment models.Comment
-		var commentCreatedAt string
-		err := rows.Scan(&comment.ID, &comment.Content, &comment.Author, &commentCreatedAt)
-		if err != nil {
-			log.Printf("扫描评论数据失败: %v", err)
+	nextCursor := ""
+	roots := 0
+	for i, comment := range comments {
+		if comment.ParentCommentID != 0 {
 			continue
 		}
-		comment.PostID = postID
-		t, err := utils.ParseTimeCST(commentCreatedAt)
-		if err != nil {
-			log.Printf("解析评论时间失败: %v", err)
-			// 使用当前时间作为后备
-			t = utils.NowCST()
+		roots++
+		if roots > limit {
+			comments = comments[:i]
+			break
 		}
-		comment.CreatedAt = t
-		comments = append(comments, comment)
+		nextCursor = encodeCursor(comment.CreatedAt, comment.ID)
+	}
+	if roots <= limit {
+		nextCursor = ""
 	}
 
 	post.Comments = comments
+	maskHiddenComments(post.Comments)
+	if err := h.linkQuotes(post, now); err != nil {
+		log.Printf("查询引用失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	if err := h.attachAttachments(post); err != nil {
+		log.Printf("查询附件失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	renderContent(post)
+	localizePost(post, loc)
 
 	c.JSON(http.StatusOK, gin.H{
-		"post": post,
+		"post":        post,
+		"next_cursor": nextCursor,
 	})
-}
\ No newline at end of file
+}
+
+// 帖子列表中内容摘要的最大字符数
+const excerptLength = 80
+
+// 截取内容开头作为摘要，按字符而不是字节截断
+func excerpt(content string) string {
+	runes := []rune(strings.TrimSpace(content))
+	if len(runes) <= excerptLength {
+		return string(runes)
+	}
+	return string(runes[:excerptLength]) + "..."
+}
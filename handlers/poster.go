@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"sync"
+
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// randomPosterKey 未配置 poster_id_key 时使用的密钥，每次启动随机生成
+var randomPosterKey = sync.OnceValues(func() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return key, err
+})
+
+// serverKey 返回计算发帖人ID和举报人标识的密钥，未配置 poster_id_key 时使用启动时随机生成的密钥
+func serverKey() ([]byte, error) {
+	if key := utils.CurrentConfig().PosterIDKey; key != "" {
+		return []byte(key), nil
+	}
+	return randomPosterKey()
+}
+
+// posterID 计算请求的客户端在帖子中的发帖人ID，客户端标识与限速相同，IPv6 按 /64 网段计算
+func posterID(c *gin.Context, postID int64) (string, error) {
+	key, err := serverKey()
+	if err != nil {
+		return "", err
+	}
+	return utils.PosterID(key, clientKey(c.ClientIP()), postID, utils.NowUTC()), nil
+}
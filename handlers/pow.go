@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Mammoth777/nilbbs/pow"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// PowChallenge 签发工作量证明挑战，难度随最近的发帖频率提高；未开启时难度为 0，客户端不需要计算
+func PowChallenge(issuer *pow.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := utils.CurrentConfig()
+		now := utils.NowUTC()
+		c.Header("Cache-Control", "no-store")
+		difficulty := issuer.Difficulty(cfg.PowDifficulty, cfg.PowMaxDifficulty, now)
+		if difficulty == 0 {
+			c.JSON(http.StatusOK, gin.H{"difficulty": 0})
+			return
+		}
+		challenge, err := issuer.Issue(difficulty, now)
+		if err != nil {
+			log.Printf("签发工作量证明挑战失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+	}
+}
+
+// RequireProofOfWork 校验请求头 X-Pow-Challenge 中的挑战和 X-Pow-Nonce 中的答案，每个挑战只能使用一次
+func RequireProofOfWork(issuer *pow.Issuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utils.CurrentConfig().PowDifficulty == 0 {
+			c.Next()
+			return
+		}
+		challenge, nonce := c.GetHeader("X-Pow-Challenge"), c.GetHeader("X-Pow-Nonce")
+		if challenge == "" || nonce == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "缺少工作量证明"})
+			return
+		}
+		if err := issuer.Verify(challenge, nonce, utils.NowUTC()); err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
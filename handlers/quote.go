@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// linkQuotes 解析帖子正文和评论中的引用并标记失效的目标，同时为每条评论填入引用了它的内容
+func (h *Handler) linkQuotes(post *models.Post, now time.Time) error {
+	postRefs := utils.ParseQuotes(post.Content)
+	commentRefs := make([][]utils.QuoteRef, len(post.Comments))
+	var commentIDs, postIDs []int64
+	collect := func(refs []utils.QuoteRef) {
+		for _, ref := range refs {
+			if ref.Post {
+				postIDs = append(postIDs, ref.ID)
+			} else {
+				commentIDs = append(commentIDs, ref.ID)
+			}
+		}
+	}
+	collect(postRefs)
+	for i := range post.Comments {
+		commentRefs[i] = utils.ParseQuotes(post.Comments[i].Content)
+		collect(commentRefs[i])
+	}
+
+	targets, err := h.store.ResolveQuotes(commentIDs, postIDs, now)
+	if err != nil {
+		return err
+	}
+	post.Quotes = quoteLinks(postRefs, targets)
+	for i := range post.Comments {
+		post.Comments[i].Quotes = quoteLinks(commentRefs[i], targets)
+	}
+
+	ids := make([]int64, len(post.Comments))
+	for i, c := range post.Comments {
+		ids[i] = c.ID
+	}
+	backlinks, err := h.store.ListBacklinks(ids, now)
+	if err != nil {
+		return err
+	}
+	for i := range post.Comments {
+		post.Comments[i].Backlinks = backlinks[post.Comments[i].ID]
+	}
+	return nil
+}
+
+// quoteLinks 把解析出的引用转换为返回给客户端的链接，目标不存在时标记为失效
+func quoteLinks(refs []utils.QuoteRef, targets database.QuoteTargets) []models.QuoteLink {
+	var links []models.QuoteLink
+	for _, ref := range refs {
+		link := models.QuoteLink{ID: ref.ID, Post: ref.Post}
+		if ref.Post {
+			if targets.Posts[ref.ID] {
+				link.PostID = ref.ID
+			}
+		} else {
+			link.PostID = targets.Comments[ref.ID]
+		}
+		link.Dead = link.PostID == 0
+		links = append(links, link)
+	}
+	return links
+}
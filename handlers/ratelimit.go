@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit 按客户端IP对路由限速，超过限速时返回 429 和 Retry-After。
+// 限速在每次请求时从当前配置读取，重新加载配置后立即生效；客户端IP只有来自信任的代理时才取自 X-Forwarded-For
+func RateLimit(limiter *utils.RateLimiter, route string, rate func(cfg utils.AppConfig) utils.Rate) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := route + " " + clientKey(c.ClientIP())
+		ok, wait := limiter.Allow(key, rate(utils.CurrentConfig()), utils.NowUTC())
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁，请稍后再试"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey 返回限速使用的客户端标识。IPv6 客户端通常拥有整个 /64 网段，按网段限速
+func clientKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	if addr.Is6() {
+		prefix, _ := addr.Prefix(64)
+		return prefix.String()
+	}
+	return addr.String()
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 举报队列默认返回的条数
+const defaultReportLimit = 50
+
+// reportRequest 举报的请求体
+type reportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Report 举报帖子或评论，帖子ID和评论ID取自路径。每个客户端对同一内容只能举报一次，
+// 只保存客户端标识用服务器密钥计算的 HMAC；举报次数达到配置的阈值时自动隐藏内容
+func (h *Handler) Report(c *gin.Context) {
+	postID, commentID, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	var req reportRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !models.ValidReportReason(req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的举报原因"})
+		return
+	}
+
+	key, err := serverKey()
+	if err != nil {
+		log.Printf("生成举报人标识密钥失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	report := models.Report{
+		PostID:    postID,
+		CommentID: commentID,
+		Reason:    req.Reason,
+		Reporter:  utils.ReporterHash(key, clientKey(c.ClientIP()), postID, commentID),
+	}
+	hidden, err := h.store.CreateReport(&report, utils.CurrentConfig().ReportHideThreshold, utils.NowUTC())
+	switch err {
+	case nil:
+	case database.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "内容不存在或已过期"})
+		return
+	case database.ErrAlreadyReported:
+		c.JSON(http.StatusConflict, gin.H{"error": "你已经举报过该内容"})
+		return
+	default:
+		log.Printf("保存举报失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	if hidden {
+		log.Printf("帖子 %d 评论 %d 举报次数达到阈值，已自动隐藏", postID, commentID)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "举报已提交"})
+}
+
+// ListReports 获取举报队列，按举报次数倒序
+func (h *Handler) ListReports(c *gin.Context) {
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+	limit := defaultReportLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPage.Error()})
+			return
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+	}
+
+	reports, err := h.store.ListReports(utils.NowUTC(), limit)
+	if err != nil {
+		log.Printf("查询举报失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	if reports == nil {
+		reports = []models.ReportSummary{}
+	}
+	for i := range reports {
+		reports[i].FirstReportedAt = reports[i].FirstReportedAt.In(loc)
+		reports[i].LastReportedAt = reports[i].LastReportedAt.In(loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/filter"
+	"github.com/Mammoth777/nilbbs/markup"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 内容需要等待审核时返回给发布者的提示
+const heldMessage = "内容已提交，等待管理员审核"
+
+// 审核队列默认返回的条数
+const defaultPendingLimit = 50
+
+// 一次批量审核的最大条数
+const maxReviewItems = 100
+
+// targetAction 按操作对象选择针对帖子或评论的操作，commentID 为 0 表示帖子本身
+func targetAction(postAction, commentAction string, commentID int64) string {
+	if commentID != 0 {
+		return commentAction
+	}
+	return postAction
+}
+
+// initialStatus 新内容的审核状态：先审后发模式下或命中等待审核的过滤规则时等待审核
+func initialStatus(premoderate bool, result filter.Result) string {
+	if premoderate || result.Action == filter.ActionHold {
+		return models.StatusPending
+	}
+	return models.StatusApproved
+}
+
+// createdStatus 返回发布成功时的状态码和提示：等待审核的内容返回 202，
+// 悄悄隐藏的内容与正常发布的响应相同
+func createdStatus(status string, code int, message string) (int, string) {
+	if status == models.StatusPending {
+		return http.StatusAccepted, heldMessage
+	}
+	return code, message
+}
+
+// editReview 返回与修改在同一事务中执行的管理操作：命中过滤规则时放入审核队列或悄悄隐藏，
+// 先审后发模式下把修改后的内容放回审核队列。与 filterReview 相同，不记录作者的IP
+func editReview(postID, commentID int64, premoderate bool, result filter.Result) []models.AuditEntry {
+	review := filterReview(result, commentID != 0)
+	if premoderate && result.Action != filter.ActionHold {
+		review = append(review, models.AuditEntry{
+			Action: targetAction(models.ActionHoldPost, models.ActionHoldComment, commentID),
+			Reason: "先审后发模式下修改的内容需要重新审核",
+			Actor:  models.ActorAuto,
+		})
+	}
+	for i := range review {
+		review[i].PostID, review[i].CommentID = postID, commentID
+	}
+	return review
+}
+
+// respondReviewedEdit 把修改的结果转换为响应，修改后的内容放回了审核队列时返回 202
+func respondReviewedEdit(c *gin.Context, err error, review []models.AuditEntry, message string) {
+	if err != nil {
+		respondEdit(c, err, message)
+		return
+	}
+	for _, e := range review {
+		if e.Action == models.ActionHoldPost || e.Action == models.ActionHoldComment {
+			c.JSON(http.StatusAccepted, gin.H{"message": heldMessage})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// GetOwnComment 使用编辑令牌查看自己的评论，等待审核或被拒绝的评论也会返回
+func (h *Handler) GetOwnComment(c *gin.Context) {
+	postID, commentID, ok := editTarget(c)
+	if !ok {
+		return
+	}
+	hash, ok := editTokenHash(c)
+	if !ok {
+		return
+	}
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+
+	comment, err := h.store.GetOwnComment(postID, commentID, hash, utils.NowUTC())
+	switch err {
+	case nil:
+	case database.ErrNotFound, database.ErrForbidden:
+		// 令牌不符时同样返回不存在，不透露未公开的评论
+		c.JSON(http.StatusNotFound, gin.H{"error": "评论不存在或已过期"})
+		return
+	default:
+		log.Printf("查询评论失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+
+	comments := []models.Comment{*comment}
+	maskHiddenComments(comments)
+	if !comments[0].Hidden {
+		comments[0].ContentHTML = markup.Render(comments[0].Content, comments[0].Format)
+	}
+	comments[0].CreatedAt = comments[0].CreatedAt.In(loc)
+	comments[0].EditedAt = localizeOptional(comments[0].EditedAt, loc)
+
+	c.JSON(http.StatusOK, gin.H{"comment": comments[0]})
+}
+
+// ListPending 获取审核队列，按创建时间正序，先提交的先审核
+func (h *Handler) ListPending(c *gin.Context) {
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+	limit := defaultPendingLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPage.Error()})
+			return
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+	}
+
+	items, err := h.store.ListPending(utils.NowUTC(), limit)
+	if err != nil {
+		log.Printf("查询审核队列失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	if items == nil {
+		items = []models.PendingItem{}
+	}
+	for i := range items {
+		items[i].CreatedAt = items[i].CreatedAt.In(loc)
+		items[i].DeleteAt = items[i].DeleteAt.In(loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// reviewItem 批量审核中的一项，评论才有 comment_id
+type reviewItem struct {
+	PostID    int64 `json:"post_id"`
+	CommentID int64 `json:"comment_id,omitempty"`
+}
+
+// reviewRequest 批量审核的请求体，原因对所有项相同
+type reviewRequest struct {
+	Items  []reviewItem `json:"items"`
+	Reason string       `json:"reason"`
+}
+
+// reviewFailure 批量审核中失败的一项
+type reviewFailure struct {
+	reviewItem
+	Error string `json:"error"`
+}
+
+// ReviewQueue 返回批量通过（approve 为 true）或拒绝审核队列中内容的处理器。
+// 每一项单独执行并记录审计日志，一项失败不影响其他项
+func (h *Handler) ReviewQueue(approve bool) gin.HandlerFunc {
+	postAction, commentAction := models.ActionRejectPost, models.ActionRejectComment
+	if approve {
+		postAction, commentAction = models.ActionApprovePost, models.ActionApproveComment
+	}
+	return func(c *gin.Context) {
+		var req reviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+			return
+		}
+		if len(req.Items) > maxReviewItems {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "一次最多审核 " + strconv.Itoa(maxReviewItems) + " 项"})
+			return
+		}
+		if utf8.RuneCountInString(req.Reason) > maxReasonLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "原因过长"})
+			return
+		}
+
+		actor := c.GetString(adminActorKey)
+		done := 0
+		failed := []reviewFailure{}
+		for _, item := range req.Items {
+			entry := models.AuditEntry{
+				Action:    targetAction(postAction, commentAction, item.CommentID),
+				PostID:    item.PostID,
+				CommentID: item.CommentID,
+				Reason:    req.Reason,
+				Actor:     actor,
+				IP:        c.ClientIP(),
+			}
+			err := h.store.Moderate(&entry, utils.NowUTC())
+			switch err {
+			case nil:
+				done++
+				log.Printf("管理操作 %s: 帖子 %d 评论 %d，操作者 %s", entry.Action, item.PostID, item.CommentID, actor)
+			case database.ErrNotFound:
+				failed = append(failed, reviewFailure{item, "内容不存在或已过期"})
+			case database.ErrNotPending:
+				failed = append(failed, reviewFailure{item, "内容不在审核队列中"})
+			default:
+				log.Printf("审核帖子 %d 评论 %d 失败: %v", item.PostID, item.CommentID, err)
+				failed = append(failed, reviewFailure{item, "服务器内部错误"})
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "操作完成", "done": done, "failed": failed})
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 搜索结果默认条数
+	defaultSearchLimit = 20
+	// 搜索片段的最大字符数
+	snippetLength = 80
+	// 片段中第一个关键词之前保留的字符数
+	snippetLead = 20
+)
+
+// Search 在未过期的帖子和评论中搜索
+func (h *Handler) Search(c *gin.Context) {
+	terms := database.SearchTerms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "搜索内容不能为空"})
+		return
+	}
+
+	loc, err := requestZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时区"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidPage.Error()})
+			return
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+	}
+
+	results, err := h.store.Search(utils.NowUTC(), terms, limit)
+	if err != nil {
+		log.Printf("搜索失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	for i := range results {
+		results[i].Snippet = highlightSnippet(results[i].Content, terms)
+		results[i].CreatedAt = results[i].CreatedAt.In(loc)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
+
+// highlightSnippet 截取第一个关键词附近的内容，转义HTML后用 <mark> 标记所有关键词
+func highlightSnippet(content string, terms []string) string {
+	runes := []rune(content)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	lowerTerms := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		lowerTerms = append(lowerTerms, []rune(strings.ToLower(term)))
+	}
+
+	// matchAt 返回从位置 i 开始匹配到的最长关键词长度，没有匹配时为0
+	matchAt := func(i int) int {
+		best := 0
+		for _, term := range lowerTerms {
+			if len(term) > best && i+len(term) <= len(lower) && string(lower[i:i+len(term)]) == string(term) {
+				best = len(term)
+			}
+		}
+		return best
+	}
+
+	first := 0
+	for i := range lower {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	start := first - snippetLead
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; {
+		if n := matchAt(i); n > 0 {
+			stop := i + n
+			if stop > end {
+				stop = end
+			}
+			b.WriteString("<mark>" + html.EscapeString(string(runes[i:stop])) + "</mark>")
+			i = stop
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
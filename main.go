@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -8,27 +10,40 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Mammoth777/nilbbs/blob"
 	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/filter"
 	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/Mammoth777/nilbbs/models"
 	"github.com/Mammoth777/nilbbs/nickname"
+	"github.com/Mammoth777/nilbbs/pow"
 	"github.com/Mammoth777/nilbbs/utils"
 	"github.com/gin-gonic/gin"
 )
 
-// 定期删除旧帖子的函数
-func setupPostCleanupTask() *utils.ScheduledTask {
+// 定期删除旧帖子的函数，删除后清理不再被任何附件使用的文件
+func setupPostCleanupTask(store database.Store, blobs blob.Store) *utils.ScheduledTask {
 	// 创建一个每 1 小时执行一次的定时任务
 	interval := 1 * time.Hour
 	// interval := 10 * time.Second // 测试时使用10s
 	task := utils.NewScheduledTask(interval, func() {
 		// 使用配置中的天数值，默认为30天
-		daysToKeep := utils.Config.InactiveDaysBeforeDelete
-		count, err := database.DeleteOldPosts(daysToKeep)
+		daysToKeep := utils.CurrentConfig().InactiveDaysBeforeDelete
+		count, err := store.DeleteOldPosts(utils.NowUTC())
 		if err != nil {
 			log.Printf("删除旧帖子时出错: %v", err)
 			return
 		}
 		log.Printf("成功删除了 %d 条超过 %d 天不活跃的旧帖子", count, daysToKeep)
+
+		removed, err := blob.Sweep(context.Background(), blobs, store.BlobKeys, time.Now())
+		if err != nil {
+			log.Printf("清理附件文件时出错: %v", err)
+			return
+		}
+		if removed > 0 {
+			log.Printf("清理了 %d 个不再使用的附件文件", removed)
+		}
 	})
 	
 	// 设置为不在启动时立即执行
@@ -38,28 +53,86 @@ func setupPostCleanupTask() *utils.ScheduledTask {
 }
 
 func main() {
-	// 从环境变量加载配置
-	utils.LoadConfigFromEnv()
-	
-	// 初始化数据库
-	if err := database.InitDB(); err != nil {
-		log.Fatalf("数据库初始化失败: %v", err)
+	// 日志时间使用配置的显示时区
+	log.SetFlags(0)
+	log.SetOutput(utils.NewZoneLogWriter(os.Stderr))
+
+	// 合并配置文件、环境变量和命令行参数
+	loader, args, err := utils.ParseConfigFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+	cfg, sources, err := loader.Load()
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+	if err := utils.SetConfig(cfg); err != nil {
+		log.Fatalf("应用配置失败: %v", err)
+	}
+	if loader.File != "" {
+		log.Printf("已加载配置文件 %s", loader.File)
+	}
+
+	// 处理子命令
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(runMigrate(args[1:]))
+		case "config":
+			os.Exit(runConfig(args[1:], cfg, sources))
+		case "hash-password":
+			os.Exit(runHashPassword(os.Stdin, os.Stdout))
+		default:
+			log.Fatalf("未知命令: %s", args[0])
+		}
 	}
-	defer database.CloseDB()
 	
-	// 设置定期删除旧帖子的任务
-	cleanupTask := setupPostCleanupTask()
-	cleanupTask.Start()
-	defer cleanupTask.Stop()
+	// 未配置密钥时每次启动随机生成，重启后发帖人ID改变，举报去重也会失效
+	if cfg.PosterIDKey == "" {
+		log.Printf("警告: 未配置 poster_id_key，重启后同一客户端可以再次举报已举报过的内容，并重复计入自动隐藏的阈值")
+	}
+
+	// 初始化附件存储
+	blobs, err := blob.Open(cfg.AttachmentStore)
+	if err != nil {
+		log.Fatalf("附件存储初始化失败: %v", err)
+	}
+
+	// 加载内容过滤规则
+	if err := filter.Load(cfg.FilterRules); err != nil {
+		log.Fatalf("加载内容过滤规则失败: %v", err)
+	}
+
+	// 工作量证明的签名密钥每次启动随机生成
+	issuer, err := pow.NewIssuer(nil)
+	if err != nil {
+		log.Fatalf("初始化工作量证明失败: %v", err)
+	}
 
 	// 创建Gin引擎
 	r := gin.Default()
 
-	// 设置静态文件服务
-	r.Static("/static", "./static")
-	
-	// 加载HTML模板
-	r.LoadHTMLGlob("templates/*")
+	// 只信任配置中的反向代理转发的客户端IP，否则任何人都能伪造 X-Forwarded-For 绕过限速
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("设置信任的代理失败: %v", err)
+	}
+
+	// 加载内嵌的模板、静态文件和词库
+	if err := setupAssets(r, cfg.OverrideDir); err != nil {
+		log.Fatalf("加载模板和静态文件失败: %v", err)
+	}
+
+	// 最后打开数据库，此后不再有直接退出的错误，退出前都会经过 gracefulShutdown 关闭数据库
+	store, err := database.Open(cfg.DatabaseDSN)
+	if err != nil {
+		log.Fatalf("数据库初始化失败: %v", err)
+	}
+
+	// 设置定期删除旧帖子的任务
+	cleanupTask := setupPostCleanupTask(store, blobs)
+	cleanupTask.Start()
 
 	// 首页路由
 	r.GET("/", func(c *gin.Context) {
@@ -83,34 +156,123 @@ func main() {
 		})
 	})
 
+	h := handlers.NewHandler(store, blobs)
+
+	// 按客户端IP对写操作限速，最多保留10万个令牌桶
+	limiter := utils.NewRateLimiter(100000)
+	limitPosts := handlers.RateLimit(limiter, "posts", func(c utils.AppConfig) utils.Rate { return c.RateLimitPosts })
+	limitComments := handlers.RateLimit(limiter, "comments", func(c utils.AppConfig) utils.Rate { return c.RateLimitComments })
+	limitUploads := handlers.RateLimit(limiter, "uploads", func(c utils.AppConfig) utils.Rate { return c.RateLimitUploads })
+	limitReports := handlers.RateLimit(limiter, "reports", func(c utils.AppConfig) utils.Rate { return c.RateLimitReports })
+
+	// 发帖和评论需要工作量证明
+	requirePow := handlers.RequireProofOfWork(issuer)
+	r.GET("/api/pow/challenge", handlers.PowChallenge(issuer))
+
 	// 帖子路由
-	r.GET("/api/posts", handlers.GetAllPosts)
-	r.GET("/api/posts/:id", handlers.GetPostByID)
-	r.POST("/api/posts", handlers.CreatePost)
+	r.GET("/api/posts", h.GetAllPosts)
+	r.GET("/api/posts/:id", h.GetPostByID)
+	r.GET("/api/posts/:id/revisions", h.ListRevisions)
+	r.POST("/api/posts", limitPosts, requirePow, h.CreatePost)
+	r.PUT("/api/posts/:id", h.UpdatePost)
+	r.DELETE("/api/posts/:id", h.DeletePost)
 
 	// 评论路由
-	r.POST("/api/posts/:id/comments", handlers.AddComment)
+	r.POST("/api/posts/:id/comments", limitComments, requirePow, h.AddComment)
+	r.GET("/api/posts/:id/comments/:commentId", h.GetOwnComment)
+	r.PUT("/api/posts/:id/comments/:commentId", h.UpdateComment)
+	r.DELETE("/api/posts/:id/comments/:commentId", h.DeleteComment)
+
+	// 附件路由
+	r.POST("/api/posts/:id/attachments", limitUploads, h.UploadAttachment)
+	r.POST("/api/posts/:id/comments/:commentId/attachments", limitUploads, h.UploadAttachment)
+	r.GET("/api/attachments/:id", h.GetAttachment)
+	r.GET("/api/attachments/:id/thumbnail", h.GetAttachmentThumbnail)
+
+	// 举报路由
+	r.POST("/api/posts/:id/report", limitReports, h.Report)
+	r.POST("/api/posts/:id/comments/:commentId/report", limitReports, h.Report)
+
+	// 搜索路由
+	r.GET("/api/search", h.Search)
 	r.GET("/api/random-go-nickname", func(c *gin.Context) {
     	c.String(http.StatusOK, nickname.GetRandomNickname())
 	})
 
+	// 管理路由，登录限速以防猜测密码
+	sessions := handlers.NewAdminSessions()
+	limitLogin := handlers.RateLimit(limiter, "admin-login", func(utils.AppConfig) utils.Rate {
+		return utils.Rate{Burst: 10, Per: 10 * time.Minute}
+	})
+	r.POST("/api/admin/login", limitLogin, handlers.AdminLogin(sessions))
+	r.POST("/api/admin/logout", handlers.AdminLogout(sessions))
+
+	admin := r.Group("/api/admin", handlers.RequireAdmin(sessions))
+	reload := func() (utils.ReloadResult, error) { return reloadConfig(loader) }
+	admin.POST("/reload", handlers.ReloadConfig(reload))
+	admin.GET("/audit", h.ListAuditLog)
+	admin.GET("/reports", h.ListReports)
+	admin.GET("/metrics", handlers.FilterMetrics)
+	admin.GET("/queue", h.ListPending)
+	admin.POST("/queue/approve", h.ReviewQueue(true))
+	admin.POST("/queue/reject", h.ReviewQueue(false))
+	admin.DELETE("/posts/:id/reports", h.Moderate(models.ActionDismissPostReports))
+	admin.DELETE("/posts/:id/comments/:commentId/reports", h.Moderate(models.ActionDismissCommentReports))
+	admin.DELETE("/posts/:id", h.Moderate(models.ActionDeletePost))
+	admin.POST("/posts/:id/hide", h.Moderate(models.ActionHidePost))
+	admin.POST("/posts/:id/unhide", h.Moderate(models.ActionUnhidePost))
+	admin.POST("/posts/:id/lock", h.Moderate(models.ActionLockPost))
+	admin.POST("/posts/:id/unlock", h.Moderate(models.ActionUnlockPost))
+	admin.POST("/posts/:id/pin", h.Moderate(models.ActionPinPost))
+	admin.POST("/posts/:id/unpin", h.Moderate(models.ActionUnpinPost))
+	admin.PUT("/posts/:id/delete-at", h.Moderate(models.ActionSetDeleteAt))
+	admin.DELETE("/posts/:id/comments/:commentId", h.Moderate(models.ActionDeleteComment))
+	admin.POST("/posts/:id/comments/:commentId/hide", h.Moderate(models.ActionHideComment))
+	admin.POST("/posts/:id/comments/:commentId/unhide", h.Moderate(models.ActionUnhideComment))
+	admin.POST("/posts/:id/approve", h.Moderate(models.ActionApprovePost))
+	admin.POST("/posts/:id/reject", h.Moderate(models.ActionRejectPost))
+	admin.POST("/posts/:id/comments/:commentId/approve", h.Moderate(models.ActionApproveComment))
+	admin.POST("/posts/:id/comments/:commentId/reject", h.Moderate(models.ActionRejectComment))
+
 	// 设置优雅关闭
 	srv := &http.Server{
-		Addr:    ":" + utils.Config.ServerPort,
+		Addr:    ":" + cfg.ServerPort,
 		Handler: r,
 	}
 
 	// 在一个单独的goroutine中启动服务器
+	serverErr := make(chan error, 1)
 	go func() {
-		log.Printf("NilBBS服务启动在 http://localhost:%s", utils.Config.ServerPort)
+		log.Printf("NilBBS服务启动在 http://localhost:%s", cfg.ServerPort)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("启动服务器失败: %v", err)
+			serverErr <- err
+		}
+	}()
+
+	// 收到 SIGHUP 时重新加载配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("收到 SIGHUP，重新加载配置...")
+			reloadConfig(loader)
 		}
 	}()
 
-	// 等待中断信号
+	// 等待中断信号或服务器出错
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	log.Println("正在关闭服务器...")
-}
\ No newline at end of file
+	exitCode := 0
+	select {
+	case sig := <-quit:
+		log.Printf("收到信号 %v，正在关闭服务器...", sig)
+	case err := <-serverErr:
+		log.Printf("启动服务器失败: %v", err)
+		exitCode = 1
+	}
+
+	if !gracefulShutdown(srv, cleanupTask, store, utils.CurrentConfig().ShutdownTimeout) {
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,129 @@
+// Package markup 把帖子和评论的内容渲染为HTML。
+// 纯文本只转义并保留换行；Markdown 只支持强调、代码、列表、链接和剧透这一子集。
+// 两种格式的输出最后都经过白名单过滤，只保留允许的标签和属性。
+package markup
+
+import (
+	"bytes"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	goldhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// 内容格式
+const (
+	// FormatPlain 纯文本，默认格式
+	FormatPlain = "plain"
+	// FormatMarkdown Markdown子集，需要作者主动选择
+	FormatMarkdown = "markdown"
+)
+
+// ParseFormat 解析内容格式，空字符串为 FormatPlain
+func ParseFormat(s string) (string, bool) {
+	switch s {
+	case "", FormatPlain:
+		return FormatPlain, true
+	case FormatMarkdown:
+		return FormatMarkdown, true
+	}
+	return "", false
+}
+
+// markdown 只启用子集需要的语法：标题、引用块、分隔线和原始HTML都按普通文本处理，
+// 以免与 >>id 引用冲突，也不允许内容中直接写HTML
+var markdown = goldmark.New(
+	goldmark.WithParser(parser.NewParser(
+		parser.WithBlockParsers(
+			util.Prioritized(parser.NewListParser(), 300),
+			util.Prioritized(parser.NewListItemParser(), 400),
+			util.Prioritized(parser.NewCodeBlockParser(), 500),
+			util.Prioritized(parser.NewFencedCodeBlockParser(), 700),
+			util.Prioritized(parser.NewParagraphParser(), 1000),
+		),
+		parser.WithInlineParsers(
+			util.Prioritized(parser.NewCodeSpanParser(), 100),
+			util.Prioritized(parser.NewLinkParser(), 200),
+			util.Prioritized(parser.NewAutoLinkParser(), 300),
+			util.Prioritized(parser.NewEmphasisParser(), 500),
+			util.Prioritized(spoilerParser{}, 600),
+		),
+		parser.WithParagraphTransformers(
+			util.Prioritized(parser.LinkReferenceParagraphTransformer, 100),
+		),
+	)),
+	goldmark.WithRendererOptions(
+		// 单个换行也显示为换行，与纯文本一致
+		goldhtml.WithHardWraps(),
+		renderer.WithNodeRenderers(
+			util.Prioritized(spoilerRenderer{}, 500),
+			util.Prioritized(imageRenderer{}, 500),
+		),
+	),
+)
+
+// policy 允许的标签和属性，链接只允许相对地址和 http、https、mailto
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "em", "strong", "code", "pre", "ul", "ol", "li")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^spoiler$`)).OnElements("span")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render 按格式把内容渲染为经过过滤的HTML，未知格式按纯文本处理
+func Render(content, format string) string {
+	var out string
+	if format == FormatMarkdown {
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(content), &buf); err != nil {
+			log.Printf("渲染Markdown失败: %v", err)
+			out = renderPlain(content)
+		} else {
+			out = buf.String()
+		}
+	} else {
+		out = renderPlain(content)
+	}
+	return policy.Sanitize(out)
+}
+
+// renderPlain 转义纯文本并把换行转换为 <br>
+func renderPlain(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return strings.ReplaceAll(html.EscapeString(content), "\n", "<br>\n")
+}
+
+// imageRenderer 不显示图片，把图片渲染为指向图片地址的链接，链接文字为图片的说明
+type imageRenderer struct{}
+
+func (imageRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindImage, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = w.WriteString(`<a href="`)
+			_, _ = w.Write(util.EscapeHTML(util.URLEscape(n.(*ast.Image).Destination, true)))
+			_, _ = w.WriteString(`">`)
+		} else {
+			_, _ = w.WriteString("</a>")
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,78 @@
+package markup
+
+import (
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// 剧透：||内容|| 渲染为 <span class="spoiler">，点击后才显示
+
+// kindSpoiler 剧透节点的类型
+var kindSpoiler = ast.NewNodeKind("Spoiler")
+
+// spoiler 剧透节点，子节点为被隐藏的内容
+type spoiler struct {
+	ast.BaseInline
+}
+
+func (n *spoiler) Kind() ast.NodeKind {
+	return kindSpoiler
+}
+
+func (n *spoiler) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// spoilerDelimiter 处理成对的 || 分隔符
+type spoilerDelimiter struct{}
+
+func (spoilerDelimiter) IsDelimiter(b byte) bool {
+	return b == '|'
+}
+
+func (spoilerDelimiter) CanOpenCloser(opener, closer *parser.Delimiter) bool {
+	return opener.Char == closer.Char
+}
+
+func (spoilerDelimiter) OnMatch(consumes int) ast.Node {
+	return &spoiler{}
+}
+
+// spoilerParser 识别至少两个连续的 |
+type spoilerParser struct{}
+
+func (spoilerParser) Trigger() []byte {
+	return []byte{'|'}
+}
+
+func (spoilerParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	before := block.PrecendingCharacter()
+	line, segment := block.PeekLine()
+	node := parser.ScanDelimiter(line, before, 2, spoilerDelimiter{})
+	if node == nil {
+		return nil
+	}
+	node.Segment = segment.WithStop(segment.Start + node.OriginalLength)
+	block.Advance(node.OriginalLength)
+	pc.PushDelimiter(node)
+	return node
+}
+
+func (spoilerParser) CloseBlock(parent ast.Node, pc parser.Context) {}
+
+// spoilerRenderer 输出剧透节点
+type spoilerRenderer struct{}
+
+func (spoilerRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindSpoiler, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = w.WriteString(`<span class="spoiler">`)
+		} else {
+			_, _ = w.WriteString("</span>")
+		}
+		return ast.WalkContinue, nil
+	})
+}
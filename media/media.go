@@ -0,0 +1,161 @@
+// Package media 检查和处理上传的附件。文件类型根据内容判断，不信任客户端提供的类型和扩展名。
+// 图片会重新编码，以去掉EXIF等元数据（拍摄位置、设备信息），并生成缩略图。
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// ErrUnsupportedType 文件类型不在允许的范围内
+var ErrUnsupportedType = errors.New("不支持的文件类型")
+
+// ErrImageTooLarge 图片的像素数超过 MaxPixels
+var ErrImageTooLarge = errors.New("图片尺寸过大")
+
+// MaxPixels 允许的图片最大像素数，防止解码很小的文件占用大量内存
+const MaxPixels = 40_000_000
+
+// ThumbnailSize 缩略图的最大宽度和高度
+const ThumbnailSize = 320
+
+// 允许上传的非图片文件，按原样保存，下载时不在浏览器中打开
+var fileTypes = map[string]bool{
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// File 处理后的附件
+type File struct {
+	// ContentType 根据内容判断出的类型
+	ContentType string
+	// Data 保存的内容，图片为重新编码后的内容
+	Data []byte
+	// Width、Height 图片的尺寸，其他文件为0
+	Width, Height int
+	// Thumbnail 缩略图，图片不超过缩略图尺寸或不是图片时为空
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// IsImage 判断类型是否为支持的图片
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// Sniff 根据内容判断文件类型，去掉 charset 等参数
+func Sniff(data []byte) string {
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	return contentType
+}
+
+// Process 检查文件类型，图片去掉元数据并生成缩略图
+func Process(data []byte) (*File, error) {
+	contentType := Sniff(data)
+	if fileTypes[contentType] {
+		return &File{ContentType: contentType, Data: data}, nil
+	}
+	if !IsImage(contentType) {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	var out bytes.Buffer
+	var first image.Image
+	switch contentType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+		// 重新编码会丢掉EXIF，先按其中的方向信息旋转图片，避免显示方向错误
+		first = orient(img, jpegOrientation(data))
+		err = jpeg.Encode(&out, first, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return nil, err
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrUnsupportedType
+		}
+		first = img
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+	case "image/gif":
+		// 保留动画的所有帧，注释和应用扩展不会写回
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(g.Image) == 0 {
+			return nil, ErrUnsupportedType
+		}
+		if len(g.Image)*cfg.Width*cfg.Height > MaxPixels {
+			return nil, ErrImageTooLarge
+		}
+		first = g.Image[0]
+		if err := gif.EncodeAll(&out, g); err != nil {
+			return nil, err
+		}
+	}
+
+	bounds := first.Bounds()
+	f := &File{ContentType: contentType, Data: out.Bytes(), Width: bounds.Dx(), Height: bounds.Dy()}
+	if err := f.thumbnail(first); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// thumbnail 把图片等比缩小到 ThumbnailSize 以内
+func (f *File) thumbnail(img image.Image) error {
+	if f.Width <= ThumbnailSize && f.Height <= ThumbnailSize {
+		return nil
+	}
+	w, h := ThumbnailSize, ThumbnailSize
+	if f.Width > f.Height {
+		h = max(1, f.Height*ThumbnailSize/f.Width)
+	} else {
+		w = max(1, f.Width*ThumbnailSize/f.Height)
+	}
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var out bytes.Buffer
+	f.ThumbnailContentType = ThumbnailContentType(f.ContentType)
+	if f.ThumbnailContentType == "image/jpeg" {
+		if err := jpeg.Encode(&out, thumb, &jpeg.Options{Quality: 80}); err != nil {
+			return err
+		}
+	} else if err := png.Encode(&out, thumb); err != nil {
+		return err
+	}
+	f.Thumbnail = out.Bytes()
+	return nil
+}
+
+// ThumbnailContentType 返回该类型图片的缩略图的类型：JPEG 的缩略图为 JPEG，其余为保留透明度的 PNG
+func ThumbnailContentType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
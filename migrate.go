@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// runMigrate 处理 migrate 子命令：status 查看迁移状态，up 执行尚未执行的迁移
+func runMigrate(args []string) int {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if action != "status" && action != "up" {
+		fmt.Printf("用法: nilbbs migrate [status|up]\n")
+		return 2
+	}
+
+	store, err := database.OpenSQL(utils.CurrentConfig().DatabaseDSN)
+	if err != nil {
+		log.Printf("打开数据库失败: %v", err)
+		return 1
+	}
+	defer store.Close()
+
+	m, err := store.Migrator()
+	if err != nil {
+		log.Printf("加载迁移脚本失败: %v", err)
+		return 1
+	}
+
+	current, pending, err := m.Status()
+	if err != nil {
+		log.Printf("读取迁移状态失败: %v", err)
+		return 1
+	}
+
+	if action == "status" {
+		fmt.Printf("当前版本: %d\n最新版本: %d\n", current, m.Latest())
+		if len(pending) == 0 {
+			fmt.Println("数据库已是最新")
+		}
+		for _, mig := range pending {
+			fmt.Printf("待执行: %04d_%s\n", mig.Version, mig.Name)
+		}
+		return 0
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	fmt.Printf("已执行 %d 个迁移，当前版本: %d\n", len(applied), m.Latest())
+	return 0
+}
@@ -11,7 +11,34 @@ type Post struct {
 	Author    string    `json:"author"`
 	CreatedAt time.Time `json:"created_at"`
 	DeleteAt  time.Time `json:"delete_at"`
-	Comments  []Comment `json:"comments,omitempty"`
+	// 发帖人ID，同一客户端当天在同一帖子中的帖子和评论相同，不同帖子之间无法关联
+	PosterID string `json:"poster_id,omitempty"`
+	// 内容格式：plain 或 markdown
+	Format string `json:"format"`
+	// 按格式渲染并过滤后的HTML，只在帖子详情中返回
+	ContentHTML string `json:"content_html,omitempty"`
+	// 最后活动时间：最新评论的时间，没有评论时为创建时间
+	LastActivityAt time.Time `json:"last_activity_at"`
+	CommentCount   int       `json:"comment_count"`
+	// 是否被作者修改过，EditedAt 为最后一次修改的时间
+	Edited   bool       `json:"edited"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// 管理员锁定的帖子不能再评论，置顶的帖子显示在列表最前面
+	Locked bool `json:"locked,omitempty"`
+	Pinned bool `json:"pinned,omitempty"`
+	// 被管理员隐藏，隐藏的帖子不对外返回
+	Hidden bool `json:"-"`
+	// 审核状态，只有通过审核的帖子公开显示，作者可以用编辑令牌查看自己的帖子
+	Status string `json:"status"`
+	// 内容中引用的评论和帖子，只在帖子详情中返回
+	Quotes []QuoteLink `json:"quotes,omitempty"`
+	// 帖子本身的附件，只在帖子详情中返回
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// 内容摘要，只在帖子列表中返回
+	Excerpt  string    `json:"excerpt,omitempty"`
+	Comments []Comment `json:"comments,omitempty"`
+	// 编辑令牌的哈希，不对外返回
+	EditTokenHash string `json:"-"`
 }
 
 // Comment 评论模型
@@ -21,4 +48,219 @@ type Comment struct {
 	PostID    int64     `json:"post_id"`
 	Author    string    `json:"author"`
 	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	// 发帖人ID，与帖子的 PosterID 相同时是楼主
+	PosterID string `json:"poster_id,omitempty"`
+	// 内容格式：plain 或 markdown
+	Format string `json:"format"`
+	// 按格式渲染并过滤后的HTML
+	ContentHTML string `json:"content_html,omitempty"`
+	// 回复的评论ID，顶层评论为 0
+	ParentCommentID int64 `json:"parent_comment_id,omitempty"`
+	// 所在讨论串的顶层评论ID，顶层评论为 0
+	ThreadID int64 `json:"-"`
+	// 嵌套层级，顶层评论为 0
+	Depth int `json:"depth"`
+	// 有回复的评论被删除后保留为占位，内容为空
+	Deleted bool `json:"deleted,omitempty"`
+	// 被管理员隐藏的评论同样保留为占位，不返回内容
+	Hidden bool `json:"hidden,omitempty"`
+	// 审核状态，未通过审核的评论不在评论列表中
+	Status string `json:"status"`
+	// 内容中引用的评论和帖子
+	Quotes []QuoteLink `json:"quotes,omitempty"`
+	// 引用了这条评论的帖子和评论
+	Backlinks []Backlink `json:"backlinks,omitempty"`
+	// 评论的附件
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// 是否被作者修改过，EditedAt 为最后一次修改的时间
+	Edited   bool       `json:"edited"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// 编辑令牌的哈希，不对外返回
+	EditTokenHash string `json:"-"`
+}
+
+// QuoteLink 内容中的一个引用：>>id 引用评论，>>>id 引用帖子
+type QuoteLink struct {
+	// 被引用的评论或帖子的ID
+	ID int64 `json:"id"`
+	// 为 true 表示引用帖子
+	Post bool `json:"post,omitempty"`
+	// 链接指向的帖子：被引用的帖子，或被引用的评论所在的帖子；目标不存在时为 0
+	PostID int64 `json:"post_id,omitempty"`
+	// 目标已删除或已过期
+	Dead bool `json:"dead"`
+}
+
+// Backlink 引用了某条评论的帖子正文或评论
+type Backlink struct {
+	PostID int64 `json:"post_id"`
+	// 帖子正文中的引用为 0
+	CommentID int64 `json:"comment_id,omitempty"`
+}
+
+// Attachment 帖子或评论的附件
+type Attachment struct {
+	ID     int64 `json:"id"`
+	PostID int64 `json:"post_id"`
+	// 帖子本身的附件为 0
+	CommentID int64 `json:"comment_id,omitempty"`
+	// 上传时的文件名
+	Filename string `json:"filename"`
+	// 根据内容判断出的类型
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	// 图片的尺寸，其他文件为 0
+	Width     int       `json:"width,omitempty"`
+	Height    int       `json:"height,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// 下载地址和缩略图地址，由处理器填入
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	// 文件和缩略图在附件存储中的键，不对外返回
+	BlobKey      string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+// Revision 帖子或评论修改前的一个版本
+type Revision struct {
+	ID     int64 `json:"id"`
+	PostID int64 `json:"post_id"`
+	// 评论的修改历史才有 CommentID
+	CommentID int64  `json:"comment_id,omitempty"`
+	Content   string `json:"content"`
+	// 这一版本被替换（即被修改）的时间
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// 帖子和评论的审核状态
+const (
+	// StatusApproved 已公开，不需要审核的内容创建后即为该状态
+	StatusApproved = "approved"
+	// StatusPending 等待管理员审核，只有作者能看到
+	StatusPending = "pending"
+	// StatusRejected 管理员拒绝公开，到期后与其他内容一样被删除
+	StatusRejected = "rejected"
+)
+
+// 管理操作，记录在审计日志中
+const (
+	ActionDeletePost    = "delete_post"
+	ActionDeleteComment = "delete_comment"
+	ActionHidePost      = "hide_post"
+	ActionUnhidePost    = "unhide_post"
+	ActionHideComment   = "hide_comment"
+	ActionUnhideComment = "unhide_comment"
+	ActionLockPost      = "lock_post"
+	ActionUnlockPost    = "unlock_post"
+	ActionPinPost       = "pin_post"
+	ActionUnpinPost     = "unpin_post"
+	ActionSetDeleteAt   = "set_delete_at"
+	// 处理完举报后清空帖子或评论的举报
+	ActionDismissPostReports    = "dismiss_post_reports"
+	ActionDismissCommentReports = "dismiss_comment_reports"
+	// 审核等待审核的帖子或评论；被拒绝的内容也可以重新通过
+	ActionApprovePost    = "approve_post"
+	ActionRejectPost     = "reject_post"
+	ActionApproveComment = "approve_comment"
+	ActionRejectComment  = "reject_comment"
+	// 把帖子或评论放回审核队列：内容修改后命中过滤规则，或在先审后发模式下被修改
+	ActionHoldPost    = "hold_post"
+	ActionHoldComment = "hold_comment"
+)
+
+// 自动执行的管理操作在审计日志中记录的操作者
+const (
+	// ActorAuto 举报达到阈值自动隐藏，或先审后发模式下修改的内容放回审核队列
+	ActorAuto = "auto"
+	// ActorFilter 内容命中过滤规则被隐藏或放入审核队列
+	ActorFilter = "filter"
+)
+
+// AuditEntry 审计日志中的一条管理操作
+type AuditEntry struct {
+	ID     int64  `json:"id"`
+	Action string `json:"action"`
+	PostID int64  `json:"post_id"`
+	// 针对评论的操作才有 CommentID
+	CommentID int64 `json:"comment_id,omitempty"`
+	// 修改删除时间时的新值
+	DeleteAt *time.Time `json:"delete_at,omitempty"`
+	Reason   string     `json:"reason,omitempty"`
+	// 执行操作的管理员：管理令牌为 token，登录会话为 session: 加会话标识的前缀
+	Actor     string    `json:"actor"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 举报原因
+const (
+	ReportSpam    = "spam"
+	ReportAbuse   = "abuse"
+	ReportIllegal = "illegal"
+	ReportOther   = "other"
+)
+
+// ValidReportReason 判断举报原因是否有效
+func ValidReportReason(reason string) bool {
+	switch reason {
+	case ReportSpam, ReportAbuse, ReportIllegal, ReportOther:
+		return true
+	}
+	return false
+}
+
+// Report 读者对帖子或评论的一次举报，Reporter 为举报者客户端标识的哈希，用于去重
+type Report struct {
+	ID        int64
+	PostID    int64
+	CommentID int64
+	Reason    string
+	Reporter  string
+	CreatedAt time.Time
+}
+
+// ReportSummary 举报队列中的一项：同一帖子或评论的全部举报
+type ReportSummary struct {
+	PostID int64 `json:"post_id"`
+	// 针对评论的举报才有 CommentID
+	CommentID int64 `json:"comment_id,omitempty"`
+	// 举报次数，每个客户端只计一次
+	Count int `json:"count"`
+	// 各举报原因的次数
+	Reasons map[string]int `json:"reasons"`
+	// 被举报的内容，已被隐藏的内容也会列出
+	Content         string    `json:"content"`
+	Author          string    `json:"author"`
+	Hidden          bool      `json:"hidden"`
+	FirstReportedAt time.Time `json:"first_reported_at"`
+	LastReportedAt  time.Time `json:"last_reported_at"`
+}
+
+// PendingItem 审核队列中的一项：等待审核的帖子或评论
+type PendingItem struct {
+	PostID int64 `json:"post_id"`
+	// 评论才有 CommentID
+	CommentID int64     `json:"comment_id,omitempty"`
+	Content   string    `json:"content"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+	// 所在帖子的删除时间，等待审核的内容到期后同样被删除
+	DeleteAt time.Time `json:"delete_at"`
+}
+
+// 搜索结果的来源类型
+const (
+	SearchTypePost    = "post"
+	SearchTypeComment = "comment"
+)
+
+// SearchResult 搜索结果，Snippet 为高亮后的内容片段（已转义的HTML）
+type SearchResult struct {
+	Type      string    `json:"type"`
+	PostID    int64     `json:"post_id"`
+	CommentID int64     `json:"comment_id,omitempty"`
+	Author    string    `json:"author"`
+	Content   string    `json:"-"`
+	Snippet   string    `json:"snippet"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -3,18 +3,33 @@ package nickname
 import (
 	"bufio"
 	"fmt"
+	"io/fs"
 	"log"
 	"math/rand"
-	"os"
 	"strings"
+	"sync/atomic"
+
+	"github.com/Mammoth777/nilbbs/assets"
 )
 
-var adjectives []string
-var nouns []string
+// wordLists holds both datasets so they can be swapped together.
+type wordLists struct {
+	adjectives []string
+	nouns      []string
+}
+
+// lists is replaced atomically by Load, so nicknames can be generated while datasets are reloaded.
+var lists atomic.Pointer[wordLists]
 
-// loadWordsFromFile reads lines from a file and returns them as a slice of strings.
-func loadWordsFromFile(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
+// Dataset file paths, relative to the root of the filesystem passed to Load.
+const (
+	adjectivesFile = "dataset/adjectives.txt"
+	nounsFile      = "dataset/nouns.txt"
+)
+
+// loadWordsFromFile reads lines from a file in fsys and returns them as a slice of strings.
+func loadWordsFromFile(fsys fs.FS, filePath string) ([]string, error) {
+	file, err := fsys.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
@@ -41,37 +56,36 @@ func loadWordsFromFile(filePath string) ([]string, error) {
 	return words, nil
 }
 
-func init() {
-	var err error
-	// Load adjectives from file
-	adjectives, err = loadWordsFromFile("assets/dataset/adjectives.txt")
+// Load replaces the word lists with the datasets found in fsys.
+// The current lists are kept if either file fails to load.
+func Load(fsys fs.FS) error {
+	adj, err := loadWordsFromFile(fsys, adjectivesFile)
 	if err != nil {
-		log.Printf("Error loading adjectives from adjectives.txt: %v. Using default list.", err)
+		return err
 	}
-
-	// Load nouns from file
-	nouns, err = loadWordsFromFile("assets/dataset/nouns.txt")
+	n, err := loadWordsFromFile(fsys, nounsFile)
 	if err != nil {
-		log.Printf("Error loading nouns from nouns.txt: %v. Using default list.", err)
+		return err
 	}
+	lists.Store(&wordLists{adjectives: adj, nouns: n})
+	return nil
+}
 
-	// Ensure lists are not empty after attempting to load or falling back
-	if len(adjectives) == 0 {
-		log.Fatal("Adjectives list is empty after init.")
-	}
-	if len(nouns) == 0 {
-		log.Fatal("Nouns list is empty after init.")
+func init() {
+	// The embedded datasets are always present, so a failure here is a build problem.
+	if err := Load(assets.Dataset); err != nil {
+		log.Fatalf("Error loading embedded nickname datasets: %v", err)
 	}
-
 }
 
 func GetRandomNickname() string {
-	if len(adjectives) == 0 || len(nouns) == 0 {
+	l := lists.Load()
+	if l == nil || len(l.adjectives) == 0 || len(l.nouns) == 0 {
 		// This should ideally not happen due to checks in init, but as a safeguard:
 		log.Println("Warning: Adjectives or nouns list is empty, returning default nickname.")
 		return "默认昵称"
 	}
-	adjIndex := rand.Intn(len(adjectives))
-	nounIndex := rand.Intn(len(nouns))
-	return fmt.Sprintf("%s%s", adjectives[adjIndex], nouns[nounIndex])
-}
\ No newline at end of file
+	adjIndex := rand.Intn(len(l.adjectives))
+	nounIndex := rand.Intn(len(l.nouns))
+	return fmt.Sprintf("%s%s", l.adjectives[adjIndex], l.nouns[nounIndex])
+}
@@ -0,0 +1,216 @@
+// Package pow 实现 hashcash 式的工作量证明，用来在不使用验证码的情况下增加批量发帖的成本。
+//
+// 服务器签发带签名和有效期的挑战，客户端寻找一个 nonce，使 SHA-256(挑战 + ":" + nonce)
+// 的前导零位数不少于挑战的难度。挑战本身不需要保存，只有使用过的挑战在过期前被记住，防止重放。
+// 难度随最近的发帖频率自动提高，每翻一倍增加一位，即客户端的平均计算量翻倍。
+package pow
+
+import (
+	"container/heap"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalid 挑战格式错误或签名不匹配
+	ErrInvalid = errors.New("工作量证明无效")
+	// ErrExpired 挑战已过期
+	ErrExpired = errors.New("工作量证明已过期")
+	// ErrReplayed 挑战已经被使用过
+	ErrReplayed = errors.New("工作量证明已被使用")
+	// ErrInsufficientWork 哈希的前导零位数不足
+	ErrInsufficientWork = errors.New("工作量不足")
+)
+
+// ChallengeTTL 挑战的有效期
+const ChallengeTTL = 10 * time.Minute
+
+// MaxDifficulty 难度的上限，更高的难度在浏览器中无法在有效期内完成
+const MaxDifficulty = 32
+
+// LoadWindow 统计发帖频率的时间窗口
+const LoadWindow = 10 * time.Minute
+
+// BaselineLoad 时间窗口内的发帖数不超过它时使用基础难度
+const BaselineLoad = 20
+
+// 挑战签名前的内容：16字节随机数、1字节难度、8字节过期时间
+const payloadSize = 16 + 1 + 8
+
+// Challenge 签发给客户端的挑战
+type Challenge struct {
+	Challenge  string    `json:"challenge"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Issuer 签发和校验挑战，记录使用过的挑战和最近的发帖频率
+type Issuer struct {
+	secret []byte
+
+	mu sync.Mutex
+	// used 使用过且未过期的挑战及其过期时间；expiry 是按过期时间排列的最小堆，
+	// 挑战的使用顺序与签发顺序不同，过期时间需要排序
+	used   map[string]time.Time
+	expiry expiryHeap
+	// load 按 LoadWindow 指数衰减的发帖数，loadAt 为上次更新的时间
+	load   float64
+	loadAt time.Time
+}
+
+// NewIssuer 创建使用 secret 签名的 Issuer，secret 为空时随机生成，重启后之前签发的挑战失效
+func NewIssuer(secret []byte) (*Issuer, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &Issuer{
+		secret: secret,
+		used:   make(map[string]time.Time),
+	}, nil
+}
+
+// Difficulty 根据最近的发帖频率计算难度：频率不超过 BaselineLoad 时为 base，之后每翻一倍加一，不超过 limit
+func (i *Issuer) Difficulty(base, limit int, now time.Time) int {
+	if base <= 0 {
+		return 0
+	}
+	i.mu.Lock()
+	load := i.decayedLoad(now)
+	i.mu.Unlock()
+
+	d := base
+	if load > BaselineLoad {
+		d += int(math.Ceil(math.Log2(load / BaselineLoad)))
+	}
+	return min(d, max(base, limit), MaxDifficulty)
+}
+
+// decayedLoad 返回衰减到 now 的发帖数，调用方需持有锁
+func (i *Issuer) decayedLoad(now time.Time) float64 {
+	if i.loadAt.IsZero() || !now.After(i.loadAt) {
+		return i.load
+	}
+	return i.load * math.Exp(-float64(now.Sub(i.loadAt))/float64(LoadWindow))
+}
+
+// Issue 签发难度为 difficulty 的挑战
+func (i *Issuer) Issue(difficulty int, now time.Time) (Challenge, error) {
+	if difficulty < 0 || difficulty > MaxDifficulty {
+		return Challenge{}, errors.New("难度超出范围")
+	}
+	payload := make([]byte, payloadSize)
+	if _, err := rand.Read(payload[:16]); err != nil {
+		return Challenge{}, err
+	}
+	expiresAt := now.Add(ChallengeTTL)
+	payload[16] = byte(difficulty)
+	binary.BigEndian.PutUint64(payload[17:], uint64(expiresAt.Unix()))
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return Challenge{
+		Challenge:  encoded + "." + i.sign(encoded),
+		Difficulty: difficulty,
+		ExpiresAt:  time.Unix(expiresAt.Unix(), 0).UTC(),
+	}, nil
+}
+
+func (i *Issuer) sign(encoded string) string {
+	mac := hmac.New(sha256.New, i.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验挑战的签名、有效期和客户端给出的 nonce，成功后记住挑战，同一挑战只能使用一次，并计入发帖频率
+func (i *Issuer) Verify(challenge, nonce string, now time.Time) error {
+	encoded, sig, ok := strings.Cut(challenge, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(i.sign(encoded))) {
+		return ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) != payloadSize {
+		return ErrInvalid
+	}
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[17:])), 0)
+	if !now.Before(expiresAt) {
+		return ErrExpired
+	}
+	if len(nonce) == 0 || len(nonce) > 64 || LeadingZeros(challenge, nonce) < int(payload[16]) {
+		return ErrInsufficientWork
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.prune(now)
+	if _, ok := i.used[challenge]; ok {
+		return ErrReplayed
+	}
+	i.used[challenge] = expiresAt
+	heap.Push(&i.expiry, usedChallenge{challenge, expiresAt})
+
+	i.load = i.decayedLoad(now) + 1
+	i.loadAt = now
+	return nil
+}
+
+// prune 忘记已过期的挑战，它们会因过期被拒绝，不需要再记住，调用方需持有锁
+func (i *Issuer) prune(now time.Time) {
+	for len(i.expiry) > 0 && !now.Before(i.expiry[0].expiresAt) {
+		delete(i.used, heap.Pop(&i.expiry).(usedChallenge).challenge)
+	}
+}
+
+// usedChallenge 使用过的挑战及其过期时间
+type usedChallenge struct {
+	challenge string
+	expiresAt time.Time
+}
+
+// expiryHeap 实现 heap.Interface，最早过期的挑战在堆顶
+type expiryHeap []usedChallenge
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(a, b int) bool  { return h[a].expiresAt.Before(h[b].expiresAt) }
+func (h expiryHeap) Swap(a, b int)       { h[a], h[b] = h[b], h[a] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(usedChallenge)) }
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// LeadingZeros 返回 SHA-256(challenge + ":" + nonce) 的前导零位数
+func LeadingZeros(challenge, nonce string) int {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Solve 寻找满足难度的 nonce，供测试和命令行客户端使用
+func Solve(challenge string, difficulty int) string {
+	for n := 0; ; n++ {
+		nonce := strconv.Itoa(n)
+		if LeadingZeros(challenge, nonce) >= difficulty {
+			return nonce
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Mammoth777/nilbbs/filter"
+	"github.com/Mammoth777/nilbbs/nickname"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// reloadMu 保证 SIGHUP 和管理接口触发的重新加载不会交错执行
+var reloadMu sync.Mutex
+
+// reloadConfig 重新读取配置，应用可以在运行中修改的配置项，并重新读取覆盖目录中的昵称词库和内容过滤规则。
+// 新配置无效、词库或过滤规则加载失败时返回错误，当前配置保持不变
+func reloadConfig(loader *utils.ConfigLoader) (utils.ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	// 覆盖目录需要重启才能修改，先用当前目录加载词库，失败时不修改配置
+	dir := utils.CurrentConfig().OverrideDir
+	if dir != "" {
+		if err := nickname.Load(nicknameDatasets(dir)); err != nil {
+			log.Printf("重新加载昵称词库失败，继续使用当前配置: %v", err)
+			return utils.ReloadResult{}, fmt.Errorf("重新加载昵称词库失败: %w", err)
+		}
+	}
+
+	// 按新配置中的路径读取内容过滤规则，规则无效时不修改配置
+	next, _, err := loader.Load()
+	if err != nil {
+		log.Printf("重新加载配置失败，继续使用当前配置: %v", err)
+		return utils.ReloadResult{}, err
+	}
+	rules, err := filter.Open(next.FilterRules)
+	if err != nil {
+		log.Printf("重新加载内容过滤规则失败，继续使用当前配置: %v", err)
+		return utils.ReloadResult{}, fmt.Errorf("重新加载内容过滤规则失败: %w", err)
+	}
+
+	// 只读取一次配置，应用的就是上面检查过的配置
+	result, err := utils.ReloadConfig(next)
+	if err != nil {
+		log.Printf("重新加载配置失败，继续使用当前配置: %v", err)
+		return result, err
+	}
+	if dir != "" {
+		result.Applied = append(result.Applied, "nickname_datasets")
+	}
+
+	// 规则文件的内容可能变了，即使路径没有修改也使用重新读取的规则
+	filter.Use(rules)
+	if next.FilterRules != "" && !slices.Contains(result.Applied, "filter_rules") {
+		result.Applied = append(result.Applied, "filter_rules")
+	}
+
+	log.Printf("配置已重新加载，已生效的修改: %s", joinKeys(result.Applied))
+	if len(result.RestartRequired) > 0 {
+		log.Printf("以下配置需要重启才能生效: %s", joinKeys(result.RestartRequired))
+	}
+	return result, nil
+}
+
+func joinKeys(keys []string) string {
+	if len(keys) == 0 {
+		return "无"
+	}
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// gracefulShutdown 按顺序关闭服务：停止接收新连接并等待处理中的请求完成、
+// 停止定时任务、关闭数据库。所有步骤共用一个超时时间，全部正常完成时返回 true。
+// 清理任务没能在超时前停止时不关闭数据库
+func gracefulShutdown(srv *http.Server, cleanupTask *utils.ScheduledTask, store database.Store, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	clean := true
+
+	// 1. 停止接收新连接，等待处理中的请求完成
+	log.Printf("停止接收新请求，等待处理中的请求完成（超时 %v）...", timeout)
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("HTTP服务器未能正常关闭: %v", err)
+		clean = false
+	} else {
+		log.Println("HTTP服务器已关闭")
+	}
+
+	// 2. 停止定时任务，正在执行的清理任务会先执行完
+	stopped := make(chan struct{})
+	go func() {
+		cleanupTask.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		log.Println("清理任务已停止")
+	case <-ctx.Done():
+		// 清理任务可能正在事务中删除帖子或清理附件，此时关闭数据库会让它中途失败，
+		// 因此不关闭数据库，由进程退出时释放
+		log.Println("等待清理任务停止超时，清理任务仍在执行，跳过关闭数据库")
+		log.Println("服务器关闭时出现错误")
+		return false
+	}
+
+	// 3. 写回SQLite的WAL并关闭数据库
+	if err := store.Close(); err != nil {
+		log.Printf("关闭数据库失败: %v", err)
+		clean = false
+	} else {
+		log.Println("数据库已关闭")
+	}
+
+	if clean {
+		log.Println("服务器已正常关闭")
+	} else {
+		log.Println("服务器关闭时出现错误")
+	}
+	return clean
+}
@@ -17,6 +17,9 @@ document.addEventListener('DOMContentLoaded', function() {
   // 确保昵称存在并显示
   initNickname();
   displayNickname();
+
+  // 滚动加载更多帖子和评论
+  setupInfiniteScroll();
 });
 
 // 向父窗口发送消息的统一方法
@@ -63,6 +66,7 @@ function loadHomeView() {
     // 已经在主页了，只需刷新数据
     loadPosts();
     setupQuickPostEvents();
+    setupSearchEvents();
     
     // 向父窗口发送切换到列表页的消息
     sendMessageToParent('nilbbs_navigation', {
@@ -82,6 +86,7 @@ function loadHomeView() {
         // 加载数据并设置事件
         loadPosts();
         setupQuickPostEvents();
+        setupSearchEvents();
         
         // 重新初始化昵称显示
         initNickname();
@@ -146,6 +151,57 @@ function navigateToPost(event, postId) {
   window.location.hash = `/post/${postId}`;
 }
 
+// 设置搜索事件：回车搜索，清空后恢复帖子列表
+function setupSearchEvents() {
+  const searchInput = document.getElementById('search-input');
+  if (!searchInput) return;
+  searchInput.addEventListener('keydown', function(e) {
+    if (e.key === 'Enter') {
+      searchPosts(searchInput.value.trim());
+    }
+  });
+  searchInput.addEventListener('search', function() {
+    if (!searchInput.value.trim()) {
+      loadPosts();
+    }
+  });
+}
+
+// 搜索帖子和评论，结果显示在帖子列表中
+async function searchPosts(query) {
+  const postList = document.getElementById('post-list');
+  if (!postList) return;
+  if (!query) {
+    loadPosts();
+    return;
+  }
+
+  try {
+    const response = await fetch(withTimezone('/api/search?q=' + encodeURIComponent(query)));
+    if (!response.ok) throw new Error('Failed to search');
+    const data = await response.json();
+    // 搜索结果不分页
+    postsNextCursor = '';
+
+    if (data.results && data.results.length > 0) {
+      // snippet 已由服务器转义并标记关键词
+      postList.innerHTML = data.results.map(result => `
+        <li class="post-item">
+          <div class="post-content"><a href="#" onclick="navigateToPost(event, ${result.post_id})">${result.snippet}</a></div>
+          <div class="post-meta">
+            <span class="post-meta-info">${escapeHTML(result.author)} · ${formatDate(result.created_at)}${result.type === 'comment' ? ' · comment' : ''}</span>
+          </div>
+        </li>
+      `).join('');
+    } else {
+      postList.innerHTML = '<li class="post-item">No results</li>';
+    }
+  } catch (error) {
+    console.error('Search failed:', error);
+    postList.innerHTML = '<li class="post-item">Failed to search</li>';
+  }
+}
+
 // 设置快速发帖事件
 function setupQuickPostEvents() {
   const quickPostContent = document.getElementById('quick-post-content');
@@ -173,10 +229,29 @@ function setupCommentEvents(postId) {
   }
 }
 
+// 浏览器所在时区，请求时传给服务器，由服务器返回该时区的时间
+function browserTimezone() {
+  try {
+    return Intl.DateTimeFormat().resolvedOptions().timeZone || '';
+  } catch (e) {
+    return '';
+  }
+}
+
+// 为API地址加上时区参数
+function withTimezone(url) {
+  const tz = browserTimezone();
+  if (!tz) return url;
+  return url + (url.includes('?') ? '&' : '?') + 'tz=' + encodeURIComponent(tz);
+}
+
+// 服务器返回的时间已经转换为请求的时区，直接显示其中的日期和时间
 function formatDate(dateString) {
+  const match = /^(\d{4}-\d{2}-\d{2})T(\d{2}:\d{2})/.exec(dateString);
+  if (match) {
+    return `${match[1]} ${match[2]}`;
+  }
   const d = new Date(dateString);
-  const str = d.toLocaleString('zh-CN', { timeZone: 'Asia/Shanghai' });
-  console.log('Formatted date:', str);
   return `${d.getFullYear()}-${String(d.getMonth() + 1).padStart(2, '0')}-${String(d.getDate()).padStart(2, '0')} ${String(d.getHours()).padStart(2, '0')}:${String(d.getMinutes()).padStart(2, '0')}`;
 }
 
@@ -266,46 +341,621 @@ function getCurrentNickname() {
   return sessionStorage.getItem('userNickname') || 'AnonymousUser';
 }
 
-// Load post list
-async function loadPosts() {
+// 转义插入HTML的纯文本，内容本身使用服务器渲染的 content_html
+function escapeHTML(text) {
+  const div = document.createElement('div');
+  div.textContent = text == null ? '' : String(text);
+  return div.innerHTML;
+}
+
+// 获取并计算工作量证明，返回发帖和评论时需要带上的请求头；服务器未开启时返回空对象
+async function proofOfWork() {
+  const response = await fetch('/api/pow/challenge', {cache: 'no-store'});
+  if (!response.ok) throw new Error('Failed to get challenge');
+  const {challenge, difficulty} = await response.json();
+  if (!difficulty) return {};
+  const nonce = await new Promise((resolve, reject) => {
+    const worker = new Worker('/static/js/pow-worker.js');
+    worker.onmessage = event => {
+      worker.terminate();
+      resolve(event.data.nonce);
+    };
+    worker.onerror = error => {
+      worker.terminate();
+      reject(error);
+    };
+    worker.postMessage({challenge, difficulty});
+  });
+  return {'X-Pow-Challenge': challenge, 'X-Pow-Nonce': nonce};
+}
+
+// 上传选择的附件，path 为帖子或评论的附件接口，全部成功时返回 true
+async function uploadAttachments(path, token) {
+  const input = document.getElementById('attachment-files');
+  if (!input || input.files.length === 0) return true;
+  let ok = true;
+  for (const file of input.files) {
+    const form = new FormData();
+    form.append('file', file);
+    const response = await fetch(path, {
+      method: 'POST',
+      headers: {'X-Edit-Token': token},
+      body: form
+    });
+    if (!response.ok) {
+      const data = await response.json().catch(() => ({}));
+      alert(`Failed to upload ${file.name}: ${data.error || response.status}`);
+      ok = false;
+    }
+  }
+  input.value = '';
+  return ok;
+}
+
+// 渲染附件：图片显示缩略图，点击打开原图；其他文件显示为下载链接
+function renderAttachments(attachments) {
+  if (!attachments || attachments.length === 0) return '';
+  const items = attachments.map(a => {
+    const name = escapeHTML(a.filename);
+    if (a.thumbnail_url) {
+      return `<a href="${a.url}" target="_blank" rel="noopener"><img src="${a.thumbnail_url}" alt="${name}" title="${name}" loading="lazy"></a>`;
+    }
+    return `<a href="${a.url}" class="attachment-file">${name} (${formatSize(a.size)})</a>`;
+  });
+  return `<div class="attachments">${items.join('')}</div>`;
+}
+
+// 把字节数格式化为易读的大小
+function formatSize(bytes) {
+  if (bytes < 1024) return `${bytes} B`;
+  if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+  return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+}
+
+// 发表内容使用的格式，勾选 Markdown 时为 markdown，否则为纯文本
+function contentFormat() {
+  const toggle = document.getElementById('use-markdown');
+  return toggle && toggle.checked ? 'markdown' : 'plain';
+}
+
+// 点击剧透内容时显示
+document.addEventListener('click', function(e) {
+  const spoiler = e.target.closest && e.target.closest('.spoiler');
+  if (spoiler) spoiler.classList.add('revealed');
+});
+
+// 编辑令牌保存在 localStorage，键为 post:<id> 或 comment:<id>
+const EDIT_TOKENS_KEY = 'nilbbsEditTokens';
+
+function loadEditTokens() {
+  try {
+    return JSON.parse(localStorage.getItem(EDIT_TOKENS_KEY)) || {};
+  } catch (e) {
+    return {};
+  }
+}
+
+function getEditToken(kind, id) {
+  return loadEditTokens()[`${kind}:${id}`] || '';
+}
+
+function saveEditToken(kind, id, token) {
+  if (!token) return;
+  const tokens = loadEditTokens();
+  tokens[`${kind}:${id}`] = token;
+  localStorage.setItem(EDIT_TOKENS_KEY, JSON.stringify(tokens));
+}
+
+function forgetEditToken(kind, id) {
+  const tokens = loadEditTokens();
+  delete tokens[`${kind}:${id}`];
+  localStorage.setItem(EDIT_TOKENS_KEY, JSON.stringify(tokens));
+}
+
+// 可编辑内容的原文，编辑时填入输入框
+const editableContent = new Map();
+
+// 自己发布的内容显示编辑和删除链接
+function renderEditActions(kind, postId, id) {
+  if (!getEditToken(kind, id)) return '';
+  return `
+    <span class="edit-actions">
+      <a href="#" onclick="startEdit(event, '${kind}', ${postId}, ${id})">Edit</a>
+      <a href="#" onclick="deleteItem(event, '${kind}', ${postId}, ${id})">Delete</a>
+    </span>
+  `;
+}
+
+// 帖子和评论的API地址
+function itemUrl(kind, postId, id) {
+  return kind === 'post' ? `/api/posts/${postId}` : `/api/posts/${postId}/comments/${id}`;
+}
+
+// 把内容替换为输入框，保存后重新加载帖子
+function startEdit(event, kind, postId, id) {
+  event.preventDefault();
+  const contentEl = document.querySelector(`[data-edit-id="${kind}:${id}"]`);
+  if (!contentEl || contentEl.querySelector('textarea')) return;
+
+  const original = contentEl.innerHTML;
+  const textarea = document.createElement('textarea');
+  textarea.className = 'form-control';
+  textarea.rows = 3;
+  textarea.value = editableContent.get(`${kind}:${id}`) || '';
+
+  const save = document.createElement('button');
+  save.textContent = 'Save';
+  save.onclick = () => saveEdit(kind, postId, id, textarea.value.trim());
+  const cancel = document.createElement('button');
+  cancel.textContent = 'Cancel';
+  cancel.onclick = () => { contentEl.innerHTML = original; };
+
+  contentEl.innerHTML = '';
+  contentEl.append(textarea, save, cancel);
+  textarea.focus();
+}
+
+// The server answers 202 when the content is held for moderator review
+function notifyHeld(response) {
+  if (response.status === 202) {
+    alert('Your content is awaiting moderator review and is hidden until approved');
+  }
+}
+
+// 自己等待审核的评论不在评论列表中，按帖子记下评论ID，打开帖子时用编辑令牌单独获取
+const PENDING_COMMENTS_KEY = 'nilbbsPendingComments';
+
+function loadPendingCommentIds() {
+  try {
+    return JSON.parse(localStorage.getItem(PENDING_COMMENTS_KEY)) || {};
+  } catch (e) {
+    return {};
+  }
+}
+
+function setPendingComment(postId, commentId, pending) {
+  const all = loadPendingCommentIds();
+  const ids = (all[postId] || []).filter(id => id !== commentId);
+  if (pending) ids.push(commentId);
+  if (ids.length > 0) {
+    all[postId] = ids;
+  } else {
+    delete all[postId];
+  }
+  localStorage.setItem(PENDING_COMMENTS_KEY, JSON.stringify(all));
+}
+
+// 未通过审核的内容只有作者能看到，显示审核状态
+function renderStatusTag(item) {
+  if (!item.status || item.status === 'approved') return '';
+  const label = item.status === 'rejected' ? 'Rejected' : 'Awaiting review';
+  return ` <span class="status-tag ${item.status}">${label}</span>`;
+}
+
+// 获取自己等待审核的评论，追加到评论列表末尾；已通过审核或不存在的评论不再记录
+async function showPendingComments(postId, container) {
+  const ids = loadPendingCommentIds()[postId] || [];
+  for (const id of ids) {
+    try {
+      const response = await fetch(withTimezone(`/api/posts/${postId}/comments/${id}`), {
+        headers: {'X-Edit-Token': getEditToken('comment', id)}
+      });
+      if (response.status === 404) {
+        setPendingComment(postId, id, false);
+        continue;
+      }
+      if (!response.ok) continue;
+      const data = await response.json();
+      if (data.comment.status === 'approved') {
+        setPendingComment(postId, id, false);
+        continue;
+      }
+      if (!container.querySelector(`[data-comment-id="${id}"]`)) {
+        container.insertAdjacentHTML('beforeend', renderComment(data.comment));
+      }
+    } catch (error) {
+      console.error('Loading failed:', error);
+    }
+  }
+}
+
+async function saveEdit(kind, postId, id, content) {
+  if (!content) {
+    alert('Content cannot be empty');
+    return;
+  }
+  try {
+    const response = await fetch(itemUrl(kind, postId, id), {
+      method: 'PUT',
+      headers: {'Content-Type': 'application/json', 'X-Edit-Token': getEditToken(kind, id)},
+      body: JSON.stringify({ content })
+    });
+    if (response.status === 403 || response.status === 404) {
+      forgetEditToken(kind, id);
+    }
+    if (!response.ok) throw new Error('Failed to save');
+    notifyHeld(response);
+    if (kind === 'comment' && response.status === 202) {
+      setPendingComment(postId, id, true);
+    }
+    loadPost(postId);
+  } catch (error) {
+    alert('Failed to save');
+  }
+}
+
+async function deleteItem(event, kind, postId, id) {
+  event.preventDefault();
+  const message = kind === 'post' ? 'Delete this post and all its comments?' : 'Delete this comment?';
+  if (!confirm(message)) return;
+
+  try {
+    const response = await fetch(itemUrl(kind, postId, id), {
+      method: 'DELETE',
+      headers: {'X-Edit-Token': getEditToken(kind, id)}
+    });
+    if (response.ok || response.status === 403 || response.status === 404) {
+      forgetEditToken(kind, id);
+    }
+    if (!response.ok) throw new Error('Failed to delete');
+
+    if (kind === 'post') {
+      window.location.hash = '';
+    } else {
+      loadPost(postId);
+    }
+  } catch (error) {
+    alert('Failed to delete');
+  }
+}
+
+// 举报原因，与服务器的 models.Report* 一致
+const reportReasons = ['spam', 'abuse', 'illegal', 'other'];
+
+// 举报链接，自己发的内容不显示
+function renderReportAction(kind, postId, id) {
+  if (getEditToken(kind, id)) return '';
+  return ` · <a href="#" class="report-action" onclick="reportItem(event, '${kind}', ${postId}, ${id})">Report</a>`;
+}
+
+// 选择原因后举报帖子或评论
+async function reportItem(event, kind, postId, id) {
+  event.preventDefault();
+  const reason = (prompt(`Report this ${kind}. Reason (${reportReasons.join(', ')}):`, 'spam') || '').trim().toLowerCase();
+  if (!reason) return;
+  if (!reportReasons.includes(reason)) {
+    alert(`Reason must be one of: ${reportReasons.join(', ')}`);
+    return;
+  }
+
+  try {
+    const response = await fetch(`${itemUrl(kind, postId, id)}/report`, {
+      method: 'POST',
+      headers: {'Content-Type': 'application/json'},
+      body: JSON.stringify({reason})
+    });
+    if (response.status === 409) {
+      alert('You have already reported this.');
+      return;
+    }
+    if (!response.ok) throw new Error('Failed to report');
+    alert('Thanks, the report has been sent to the moderators.');
+  } catch (error) {
+    alert('Failed to report');
+  }
+}
+
+// 修改过的内容显示 edited 标记，点击查看修改历史
+function renderEditedMark(kind, postId, item) {
+  if (!item.edited) return '';
+  const editedAt = item.edited_at ? formatDate(item.edited_at) : '';
+  return ` · <a href="#" class="edited-mark" title="${editedAt}" onclick="toggleRevisions(event, '${kind}', ${postId}, ${item.id})">edited</a>`;
+}
+
+async function toggleRevisions(event, kind, postId, id) {
+  event.preventDefault();
+  const contentEl = document.querySelector(`[data-edit-id="${kind}:${id}"]`);
+  if (!contentEl) return;
+  const existing = contentEl.parentElement.querySelector('.revisions');
+  if (existing) {
+    existing.remove();
+    return;
+  }
+
+  try {
+    const response = await fetch(withTimezone(`/api/posts/${postId}/revisions`));
+    if (!response.ok) throw new Error('Failed to fetch revisions');
+    const data = await response.json();
+    const revisions = (data.revisions || []).filter(r =>
+      kind === 'post' ? !r.comment_id : r.comment_id === id);
+
+    const list = document.createElement('ol');
+    list.className = 'revisions';
+    revisions.forEach(r => {
+      const item = document.createElement('li');
+      const meta = document.createElement('div');
+      meta.className = 'revision-meta';
+      meta.textContent = `Replaced at ${formatDate(r.replaced_at)}`;
+      const content = document.createElement('div');
+      content.textContent = r.content;
+      item.append(meta, content);
+      list.appendChild(item);
+    });
+    contentEl.after(list);
+  } catch (error) {
+    console.error('Loading failed:', error);
+  }
+}
+
+// 分页游标，为空表示没有更多数据
+let postsNextCursor = '';
+let commentsNextCursor = '';
+let loadingMore = false;
+
+// 渲染帖子列表项
+function renderPostItem(post) {
+  const date = formatDate(post.created_at);
+  const preview = post.excerpt || post.content;
+  const commentCount = post.comment_count ? ` · ${post.comment_count} comments` : '';
+  const pinned = post.pinned ? '<span class="pinned-tag">Pinned</span> ' : '';
+  // 计算初始倒计时，使用服务器返回的delete_at时间
+  const countdown = calculateCountdown(post.created_at, post.delete_at);
+  const countdownClass = countdown.status ? `countdown-tag ${countdown.status}` : 'countdown-tag';
+
+  return `
+    <li class="post-item">
+      <div class="post-content">${pinned}<a href="#" data-post-id="${post.id}" onclick="navigateToPost(event, ${post.id})">${escapeHTML(preview)}</a></div>
+      <div class="post-meta">
+        <span class="post-meta-info">${escapeHTML(post.author)} · ${date}${commentCount}</span>
+        <span class="${countdownClass}" data-created-at="${post.created_at}" data-delete-at="${post.delete_at}">${countdown.text}</span>
+      </div>
+    </li>
+  `;
+}
+
+// Load post list, append 为 true 时加载下一页
+async function loadPosts(append = false) {
   const postList = document.getElementById('post-list');
   if (!postList) return;
+  if (append && !postsNextCursor) return;
   
   try {
-    const response = await fetch('/api/posts');
+    const sortSelect = document.getElementById('post-sort');
+    let url = '/api/posts?sort=' + encodeURIComponent(sortSelect ? sortSelect.value : 'created');
+    if (append) {
+      url += '&cursor=' + encodeURIComponent(postsNextCursor);
+    }
+    const response = await fetch(withTimezone(url));
     if (!response.ok) throw new Error('Failed to fetch posts');
     const data = await response.json();
+    postsNextCursor = data.next_cursor || '';
     
-    postList.innerHTML = '';
+    if (!append) {
+      postList.innerHTML = '';
+    }
     
     if (data.posts && data.posts.length > 0) {
-      data.posts.forEach(post => {
-        const date = formatDate(post.created_at);
-        const preview = post.content.length > 80 ? post.content.substring(0, 80) + '...' : post.content;
-        // 计算初始倒计时，使用服务器返回的delete_at时间
-        const countdown = calculateCountdown(post.created_at, post.delete_at);
-        const countdownClass = countdown.status ? `countdown-tag ${countdown.status}` : 'countdown-tag';
-        
-        postList.innerHTML += `
-          <li class="post-item">
-            <div class="post-content"><a href="#" data-post-id="${post.id}" onclick="navigateToPost(event, ${post.id})">${preview}</a></div>
-            <div class="post-meta">
-              <span class="post-meta-info">${post.author} · ${date}</span>
-              <span class="${countdownClass}" data-created-at="${post.created_at}" data-delete-at="${post.delete_at}">${countdown.text}</span>
-            </div>
-          </li>
-        `;
-      });
+      postList.insertAdjacentHTML('beforeend', data.posts.map(renderPostItem).join(''));
       
       // 启动倒计时更新
       startCountdownTimer();
-    } else {
+    } else if (!append) {
       postList.innerHTML = '<li class="post-item">No posts yet</li>';
     }
   } catch (error) {
     console.error('Loading failed:', error);
-    postList.innerHTML = '<li class="post-item">Failed to load</li>';
+    if (!append) {
+      postList.innerHTML = '<li class="post-item">Failed to load</li>';
+    }
+  }
+}
+
+// 当前帖子楼主的发帖人ID，评论中与之相同的ID高亮显示
+let opPosterId = '';
+
+// 发帖人ID在同一帖子中相同，用来区分同一个讨论中的不同发言者
+function renderPosterId(posterId) {
+  if (!posterId) return '';
+  const op = posterId === opPosterId ? ' op' : '';
+  const title = op ? 'Original poster' : 'Poster ID in this thread';
+  return ` <span class="poster-id${op}" title="${title}">ID:${escapeHTML(posterId)}</span>`;
+}
+
+// 渲染评论，回复按层级缩进
+function renderComment(comment) {
+  const commentDate = formatDate(comment.created_at);
+  const depth = comment.depth || 0;
+  const toggle = `<a href="#" class="thread-toggle" onclick="toggleThread(event, ${comment.id})">[-]</a> `;
+  if (comment.deleted || comment.hidden) {
+    return `
+    <div class="comment deleted" data-comment-id="${comment.id}" data-depth="${depth}" style="--depth: ${depth}">
+      <div class="comment-content">${comment.hidden ? '[hidden by moderator]' : '[deleted]'}</div>
+      <div class="comment-meta">${toggle}<span class="comment-id">#${comment.id}</span> ${commentDate}${renderBacklinks(comment.backlinks)}</div>
+    </div>
+  `;
+  }
+  editableContent.set(`comment:${comment.id}`, comment.content);
+  contentQuotes.set(`comment:${comment.id}`, comment.quotes);
+  return `
+    <div class="comment" data-comment-id="${comment.id}" data-depth="${depth}" style="--depth: ${depth}">
+      <div class="comment-content" data-edit-id="comment:${comment.id}">${comment.content_html}</div>
+      ${renderAttachments(comment.attachments)}
+      <div class="comment-meta">${toggle}<span class="comment-id">#${comment.id}</span> ${escapeHTML(comment.author)}${renderPosterId(comment.poster_id)} · ${commentDate}${renderStatusTag(comment)}${renderEditedMark('comment', comment.post_id, comment)} · <a href="#" onclick="startReply(event, ${comment.post_id}, ${comment.id})">Reply</a>${renderEditActions('comment', comment.post_id, comment.id)}${renderReportAction('comment', comment.post_id, comment.id)}${renderBacklinks(comment.backlinks)}</div>
+    </div>
+  `;
+}
+
+// 内容中引用的目标，键为 post:<id> 或 comment:<id>，内容插入页面后再替换为链接
+const contentQuotes = new Map();
+
+// 把内容中的 >>id（评论）和 >>>id（帖子）替换为链接，服务器标记为失效的目标显示为删除线。
+// 只处理文本节点，代码、已有的链接和已经处理过的引用保持原样
+function applyQuoteLinks(root) {
+  root.querySelectorAll('[data-edit-id]').forEach(el => {
+    const quotes = contentQuotes.get(el.dataset.editId);
+    if (!quotes || quotes.length === 0) return;
+    const links = new Map(quotes.map(q => [`${q.post ? 'p' : 'c'}:${q.id}`, q]));
+
+    const walker = document.createTreeWalker(el, NodeFilter.SHOW_TEXT);
+    const nodes = [];
+    while (walker.nextNode()) {
+      const node = walker.currentNode;
+      if (!node.parentElement.closest('a, code, pre, .quote-link') && /(>>>?)(\d+)/.test(node.nodeValue)) {
+        nodes.push(node);
+      }
+    }
+    nodes.forEach(node => {
+      const fragment = document.createDocumentFragment();
+      const text = node.nodeValue;
+      let last = 0;
+      for (const match of text.matchAll(/(>>>?)(\d+)/g)) {
+        fragment.append(text.slice(last, match.index));
+        const quote = links.get(`${match[1] === '>>>' ? 'p' : 'c'}:${match[2]}`);
+        fragment.append(quote ? quoteElement(match[0], quote) : match[0]);
+        last = match.index + match[0].length;
+      }
+      fragment.append(text.slice(last));
+      node.replaceWith(fragment);
+    });
+  });
+}
+
+function quoteElement(label, quote) {
+  if (quote.dead) {
+    const span = document.createElement('span');
+    span.className = 'quote-link dead';
+    span.textContent = label;
+    return span;
   }
+  const link = document.createElement('a');
+  link.className = 'quote-link';
+  link.href = `#/post/${quote.post_id}`;
+  link.textContent = label;
+  link.onclick = event => goToQuote(event, quote.post_id, quote.post ? 0 : quote.id);
+  return link;
+}
+
+// 引用了这条评论的内容
+function renderBacklinks(backlinks) {
+  if (!backlinks || backlinks.length === 0) return '';
+  const links = backlinks.map(b => {
+    const label = b.comment_id ? `>>${b.comment_id}` : `>>>${b.post_id}`;
+    return `<a href="#/post/${b.post_id}" class="quote-link" onclick="goToQuote(event, ${b.post_id}, ${b.comment_id || 0})">${label}</a>`;
+  });
+  return `<span class="backlinks"> · Replies: ${links.join(' ')}</span>`;
+}
+
+// 打开引用的目标：当前页面中的评论直接滚动过去，否则跳转到所在帖子后再定位
+let pendingQuoteTarget = 0;
+
+function goToQuote(event, postId, commentId) {
+  event.preventDefault();
+  if (commentId && scrollToComment(commentId)) return;
+  pendingQuoteTarget = commentId;
+  if (window.location.hash === `#/post/${postId}`) {
+    loadPost(postId);
+  } else {
+    window.location.hash = `/post/${postId}`;
+  }
+}
+
+function scrollToComment(commentId) {
+  const el = document.querySelector(`.comment[data-comment-id="${commentId}"]`);
+  if (!el) return false;
+  el.hidden = false;
+  el.scrollIntoView({ behavior: 'smooth', block: 'center' });
+  el.classList.add('highlighted');
+  setTimeout(() => el.classList.remove('highlighted'), 2000);
+  return true;
+}
+
+// 折叠或展开评论下的回复：评论后面层级更深的连续评论都属于它的子树
+function toggleThread(event, id) {
+  event.preventDefault();
+  const commentEl = document.querySelector(`.comment[data-comment-id="${id}"]`);
+  if (!commentEl) return;
+  const depth = Number(commentEl.dataset.depth);
+  const collapse = !commentEl.classList.contains('collapsed');
+  commentEl.classList.toggle('collapsed', collapse);
+  event.target.textContent = collapse ? '[+]' : '[-]';
+
+  for (let el = commentEl.nextElementSibling; el && el.classList.contains('comment') && Number(el.dataset.depth) > depth; el = el.nextElementSibling) {
+    el.hidden = collapse;
+    // 展开时子树中的评论也全部展开
+    if (!collapse) {
+      el.classList.remove('collapsed');
+      const childToggle = el.querySelector('.thread-toggle');
+      if (childToggle) childToggle.textContent = '[-]';
+    }
+  }
+}
+
+// 在评论下方显示回复输入框
+function startReply(event, postId, parentId) {
+  event.preventDefault();
+  const commentEl = document.querySelector(`.comment[data-comment-id="${parentId}"]`);
+  if (!commentEl || commentEl.querySelector('.reply-form')) return;
+
+  const form = document.createElement('div');
+  form.className = 'reply-form';
+  const textarea = document.createElement('textarea');
+  textarea.className = 'form-control';
+  textarea.rows = 3;
+  const send = document.createElement('button');
+  send.textContent = 'Reply';
+  send.onclick = async () => {
+    if (await postComment(postId, textarea.value.trim(), parentId)) {
+      loadPost(postId);
+    }
+  };
+  const cancel = document.createElement('button');
+  cancel.textContent = 'Cancel';
+  cancel.onclick = () => form.remove();
+
+  form.append(textarea, send, cancel);
+  commentEl.appendChild(form);
+  textarea.focus();
+}
+
+// 加载下一页评论
+async function loadMoreComments(postId) {
+  const commentsContainer = document.getElementById('comments-container');
+  if (!commentsContainer || !commentsNextCursor) return;
+
+  try {
+    const url = `/api/posts/${postId}?cursor=` + encodeURIComponent(commentsNextCursor);
+    const response = await fetch(withTimezone(url));
+    if (!response.ok) throw new Error('Failed to fetch comments');
+    const data = await response.json();
+    commentsNextCursor = data.next_cursor || '';
+
+    if (data.post && data.post.comments) {
+      commentsContainer.insertAdjacentHTML('beforeend', data.post.comments.map(renderComment).join(''));
+      applyQuoteLinks(commentsContainer);
+    }
+  } catch (error) {
+    console.error('Loading failed:', error);
+  }
+}
+
+// 滚动到页面底部附近时加载下一页
+function setupInfiniteScroll() {
+  window.addEventListener('scroll', async function() {
+    if (loadingMore) return;
+    if (window.innerHeight + window.scrollY < document.body.offsetHeight - 200) return;
+
+    loadingMore = true;
+    try {
+      const hash = window.location.hash;
+      if (hash.startsWith('#/post/')) {
+        await loadMoreComments(hash.split('/').pop());
+      } else {
+        await loadPosts(true);
+      }
+    } finally {
+      loadingMore = false;
+    }
+  });
 }
 
 // Load single post and its comments
@@ -316,7 +966,11 @@ async function loadPost(postId) {
   if (!postContainer || !commentsContainer) return;
   
   try {
-    const response = await fetch(`/api/posts/${postId}`);
+    // 带上编辑令牌，作者可以看到自己等待审核的帖子
+    const token = getEditToken('post', postId);
+    const response = await fetch(withTimezone(`/api/posts/${postId}`), {
+      headers: token ? {'X-Edit-Token': token} : {}
+    });
     if (!response.ok) throw new Error('Failed to fetch post');
     const data = await response.json();
     
@@ -328,36 +982,40 @@ async function loadPost(postId) {
     const countdown = calculateCountdown(post.created_at, post.delete_at);
     const countdownClass = countdown.status ? `countdown-tag ${countdown.status}` : 'countdown-tag';
     
+    opPosterId = post.poster_id || '';
+    editableContent.set(`post:${post.id}`, post.content);
+    contentQuotes.set(`post:${post.id}`, post.quotes);
     postContainer.innerHTML = `
-      <div class="post-content">${post.content}</div>
+      <div class="post-content" data-edit-id="post:${post.id}">${post.content_html}</div>
+      ${renderAttachments(post.attachments)}
       <div class="post-meta">
-        <span class="post-meta-info">${post.author} · ${date}</span>
+        <span class="post-meta-info">${escapeHTML(post.author)}${renderPosterId(post.poster_id)} · ${date}${renderStatusTag(post)}${renderEditedMark('post', post.id, post)}${renderEditActions('post', post.id, post.id)}${renderReportAction('post', post.id, post.id)}</span>
         <span class="${countdownClass}" data-created-at="${post.created_at}" data-delete-at="${post.delete_at}">${countdown.text}</span>
       </div>
+      ${post.locked ? '<div class="locked-notice">This thread is locked. New comments are disabled.</div>' : ''}
     `;
     
     // 确保倒计时定时器在加载帖子详情时也启动
     startCountdownTimer();
     
     commentsContainer.innerHTML = '<h3 class="no-margin">Comments</h3>';
+    commentsNextCursor = data.next_cursor || '';
     
     if (post.comments && post.comments.length > 0) {
-      post.comments.forEach(comment => {
-        const commentDate = formatDate(comment.created_at);
-        commentsContainer.innerHTML += `
-          <div class="comment">
-            <div class="comment-content">${comment.content}</div>
-            <div class="comment-meta">${comment.author} · ${commentDate}</div>
-          </div>
-        `;
-      });
-    } else {
-      commentsContainer.innerHTML += '';
+      commentsContainer.insertAdjacentHTML('beforeend', post.comments.map(renderComment).join(''));
+    }
+    await showPendingComments(post.id, commentsContainer);
+    applyQuoteLinks(postContainer);
+    applyQuoteLinks(commentsContainer);
+    if (pendingQuoteTarget) {
+      scrollToComment(pendingQuoteTarget);
+      pendingQuoteTarget = 0;
     }
     
-    // 帖子存在时显示评论表单
+    // 帖子存在、未锁定且已公开时显示评论表单
     if (commentForm) {
-      commentForm.style.display = 'block';
+      const unapproved = post.status && post.status !== 'approved';
+      commentForm.style.display = post.locked || unapproved ? 'none' : 'block';
     }
   } catch (error) {
     console.error('Loading failed:', error);
@@ -382,30 +1040,50 @@ async function addComment(event, postId) {
   event.preventDefault();
   
   const content = document.getElementById('comment-content').value.trim();
+  const data = await postComment(postId, content);
+  if (data) {
+    // 清空输入框
+    document.getElementById('comment-content').value = '';
+    await uploadAttachments(`/api/posts/${postId}/comments/${data.comment_id}/attachments`, data.edit_token);
+    
+    // 重新加载帖子及评论，不刷新页面
+    loadPost(postId);
+  }
+}
+
+// 发表评论，parentId 不为空时回复该评论，成功时返回接口的响应，失败时返回 null
+async function postComment(postId, content, parentId) {
   if (!content) {
     alert('Comment cannot be empty');
-    return;
+    return null;
   }
 
   // Use global nickname
   const author = getCurrentNickname();
+  const body = { content, author, format: contentFormat() };
+  if (parentId) {
+    body.parent_comment_id = parentId;
+  }
   
   try {
+    const pow = await proofOfWork();
     const response = await fetch(`/api/posts/${postId}/comments`, {
       method: 'POST',
-      headers: {'Content-Type': 'application/json'},
-      body: JSON.stringify({ content, author })
+      headers: {'Content-Type': 'application/json', ...pow},
+      body: JSON.stringify(body)
     });
     
     if (!response.ok) throw new Error('Failed to add comment');
-    
-    // 清空输入框
-    document.getElementById('comment-content').value = '';
-    
-    // 重新加载帖子及评论，不刷新页面
-    loadPost(postId);
+    notifyHeld(response);
+    const data = await response.json();
+    saveEditToken('comment', data.comment_id, data.edit_token);
+    if (response.status === 202) {
+      setPendingComment(postId, data.comment_id, true);
+    }
+    return data;
   } catch (error) {
     alert('Failed to add comment');
+    return null;
   }
 }
 
@@ -423,13 +1101,17 @@ async function createPost(event) {
   const author = getCurrentNickname();
   
   try {
+    const pow = await proofOfWork();
     const response = await fetch('/api/posts', {
       method: 'POST',
-      headers: {'Content-Type': 'application/json'},
-      body: JSON.stringify({ content, author })
+      headers: {'Content-Type': 'application/json', ...pow},
+      body: JSON.stringify({ content, author, format: contentFormat() })
     });
     
     if (!response.ok) throw new Error('Failed to create post');
+    notifyHeld(response);
+    const data = await response.json();
+    saveEditToken('post', data.post_id, data.edit_token);
     
     // 使用 hash 路由导航到主页
     window.location.hash = '';
@@ -452,16 +1134,21 @@ async function quickCreatePost(event) {
   const author = getCurrentNickname();
   
   try {
+    const pow = await proofOfWork();
     const response = await fetch('/api/posts', {
       method: 'POST',
-      headers: {'Content-Type': 'application/json'},
-      body: JSON.stringify({ content, author })
+      headers: {'Content-Type': 'application/json', ...pow},
+      body: JSON.stringify({ content, author, format: contentFormat() })
     });
     
     if (!response.ok) throw new Error('Failed to create post');
+    notifyHeld(response);
+    const data = await response.json();
+    saveEditToken('post', data.post_id, data.edit_token);
     
     // Clear input after successful post
     document.getElementById('quick-post-content').value = '';
+    await uploadAttachments(`/api/posts/${data.post_id}/attachments`, data.edit_token);
     
     // Reload post list instead of refreshing the whole page
     loadPosts();
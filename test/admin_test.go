@@ -0,0 +1,272 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 在测试路由上加上管理接口
+func newAdminTestRouter(store database.Store) http.Handler {
+	r := newTestRouter(store)
+	h := handlers.NewHandler(store, nil)
+	sessions := handlers.NewAdminSessions()
+	r.POST("/api/admin/login", handlers.AdminLogin(sessions))
+	r.POST("/api/admin/logout", handlers.AdminLogout(sessions))
+	admin := r.Group("/api/admin", handlers.RequireAdmin(sessions))
+	admin.GET("/audit", h.ListAuditLog)
+	admin.GET("/reports", h.ListReports)
+	admin.GET("/metrics", handlers.FilterMetrics)
+	admin.GET("/queue", h.ListPending)
+	admin.POST("/queue/approve", h.ReviewQueue(true))
+	admin.POST("/queue/reject", h.ReviewQueue(false))
+	admin.DELETE("/posts/:id/reports", h.Moderate(models.ActionDismissPostReports))
+	admin.DELETE("/posts/:id", h.Moderate(models.ActionDeletePost))
+	admin.POST("/posts/:id/hide", h.Moderate(models.ActionHidePost))
+	admin.POST("/posts/:id/lock", h.Moderate(models.ActionLockPost))
+	admin.POST("/posts/:id/pin", h.Moderate(models.ActionPinPost))
+	admin.PUT("/posts/:id/delete-at", h.Moderate(models.ActionSetDeleteAt))
+	admin.POST("/posts/:id/comments/:commentId/hide", h.Moderate(models.ActionHideComment))
+	admin.POST("/posts/:id/approve", h.Moderate(models.ActionApprovePost))
+	return r
+}
+
+// 登录管理接口，返回会话 Cookie，登录失败时返回空字符串
+func adminLogin(t *testing.T, r http.Handler, password string) (string, int) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/login", strings.NewReader(`{"password":"`+password+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == handlers.AdminSessionCookie && cookie.Value != "" {
+			if !cookie.HttpOnly || cookie.Path != "/api/admin" || cookie.SameSite != http.SameSiteStrictMode {
+				t.Errorf("会话 Cookie 属性不安全: %+v", cookie)
+			}
+			return cookie.Name + "=" + cookie.Value, w.Code
+		}
+	}
+	return "", w.Code
+}
+
+func TestAdminLogin(t *testing.T) {
+	restoreDefaultConfig(t)
+	r := newAdminTestRouter(database.NewMemoryStore())
+
+	if _, code := adminLogin(t, r, "anything"); code != http.StatusNotFound {
+		t.Fatalf("未配置管理凭据时不能登录: %d", code)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := utils.DefaultConfig()
+	cfg.AdminToken = "s3cret"
+	cfg.AdminPasswordHash = string(hash)
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cookie, code := adminLogin(t, r, "wrong"); code != http.StatusUnauthorized || cookie != "" {
+		t.Fatalf("错误的密码应被拒绝: %d", code)
+	}
+	if cookie, code := adminLogin(t, r, "s3cret"); code != http.StatusOK || cookie == "" {
+		t.Fatalf("管理令牌也可以登录: %d", code)
+	}
+	cookie, code := adminLogin(t, r, "hunter2")
+	if code != http.StatusOK || cookie == "" {
+		t.Fatalf("管理密码登录失败: %d", code)
+	}
+
+	audit := func(header map[string]string) int {
+		return doJSONWithHeader(t, r, "GET", "/api/admin/audit", "", header, nil)
+	}
+	if code := audit(nil); code != http.StatusUnauthorized {
+		t.Errorf("未登录: status %d, want 401", code)
+	}
+	if code := audit(map[string]string{"Cookie": handlers.AdminSessionCookie + "=forged"}); code != http.StatusUnauthorized {
+		t.Errorf("伪造的会话: status %d, want 401", code)
+	}
+	if code := audit(map[string]string{"Cookie": cookie}); code != http.StatusOK {
+		t.Errorf("会话 Cookie: status %d, want 200", code)
+	}
+	if code := audit(map[string]string{"Authorization": "Bearer s3cret"}); code != http.StatusOK {
+		t.Errorf("管理令牌: status %d, want 200", code)
+	}
+
+	// 退出后会话失效
+	if code := doJSONWithHeader(t, r, "POST", "/api/admin/logout", "", map[string]string{"Cookie": cookie}, nil); code != http.StatusOK {
+		t.Fatalf("logout: status %d", code)
+	}
+	if code := audit(map[string]string{"Cookie": cookie}); code != http.StatusUnauthorized {
+		t.Errorf("退出后的会话: status %d, want 401", code)
+	}
+
+	// 修改管理凭据后已有的会话失效
+	cookie, _ = adminLogin(t, r, "hunter2")
+	cfg.AdminToken = "rotated"
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if code := audit(map[string]string{"Cookie": cookie}); code != http.StatusUnauthorized {
+		t.Errorf("凭据修改后的会话: status %d, want 401", code)
+	}
+}
+
+func TestAdminCookieSecure(t *testing.T) {
+	restoreDefaultConfig(t)
+	r := newAdminTestRouter(database.NewMemoryStore())
+	cfg := utils.DefaultConfig()
+	cfg.AdminToken = "s3cret"
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	secure := func() bool {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/login", strings.NewReader(`{"password":"s3cret"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == handlers.AdminSessionCookie {
+				return cookie.Secure
+			}
+		}
+		t.Fatalf("login: status %d, no session cookie", w.Code)
+		return false
+	}
+
+	// 不是来自信任的代理时忽略 X-Forwarded-Proto
+	if secure() {
+		t.Error("untrusted X-Forwarded-Proto set the Secure flag")
+	}
+	// httptest 的请求来自 192.0.2.1
+	cfg.TrustedProxies = []string{"192.0.2.0/24"}
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !secure() {
+		t.Error("X-Forwarded-Proto from a trusted proxy did not set the Secure flag")
+	}
+}
+
+func TestAdminModeration(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.DefaultConfig()
+	cfg.AdminToken = "s3cret"
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r := newAdminTestRouter(database.NewMemoryStore())
+	auth := map[string]string{"Authorization": "Bearer s3cret", "X-Forwarded-For": "192.0.2.7"}
+
+	var created struct {
+		PostID int64 `json:"post_id"`
+	}
+	doJSON(t, r, "POST", "/api/posts", `{"content":"first"}`, nil)
+	doJSON(t, r, "POST", "/api/posts", `{"content":"second"}`, &created)
+	path := "/api/posts/" + strconv.FormatInt(created.PostID, 10)
+	adminPath := "/api/admin/posts/" + strconv.FormatInt(created.PostID, 10)
+	var comment struct {
+		CommentID int64 `json:"comment_id"`
+	}
+	doJSON(t, r, "POST", path+"/comments", `{"content":"spam"}`, &comment)
+	adminCommentPath := adminPath + "/comments/" + strconv.FormatInt(comment.CommentID, 10)
+
+	if code := doJSON(t, r, "POST", adminPath+"/pin", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("未认证的管理操作: status %d, want 401", code)
+	}
+
+	// 置顶的帖子排在第一页最前面
+	if code := doJSONWithHeader(t, r, "POST", adminPath+"/pin", `{"reason":"公告"}`, auth, nil); code != http.StatusOK {
+		t.Fatalf("pin: status %d", code)
+	}
+	var list struct {
+		Posts []models.Post `json:"posts"`
+	}
+	doJSON(t, r, "GET", "/api/posts?sort=created", "", &list)
+	if len(list.Posts) != 2 || list.Posts[0].ID != created.PostID || !list.Posts[0].Pinned {
+		t.Errorf("pinned post not first: %+v", list.Posts)
+	}
+
+	// 隐藏的评论只保留占位
+	if code := doJSONWithHeader(t, r, "POST", adminCommentPath+"/hide", "", auth, nil); code != http.StatusOK {
+		t.Fatalf("hide comment: status %d", code)
+	}
+	var got struct {
+		Post models.Post `json:"post"`
+	}
+	doJSON(t, r, "GET", path, "", &got)
+	if len(got.Post.Comments) != 1 || !got.Post.Comments[0].Hidden || got.Post.Comments[0].Content != "" {
+		t.Errorf("hidden comment exposed: %+v", got.Post.Comments)
+	}
+
+	// 锁定的帖子不能评论
+	doJSONWithHeader(t, r, "POST", adminPath+"/lock", "", auth, nil)
+	if code := doJSON(t, r, "POST", path+"/comments", `{"content":"more"}`, nil); code != http.StatusForbidden {
+		t.Errorf("comment on locked post: status %d, want 403", code)
+	}
+	doJSON(t, r, "GET", path, "", &got)
+	if !got.Post.Locked {
+		t.Errorf("post not marked locked: %+v", got.Post)
+	}
+
+	// 删除时间必须晚于当前时间
+	past := utils.NowUTC().Add(-time.Hour).Format(time.RFC3339)
+	if code := doJSONWithHeader(t, r, "PUT", adminPath+"/delete-at", `{"delete_at":"`+past+`"}`, auth, nil); code != http.StatusBadRequest {
+		t.Errorf("delete_at in the past: status %d, want 400", code)
+	}
+	future := utils.NowUTC().AddDate(0, 0, 30).Truncate(time.Second)
+	if code := doJSONWithHeader(t, r, "PUT", adminPath+"/delete-at", `{"delete_at":"`+future.Format(time.RFC3339)+`"}`, auth, nil); code != http.StatusOK {
+		t.Fatalf("set delete_at: status %d", code)
+	}
+	doJSON(t, r, "GET", path, "", &got)
+	if !got.Post.DeleteAt.Equal(future) {
+		t.Errorf("delete_at = %v, want %v", got.Post.DeleteAt, future)
+	}
+
+	if code := doJSONWithHeader(t, r, "POST", adminPath+"/hide", `{"reason":"`+strings.Repeat("长", 501)+`"}`, auth, nil); code != http.StatusBadRequest {
+		t.Errorf("reason too long: status %d, want 400", code)
+	}
+	if code := doJSONWithHeader(t, r, "POST", adminPath+"/hide", "", auth, nil); code != http.StatusOK {
+		t.Fatalf("hide post: status %d", code)
+	}
+	if code := doJSON(t, r, "GET", path, "", nil); code != http.StatusNotFound {
+		t.Errorf("hidden post: status %d, want 404", code)
+	}
+	if code := doJSONWithHeader(t, r, "DELETE", adminPath, "", auth, nil); code != http.StatusOK {
+		t.Fatalf("delete post: status %d", code)
+	}
+	if code := doJSONWithHeader(t, r, "POST", adminPath+"/pin", "", auth, nil); code != http.StatusNotFound {
+		t.Errorf("pin deleted post: status %d, want 404", code)
+	}
+
+	// 每个成功的操作都记录在审计日志中
+	var audit struct {
+		Entries    []models.AuditEntry `json:"entries"`
+		NextCursor string              `json:"next_cursor"`
+	}
+	doJSONWithHeader(t, r, "GET", "/api/admin/audit?limit=5", "", auth, &audit)
+	if len(audit.Entries) != 5 || audit.NextCursor == "" {
+		t.Fatalf("audit log: %+v", audit)
+	}
+	latest := audit.Entries[0]
+	if latest.Action != models.ActionDeletePost || latest.PostID != created.PostID || latest.Actor != "token" || latest.IP != "192.0.2.7" {
+		t.Errorf("latest entry = %+v", latest)
+	}
+	doJSONWithHeader(t, r, "GET", "/api/admin/audit?limit=5&cursor="+audit.NextCursor, "", auth, &audit)
+	if len(audit.Entries) != 1 || audit.Entries[0].Action != models.ActionPinPost || audit.Entries[0].Reason != "公告" || audit.NextCursor != "" {
+		t.Errorf("second page: %+v", audit)
+	}
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Mammoth777/nilbbs/assets"
+	"github.com/Mammoth777/nilbbs/nickname"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+func TestOverlayFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "index.html"), []byte("custom"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := fstest.MapFS{
+		"templates/index.html": {Data: []byte("embedded")},
+		"templates/post.html":  {Data: []byte("post")},
+	}
+
+	files := utils.WithOverride(dir, base)
+	if data, err := fs.ReadFile(files, "templates/index.html"); err != nil || string(data) != "custom" {
+		t.Fatalf("覆盖文件未生效: %q, %v", data, err)
+	}
+	if data, err := fs.ReadFile(files, "templates/post.html"); err != nil || string(data) != "post" {
+		t.Fatalf("未覆盖的文件应回退到内嵌文件: %q, %v", data, err)
+	}
+	matches, err := fs.Glob(files, "templates/*")
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("目录内容应合并: %v, %v", matches, err)
+	}
+
+	if utils.WithOverride("", base) == nil {
+		t.Fatal("覆盖目录为空时应返回内嵌文件")
+	}
+}
+
+func TestNicknameLoad(t *testing.T) {
+	defer func() {
+		if err := nickname.Load(assets.Dataset); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	custom := fstest.MapFS{
+		"dataset/adjectives.txt": {Data: []byte("# 注释\n快乐的\n")},
+		"dataset/nouns.txt":      {Data: []byte("企鹅\n")},
+	}
+	if err := nickname.Load(custom); err != nil {
+		t.Fatal(err)
+	}
+	if name := nickname.GetRandomNickname(); name != "快乐的企鹅" {
+		t.Fatalf("昵称应来自新词库: %s", name)
+	}
+
+	// 词库缺失时保留当前词库
+	if err := nickname.Load(fstest.MapFS{}); err == nil {
+		t.Fatal("缺少词库文件时应返回错误")
+	}
+	if name := nickname.GetRandomNickname(); !strings.HasPrefix(name, "快乐的") {
+		t.Fatalf("加载失败后应保留原词库: %s", name)
+	}
+}
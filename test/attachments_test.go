@@ -0,0 +1,357 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/blob"
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/Mammoth777/nilbbs/media"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// 每种附件存储都必须通过同一套行为测试
+func runBlobSuite(t *testing.T, s blob.Store) {
+	ctx := context.Background()
+	data := []byte("hello attachment")
+	key := blob.Key(data)
+
+	if _, err := s.Open(ctx, key); err != blob.ErrNotFound {
+		t.Errorf("Open missing: got %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, "../escape", data, "text/plain"); err == nil {
+		t.Error("Put accepted an invalid key")
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Put(ctx, key, data, "text/plain"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	r, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(r)
+	r.Close()
+	if !bytes.Equal(got, data) {
+		t.Errorf("Open = %q, want %q", got, data)
+	}
+
+	other := []byte("in use")
+	if err := s.Put(ctx, blob.Key(other), other, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	var listed []string
+	if err := s.List(ctx, func(k string, _ time.Time) error { listed = append(listed, k); return nil }); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(listed) != 2 {
+		t.Errorf("List = %v, want 2 keys", listed)
+	}
+
+	inUse := func() (map[string]bool, error) { return map[string]bool{blob.Key(other): true}, nil }
+	// 刚写入的文件在宽限期内不会被删除
+	if n, err := blob.Sweep(ctx, s, inUse, time.Now()); err != nil || n != 0 {
+		t.Errorf("Sweep within grace = %d, %v; want 0", n, err)
+	}
+	if n, err := blob.Sweep(ctx, s, inUse, time.Now().Add(2*blob.SweepGrace)); err != nil || n != 1 {
+		t.Errorf("Sweep = %d, %v; want 1", n, err)
+	}
+	if _, err := s.Open(ctx, key); err != blob.ErrNotFound {
+		t.Errorf("swept file still exists: %v", err)
+	}
+	if _, err := s.Open(ctx, blob.Key(other)); err != nil {
+		t.Errorf("file in use was swept: %v", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete missing: %v", err)
+	}
+}
+
+func TestDiskBlobStore(t *testing.T) {
+	s, err := blob.NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+	runBlobSuite(t, s)
+}
+
+// TestS3BlobStore 需要S3兼容的对象存储，例如本地启动的MinIO：
+// NILBBS_TEST_S3_DSN=s3://minioadmin:minioadmin@localhost:9000/nilbbs-test?insecure=true
+func TestS3BlobStore(t *testing.T) {
+	dsn := os.Getenv("NILBBS_TEST_S3_DSN")
+	if dsn == "" {
+		t.Skip("NILBBS_TEST_S3_DSN 未设置，跳过S3测试")
+	}
+	// 每次使用新的前缀，不受之前留下的对象影响
+	s, err := blob.NewS3Store(dsn + "/" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	runBlobSuite(t, s)
+}
+
+// testImage 生成左上角为红色、其余为蓝色的图片
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x < w/4 && y < h/4 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// jpegWithExif 编码JPEG并在开头插入带方向标记和一段文字的EXIF
+func jpegWithExif(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	tiff := []byte("MM\x00*\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 39.9042N 116.4074E"...)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func TestMediaProcess(t *testing.T) {
+	// 方向标记 6 表示需要顺时针旋转90度：宽高互换，左上角的红色转到右上角
+	data := jpegWithExif(t, testImage(400, 200), 6)
+	f, err := media.Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if f.ContentType != "image/jpeg" || f.Width != 200 || f.Height != 400 {
+		t.Errorf("got %s %dx%d, want image/jpeg 200x400", f.ContentType, f.Width, f.Height)
+	}
+	if bytes.Contains(f.Data, []byte("Exif")) || bytes.Contains(f.Data, []byte("GPS")) {
+		t.Error("metadata was not stripped")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(f.Data))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if r, _, b, _ := img.At(190, 10).RGBA(); r < b {
+		t.Errorf("image not rotated: top right is %v", img.At(190, 10))
+	}
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(f.Thumbnail))
+	if err != nil || f.ThumbnailContentType != "image/jpeg" || thumb.Width != 160 || thumb.Height != 320 {
+		t.Errorf("thumbnail %s %+v, %v; want image/jpeg 160x320", f.ThumbnailContentType, thumb, err)
+	}
+
+	var small bytes.Buffer
+	png.Encode(&small, testImage(32, 16))
+	if f, err := media.Process(small.Bytes()); err != nil || f.ContentType != "image/png" || f.Thumbnail != nil {
+		t.Errorf("small png: %+v, %v", f, err)
+	}
+
+	if f, err := media.Process([]byte("just some notes\n")); err != nil || f.ContentType != "text/plain" {
+		t.Errorf("text file: %+v, %v", f, err)
+	}
+	for _, bad := range []string{"<html><script>alert(1)</script></html>", "\x7fELF\x02\x01\x01"} {
+		if _, err := media.Process([]byte(bad)); err != media.ErrUnsupportedType {
+			t.Errorf("Process(%q) = %v, want ErrUnsupportedType", bad, err)
+		}
+	}
+	// 声称是PNG但无法解码
+	if _, err := media.Process([]byte("\x89PNG\r\n\x1a\nbroken")); err != media.ErrUnsupportedType {
+		t.Errorf("broken png: %v, want ErrUnsupportedType", err)
+	}
+}
+
+// upload 以 multipart 表单上传文件
+func upload(t *testing.T, r http.Handler, path, token, filename string, data []byte, out interface{}) int {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", filename)
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest("POST", path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set(handlers.EditTokenHeader, token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("upload %s: invalid json %q: %v", path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestAttachmentUpload(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.CurrentConfig()
+	cfg.MaxAttachments = 2
+	cfg.MaxUploadSize = 64 << 10
+	utils.SetConfig(cfg)
+
+	store := database.NewMemoryStore()
+	blobs, err := blob.NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouterWithBlobs(store, blobs)
+
+	var post struct {
+		PostID    int64  `json:"post_id"`
+		EditToken string `json:"edit_token"`
+	}
+	doJSON(t, r, "POST", "/api/posts", `{"content":"pics"}`, &post)
+	path := "/api/posts/" + strconv.FormatInt(post.PostID, 10)
+	var comment struct {
+		CommentID int64  `json:"comment_id"`
+		EditToken string `json:"edit_token"`
+	}
+	doJSON(t, r, "POST", path+"/comments", `{"content":"more"}`, &comment)
+	commentPath := path + "/comments/" + strconv.FormatInt(comment.CommentID, 10)
+
+	photo := jpegWithExif(t, testImage(640, 480), 1)
+	if code := upload(t, r, path+"/attachments", comment.EditToken, "a.jpg", photo, nil); code != http.StatusForbidden {
+		t.Errorf("wrong token: status %d, want 403", code)
+	}
+	if code := upload(t, r, path+"/attachments", post.EditToken, "a.html", []byte("<html><body>x</body></html>"), nil); code != http.StatusUnsupportedMediaType {
+		t.Errorf("html upload: status %d, want 415", code)
+	}
+	if code := upload(t, r, path+"/attachments", post.EditToken, "big.txt", bytes.Repeat([]byte("a"), 70<<10), nil); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status %d, want 413", code)
+	}
+
+	var uploaded struct {
+		Attachment models.Attachment `json:"attachment"`
+	}
+	if code := upload(t, r, path+"/attachments", post.EditToken, `C:\photos\..\a"b.jpg`, photo, &uploaded); code != http.StatusCreated {
+		t.Fatalf("upload: status %d", code)
+	}
+	a := uploaded.Attachment
+	if a.Filename != `a"b.jpg` || a.ContentType != "image/jpeg" || a.Width != 640 || a.URL == "" || a.ThumbnailURL == "" {
+		t.Errorf("unexpected attachment: %+v", a)
+	}
+	upload(t, r, path+"/attachments", post.EditToken, "notes.txt", []byte("notes\n"), nil)
+	if code := upload(t, r, path+"/attachments", post.EditToken, "third.txt", []byte("third\n"), nil); code != http.StatusBadRequest {
+		t.Errorf("over limit: status %d, want 400", code)
+	}
+	if code := upload(t, r, commentPath+"/attachments", comment.EditToken, "c.txt", []byte("comment file\n"), nil); code != http.StatusCreated {
+		t.Errorf("comment upload: status %d", code)
+	}
+
+	var resp struct {
+		Post models.Post `json:"post"`
+	}
+	doJSON(t, r, "GET", path, "", &resp)
+	if len(resp.Post.Attachments) != 2 || resp.Post.Attachments[0].ID != a.ID {
+		t.Errorf("post attachments = %+v", resp.Post.Attachments)
+	}
+	if len(resp.Post.Comments) != 1 || len(resp.Post.Comments[0].Attachments) != 1 {
+		t.Errorf("comment attachments = %+v", resp.Post.Comments)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", a.URL, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/jpeg" || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("download: status %d, headers %v", w.Code, w.Header())
+	}
+	if bytes.Contains(w.Body.Bytes(), []byte("GPS")) {
+		t.Error("downloaded image still has metadata")
+	}
+	req := httptest.NewRequest("GET", a.URL, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("conditional download: status %d, want 304", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", a.ThumbnailURL, nil))
+	if thumb, err := jpeg.DecodeConfig(w.Body); err != nil || thumb.Width != media.ThumbnailSize {
+		t.Errorf("thumbnail: %+v, %v", thumb, err)
+	}
+
+	text := resp.Post.Attachments[1]
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", text.URL, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Disposition") != `attachment; filename=notes.txt` {
+		t.Errorf("text download: status %d, disposition %q", w.Code, w.Header().Get("Content-Disposition"))
+	}
+	if text.ThumbnailURL != "" {
+		t.Errorf("text file has thumbnail url %q", text.ThumbnailURL)
+	}
+}
+
+func TestAttachmentGarbageCollection(t *testing.T) {
+	restoreDefaultConfig(t)
+	store := database.NewMemoryStore()
+	blobs, err := blob.NewDiskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouterWithBlobs(store, blobs)
+
+	// 两个帖子共用同一个文件，另一个文件只属于一小时后过期的帖子
+	now := utils.NowUTC()
+	token, hash, _ := utils.NewEditToken()
+	oldID, _ := store.CreatePost(&models.Post{Content: "old", Author: "a", CreatedAt: now, DeleteAt: now.Add(time.Hour), EditTokenHash: hash}, nil, nil)
+	oldPath := "/api/posts/" + strconv.FormatInt(oldID, 10)
+	var live struct {
+		PostID    int64  `json:"post_id"`
+		EditToken string `json:"edit_token"`
+	}
+	doJSON(t, r, "POST", "/api/posts", `{"content":"live"}`, &live)
+	livePath := "/api/posts/" + strconv.FormatInt(live.PostID, 10)
+
+	shared, only := []byte("shared\n"), []byte("only old\n")
+	for _, u := range []struct {
+		path, token string
+		data        []byte
+	}{{oldPath, token, shared}, {oldPath, token, only}, {livePath, live.EditToken, shared}} {
+		if code := upload(t, r, u.path+"/attachments", u.token, "f.txt", u.data, nil); code != http.StatusCreated {
+			t.Fatalf("upload: status %d", code)
+		}
+	}
+
+	later := now.Add(3 * time.Hour)
+	if n, err := store.DeleteOldPosts(later); err != nil || n != 1 {
+		t.Fatalf("DeleteOldPosts = %d, %v", n, err)
+	}
+
+	ctx := context.Background()
+	if n, err := blob.Sweep(ctx, blobs, store.BlobKeys, later); err != nil || n != 1 {
+		t.Fatalf("Sweep = %d, %v; want 1", n, err)
+	}
+	if _, err := blobs.Open(ctx, blob.Key(only)); err != blob.ErrNotFound {
+		t.Errorf("file of deleted post remains: %v", err)
+	}
+	if _, err := blobs.Open(ctx, blob.Key(shared)); err != nil {
+		t.Errorf("shared file was removed: %v", err)
+	}
+}
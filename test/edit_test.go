@@ -0,0 +1,62 @@
+package test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/handlers"
+)
+
+func TestEditTokens(t *testing.T) {
+	r := newTestRouter(database.NewMemoryStore())
+
+	var post struct {
+		PostID    int64  `json:"post_id"`
+		EditToken string `json:"edit_token"`
+	}
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"typo"}`, &post); code != http.StatusCreated || post.EditToken == "" {
+		t.Fatalf("create post: status %d, token %q", code, post.EditToken)
+	}
+	path := "/api/posts/" + strconv.FormatInt(post.PostID, 10)
+
+	var comment struct {
+		CommentID int64  `json:"comment_id"`
+		EditToken string `json:"edit_token"`
+	}
+	if code := doJSON(t, r, "POST", path+"/comments", `{"content":"reply"}`, &comment); code != http.StatusCreated || comment.EditToken == "" {
+		t.Fatalf("add comment: status %d, token %q", code, comment.EditToken)
+	}
+	if comment.EditToken == post.EditToken {
+		t.Fatal("each item should get its own token")
+	}
+	commentPath := path + "/comments/" + strconv.FormatInt(comment.CommentID, 10)
+	postToken := map[string]string{handlers.EditTokenHeader: post.EditToken}
+	commentToken := map[string]string{handlers.EditTokenHeader: comment.EditToken}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header map[string]string
+		want   int
+	}{
+		{"missing token", "PUT", path, `{"content":"x"}`, nil, http.StatusUnauthorized},
+		{"wrong token", "PUT", path, `{"content":"x"}`, commentToken, http.StatusForbidden},
+		{"empty content", "PUT", path, `{"content":""}`, postToken, http.StatusBadRequest},
+		{"missing post", "PUT", "/api/posts/999", `{"content":"x"}`, postToken, http.StatusNotFound},
+		{"edit post", "PUT", path, `{"content":"fixed"}`, postToken, http.StatusOK},
+		{"comment with post token", "PUT", commentPath, `{"content":"x"}`, postToken, http.StatusForbidden},
+		{"edit comment", "PUT", commentPath, `{"content":"edited"}`, commentToken, http.StatusOK},
+		{"delete comment", "DELETE", commentPath, "", commentToken, http.StatusOK},
+		{"delete comment again", "DELETE", commentPath, "", commentToken, http.StatusNotFound},
+		{"delete post", "DELETE", path, "", postToken, http.StatusOK},
+		{"deleted post", "GET", path, "", nil, http.StatusNotFound},
+	} {
+		if code := doJSONWithHeader(t, r, tc.method, tc.path, tc.body, tc.header, nil); code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, code, tc.want)
+		}
+	}
+}
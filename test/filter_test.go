@@ -0,0 +1,243 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/filter"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+func TestFilterCheck(t *testing.T) {
+	f, err := filter.New([]filter.Rule{
+		{Name: "spam", Pattern: "cheap pills", Action: filter.ActionReplace},
+		{Name: "link", Match: filter.MatchRegex, Pattern: `https?://\S*\.example\b\S*`, Action: filter.ActionReplace, Replacement: "[链接]"},
+		{Name: "slur", Match: filter.MatchNormalized, Pattern: "badword", Action: filter.ActionReject},
+		{Name: "casino", Match: filter.MatchNormalized, Pattern: "赌场", Action: filter.ActionHold},
+		{Name: "quiet", Pattern: "follow me", Action: filter.ActionShadowHide},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		content, action, want string
+	}{
+		{"hello world", "", "hello world"},
+		{"Buy CHEAP PILLS here, cheap pills!", filter.ActionReplace, "Buy *** here, ***!"},
+		{"see http://shop.example/x now", filter.ActionReplace, "see [链接] now"},
+		// 全角字符、大小写和零宽字符都不影响归一化匹配
+		{"ｂａｄｗｏｒｄ", filter.ActionReject, "ｂａｄｗｏｒｄ"},
+		{"BAD\u200bWO\u00adRD", filter.ActionReject, "BAD\u200bWO\u00adRD"},
+		{"b a d w o r d", "", "b a d w o r d"},
+		{"去赌\u200d场玩", filter.ActionHold, "去赌\u200d场玩"},
+		// 多条规则命中时执行最严重的动作，替换仍然生效
+		{"follow me for cheap pills", filter.ActionShadowHide, "follow me for ***"},
+		{"cheap pills 赌场", filter.ActionHold, "*** 赌场"},
+	}
+	for _, tt := range tests {
+		got := f.Check(tt.content)
+		if got.Action != tt.action || got.Content != tt.want {
+			t.Errorf("Check(%q) = %q %q, want %q %q", tt.content, got.Action, got.Content, tt.action, tt.want)
+		}
+	}
+
+	got := f.Check("cheap pills and ｂａｄword")
+	if got.RuleNames() != "spam, slur" || got.Hidden() {
+		t.Errorf("hits = %+v", got.Hits)
+	}
+}
+
+func TestFilterRuleFiles(t *testing.T) {
+	toml := `
+[[rules]]
+name = "links"
+match = "regex"
+pattern = 'https?://'
+action = "hold"
+
+[[rules]]
+pattern = "spam"
+action = "replace"
+`
+	f, err := filter.Parse("rules.toml", []byte(toml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Check("spam at https://x"); got.RuleNames() != "links, rule-2" || got.Content != "*** at https://x" {
+		t.Errorf("toml rules: %+v", got)
+	}
+
+	yaml := `
+rules:
+  - name: shout
+    match: normalized
+    pattern: ＡＢＣ
+    action: shadow_hide
+`
+	f, err = filter.Parse("rules.yml", []byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Check("abc"); got.Action != filter.ActionShadowHide {
+		t.Errorf("yaml rules: %+v", got)
+	}
+
+	invalid := map[string]string{
+		"rules.toml": "[[rules]]\npattern = \"x\"\naction = \"ban\"\n",
+		"rules.yaml": "rules:\n  - pattern: \"(\"\n    match: regex\n    action: reject\n",
+		"rules.json": `{"rules": []}`,
+		"empty.yaml": "rules:\n  - pattern: \"\u200b\"\n    match: normalized\n    action: reject\n",
+	}
+	for name, data := range invalid {
+		if _, err := filter.Parse(name, []byte(data)); err == nil {
+			t.Errorf("Parse(%s) should fail", name)
+		}
+	}
+
+	// 没有规则的文件通常是写错了顶层的键，不能悄悄关闭过滤
+	path := writeConfigFile(t, "rules.toml", "[[rule]]\npattern = \"x\"\naction = \"reject\"\n")
+	if _, err := filter.Open(path); err == nil {
+		t.Error("rule file without rules should fail")
+	}
+	if f, err := filter.Open(""); f != nil || err != nil {
+		t.Errorf("Open(\"\") = %v, %v", f, err)
+	}
+}
+
+// 使用指定的规则，测试结束后清空
+func useFilterRules(t *testing.T, rules ...filter.Rule) {
+	t.Helper()
+	f, err := filter.New(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter.Use(f)
+	t.Cleanup(func() { filter.Use(nil) })
+}
+
+func TestContentFilter(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.DefaultConfig()
+	cfg.AdminToken = "s3cret"
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	useFilterRules(t,
+		filter.Rule{Name: "banned", Match: filter.MatchNormalized, Pattern: "forbidden", Action: filter.ActionReject},
+		filter.Rule{Name: "rude", Pattern: "darn", Action: filter.ActionReplace},
+		filter.Rule{Name: "review", Pattern: "crypto", Action: filter.ActionHold},
+		filter.Rule{Name: "spammer", Pattern: "subscribe", Action: filter.ActionShadowHide},
+	)
+	r := newAdminTestRouter(database.NewMemoryStore())
+	admin := map[string]string{"Authorization": "Bearer s3cret"}
+
+	type created struct {
+		Message   string `json:"message"`
+		PostID    int64  `json:"post_id"`
+		CommentID int64  `json:"comment_id"`
+		EditToken string `json:"edit_token"`
+	}
+	var got struct {
+		Post models.Post `json:"post"`
+	}
+
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"ｆｏｒｂｉｄｄｅｎ"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("rejected post: status %d, want 400", code)
+	}
+
+	// 替换后的内容正常发布
+	var post created
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"darn it"}`, &post); code != http.StatusCreated {
+		t.Fatalf("replaced post: status %d", code)
+	}
+	path := "/api/posts/" + strconv.FormatInt(post.PostID, 10)
+	doJSON(t, r, "GET", path, "", &got)
+	if got.Post.Content != "*** it" {
+		t.Errorf("content = %q, want replaced", got.Post.Content)
+	}
+
+	// 等待审核的帖子对读者隐藏，发布者得到提示并可以用编辑令牌查看
+	var held created
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"buy crypto"}`, &held); code != http.StatusAccepted || held.EditToken == "" {
+		t.Fatalf("held post: status %d %+v", code, held)
+	}
+	if code := doJSON(t, r, "GET", "/api/posts/"+strconv.FormatInt(held.PostID, 10), "", nil); code != http.StatusNotFound {
+		t.Errorf("held post visible: status %d", code)
+	}
+	if code := doJSONWithHeader(t, r, "GET", "/api/posts/"+strconv.FormatInt(held.PostID, 10), "", map[string]string{"X-Edit-Token": held.EditToken}, &got); code != http.StatusOK || got.Post.Status != models.StatusPending {
+		t.Errorf("own held post: status %d %+v", code, got.Post)
+	}
+
+	// 悄悄隐藏的评论响应与正常发布相同
+	var comment created
+	if code := doJSON(t, r, "POST", path+"/comments", `{"content":"please subscribe"}`, &comment); code != http.StatusCreated || comment.Message != "评论添加成功" {
+		t.Fatalf("shadow-hidden comment: status %d %+v", code, comment)
+	}
+	doJSON(t, r, "GET", path, "", &got)
+	if len(got.Post.Comments) != 1 || !got.Post.Comments[0].Hidden || got.Post.Comments[0].Content != "" {
+		t.Errorf("shadow-hidden comment exposed: %+v", got.Post.Comments)
+	}
+
+	// 客户端不能自己设置管理标记
+	var flagged created
+	doJSON(t, r, "POST", "/api/posts", `{"content":"hi","locked":true,"pinned":true}`, &flagged)
+	doJSON(t, r, "GET", "/api/posts/"+strconv.FormatInt(flagged.PostID, 10), "", &got)
+	if got.Post.Locked || got.Post.Pinned {
+		t.Errorf("client set moderation flags: %+v", got.Post)
+	}
+
+	// 修改时同样检查
+	token := map[string]string{"X-Edit-Token": post.EditToken}
+	if code := doJSONWithHeader(t, r, "PUT", path, `{"content":"forbidden"}`, token, nil); code != http.StatusBadRequest {
+		t.Errorf("rejected edit: status %d, want 400", code)
+	}
+	if code := doJSONWithHeader(t, r, "PUT", path, `{"content":"crypto tips"}`, token, nil); code != http.StatusAccepted {
+		t.Errorf("held edit: status %d, want 202", code)
+	}
+	if code := doJSON(t, r, "GET", path, "", nil); code != http.StatusNotFound {
+		t.Errorf("held edit visible: status %d", code)
+	}
+
+	// 放入审核队列和隐藏都记录在审计日志中
+	var audit struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}
+	doJSONWithHeader(t, r, "GET", "/api/admin/audit", "", admin, &audit)
+	if len(audit.Entries) != 3 {
+		t.Fatalf("audit log = %+v", audit.Entries)
+	}
+	// 与内容一起写入，不记录作者的IP
+	for _, e := range audit.Entries {
+		if e.Actor != models.ActorFilter || !strings.HasPrefix(e.Reason, "命中过滤规则") || e.PostID == 0 || e.IP != "" {
+			t.Errorf("audit entry = %+v", e)
+		}
+	}
+	if audit.Entries[0].Action != models.ActionHoldPost || audit.Entries[2].Action != models.ActionHoldPost {
+		t.Errorf("hold entries = %+v", audit.Entries)
+	}
+	if e := audit.Entries[1]; e.Action != models.ActionHideComment || e.CommentID != comment.CommentID || !strings.Contains(e.Reason, "spammer") {
+		t.Errorf("comment entry = %+v", e)
+	}
+
+	// 命中次数按规则和动作输出，计数在进程内累计
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	body := w.Body.String()
+	for _, want := range []string{
+		"nilbbs_filter_rules 4\n",
+		`nilbbs_filter_hits_total{rule="review",action="hold"} `,
+		`nilbbs_filter_hits_total{rule="banned",action="reject"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/blob"
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// 使用内存存储搭建测试路由
+func newTestRouter(store database.Store) *gin.Engine {
+	return newTestRouterWithBlobs(store, nil)
+}
+
+// 搭建测试路由，附件文件保存在 blobs 中
+func newTestRouterWithBlobs(store database.Store, blobs blob.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := handlers.NewHandler(store, blobs)
+	r.GET("/api/posts", h.GetAllPosts)
+	r.GET("/api/posts/:id", h.GetPostByID)
+	r.GET("/api/posts/:id/revisions", h.ListRevisions)
+	r.POST("/api/posts", h.CreatePost)
+	r.PUT("/api/posts/:id", h.UpdatePost)
+	r.DELETE("/api/posts/:id", h.DeletePost)
+	r.POST("/api/posts/:id/comments", h.AddComment)
+	r.GET("/api/posts/:id/comments/:commentId", h.GetOwnComment)
+	r.PUT("/api/posts/:id/comments/:commentId", h.UpdateComment)
+	r.DELETE("/api/posts/:id/comments/:commentId", h.DeleteComment)
+	r.GET("/api/search", h.Search)
+	r.POST("/api/posts/:id/attachments", h.UploadAttachment)
+	r.POST("/api/posts/:id/comments/:commentId/attachments", h.UploadAttachment)
+	r.GET("/api/attachments/:id", h.GetAttachment)
+	r.GET("/api/attachments/:id/thumbnail", h.GetAttachmentThumbnail)
+	r.POST("/api/posts/:id/report", h.Report)
+	r.POST("/api/posts/:id/comments/:commentId/report", h.Report)
+	return r
+}
+
+func doJSON(t *testing.T, r http.Handler, method, path, body string, out interface{}) int {
+	t.Helper()
+	return doJSONWithHeader(t, r, method, path, body, nil, out)
+}
+
+func doJSONWithHeader(t *testing.T, r http.Handler, method, path, body string, header map[string]string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid json %q: %v", method, path, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestPostAndCommentFlow(t *testing.T) {
+	r := newTestRouter(database.NewMemoryStore())
+
+	var created struct {
+		PostID int64 `json:"post_id"`
+	}
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"hello"}`, &created); code != http.StatusCreated {
+		t.Fatalf("create post: status %d", code)
+	}
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":""}`, nil); code != http.StatusBadRequest {
+		t.Errorf("empty post: status %d, want 400", code)
+	}
+
+	path := "/api/posts/" + strconv.FormatInt(created.PostID, 10)
+	if code := doJSON(t, r, "POST", path+"/comments", `{"content":"reply","author":"bob"}`, nil); code != http.StatusCreated {
+		t.Fatalf("add comment: status %d", code)
+	}
+
+	var detail struct {
+		Post struct {
+			Author   string `json:"author"`
+			Comments []struct {
+				Content string `json:"content"`
+				Author  string `json:"author"`
+			} `json:"comments"`
+		} `json:"post"`
+	}
+	if code := doJSON(t, r, "GET", path, "", &detail); code != http.StatusOK {
+		t.Fatalf("get post: status %d", code)
+	}
+	if detail.Post.Author != "匿名用户" {
+		t.Errorf("default author = %q", detail.Post.Author)
+	}
+	if len(detail.Post.Comments) != 1 || detail.Post.Comments[0].Author != "bob" {
+		t.Errorf("unexpected comments: %+v", detail.Post.Comments)
+	}
+
+	if code := doJSON(t, r, "GET", "/api/posts/999", "", nil); code != http.StatusNotFound {
+		t.Errorf("missing post: status %d, want 404", code)
+	}
+}
+
+func TestRequestTimezone(t *testing.T) {
+	r := newTestRouter(database.NewMemoryStore())
+	doJSON(t, r, "POST", "/api/posts", `{"content":"hello"}`, nil)
+
+	var list struct {
+		Posts []struct {
+			CreatedAt      time.Time `json:"created_at"`
+			LastActivityAt time.Time `json:"last_activity_at"`
+		} `json:"posts"`
+	}
+	if code := doJSON(t, r, "GET", "/api/posts?tz=America/New_York", "", &list); code != http.StatusOK {
+		t.Fatalf("list posts: status %d", code)
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+	_, wantOffset := list.Posts[0].CreatedAt.In(ny).Zone()
+	if _, offset := list.Posts[0].CreatedAt.Zone(); offset != wantOffset {
+		t.Errorf("offset = %d, want %d", offset, wantOffset)
+	}
+	if _, offset := list.Posts[0].LastActivityAt.Zone(); offset != wantOffset {
+		t.Errorf("last activity offset = %d, want %d", offset, wantOffset)
+	}
+
+	if code := doJSON(t, r, "GET", "/api/posts?tz=Mars/Olympus", "", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid tz: status %d, want 400", code)
+	}
+}
+
+func TestPostListPagination(t *testing.T) {
+	r := newTestRouter(database.NewMemoryStore())
+	for i := 0; i < 3; i++ {
+		doJSON(t, r, "POST", "/api/posts", `{"content":"hello"}`, nil)
+	}
+
+	var page struct {
+		Posts []struct {
+			ID int64 `json:"id"`
+		} `json:"posts"`
+		NextCursor string `json:"next_cursor"`
+	}
+	doJSON(t, r, "GET", "/api/posts?limit=2", "", &page)
+	if len(page.Posts) != 2 || page.NextCursor == "" {
+		t.Fatalf("first page: %+v", page)
+	}
+	doJSON(t, r, "GET", "/api/posts?limit=2&cursor="+page.NextCursor, "", &page)
+	if len(page.Posts) != 1 || page.NextCursor != "" {
+		t.Fatalf("second page: %+v", page)
+	}
+
+	if code := doJSON(t, r, "GET", "/api/posts?cursor=!!", "", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid cursor: status %d, want 400", code)
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/markup"
+	"github.com/Mammoth777/nilbbs/models"
+	"golang.org/x/net/html"
+)
+
+// 使用 go test ./test -run TestMarkupGolden -update 重新生成期望的输出
+var updateGolden = flag.Bool("update", false, "重新生成 testdata 中的期望输出")
+
+// 渲染结果中允许出现的标签及其属性，即使期望输出被错误地更新也能发现不安全的输出
+var allowedTags = map[string][]string{
+	"p": nil, "br": nil, "em": nil, "strong": nil, "pre": nil, "ul": nil, "li": nil,
+	"ol":   {"start"},
+	"code": {"class"},
+	"span": {"class"},
+	"a":    {"href", "rel", "target"},
+}
+
+// checkSafeHTML 检查输出中只有允许的标签和属性，链接只使用安全的协议
+func checkSafeHTML(t *testing.T, out string) {
+	t.Helper()
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				t.Errorf("invalid html: %v", z.Err())
+			}
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			attrs, ok := allowedTags[tok.Data]
+			if !ok {
+				t.Errorf("unexpected tag <%s> in:\n%s", tok.Data, out)
+				continue
+			}
+			for _, attr := range tok.Attr {
+				if !slices.Contains(attrs, attr.Key) {
+					t.Errorf("unexpected attribute %s on <%s> in:\n%s", attr.Key, tok.Data, out)
+				}
+				if attr.Key == "href" && !safeHref.MatchString(attr.Val) {
+					t.Errorf("unsafe link %q in:\n%s", attr.Val, out)
+				}
+			}
+		}
+	}
+}
+
+// 链接只允许 http、https、mailto 和不带协议的相对地址
+var safeHref = regexp.MustCompile(`^(?i)(https?://|mailto:|/|#|[^:]*$)`)
+
+// testdata/markup 下 .md 文件按Markdown渲染，.txt 文件按纯文本渲染，期望输出为同名的 .html 文件
+func TestMarkupGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "markup", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs {
+		ext := filepath.Ext(input)
+		format := map[string]string{".md": markup.FormatMarkdown, ".txt": markup.FormatPlain}[ext]
+		if format == "" {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(input), ext)
+		t.Run(name, func(t *testing.T) {
+			content, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := markup.Render(string(content), format)
+			checkSafeHTML(t, got)
+
+			golden := strings.TrimSuffix(input, ext) + ".html"
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"", markup.FormatPlain, true},
+		{"plain", markup.FormatPlain, true},
+		{"markdown", markup.FormatMarkdown, true},
+		{"html", "", false},
+	} {
+		if got, ok := markup.ParseFormat(tc.in); got != tc.want || ok != tc.ok {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestContentHTML(t *testing.T) {
+	r := newTestRouter(database.NewMemoryStore())
+
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"x","format":"html"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("invalid format: status %d, want 400", code)
+	}
+
+	var created struct {
+		PostID int64 `json:"post_id"`
+	}
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"**hi** <b>x</b>","format":"markdown"}`, &created); code != http.StatusCreated {
+		t.Fatalf("create post: status %d", code)
+	}
+	path := "/api/posts/" + strconv.FormatInt(created.PostID, 10)
+	if code := doJSON(t, r, "POST", path+"/comments", `{"content":"**hi** <script>x</script>","content_html":"<script>x</script>"}`, nil); code != http.StatusCreated {
+		t.Fatalf("add comment: status %d", code)
+	}
+
+	var resp struct {
+		Post models.Post `json:"post"`
+	}
+	if code := doJSON(t, r, "GET", path, "", &resp); code != http.StatusOK {
+		t.Fatalf("get post: status %d", code)
+	}
+	if want := "<p><strong>hi</strong> &lt;b&gt;x&lt;/b&gt;</p>\n"; resp.Post.ContentHTML != want || resp.Post.Format != markup.FormatMarkdown {
+		t.Errorf("post html = %q (%s), want %q", resp.Post.ContentHTML, resp.Post.Format, want)
+	}
+	if resp.Post.Content != "**hi** <b>x</b>" {
+		t.Errorf("raw content changed: %q", resp.Post.Content)
+	}
+	comment := resp.Post.Comments[0]
+	if want := "**hi** &lt;script&gt;x&lt;/script&gt;"; comment.ContentHTML != want || comment.Format != markup.FormatPlain {
+		t.Errorf("comment html = %q (%s), want %q", comment.ContentHTML, comment.Format, want)
+	}
+}
@@ -0,0 +1,119 @@
+package test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+)
+
+func TestMigrations(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "nilbbs.db")
+
+	s, err := database.OpenSQL(dsn)
+	if err != nil {
+		t.Fatalf("OpenSQL: %v", err)
+	}
+	defer s.Close()
+	m, err := s.Migrator()
+	if err != nil {
+		t.Fatalf("Migrator: %v", err)
+	}
+
+	current, pending, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if current != 0 || len(pending) == 0 || pending[len(pending)-1].Version != m.Latest() {
+		t.Fatalf("fresh database: current=%d pending=%d latest=%d", current, len(pending), m.Latest())
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	applied, err := m.Up()
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second Up: applied=%d err=%v", len(applied), err)
+	}
+	if current, _ := m.Current(); current != m.Latest() {
+		t.Fatalf("current = %d, want %d", current, m.Latest())
+	}
+}
+
+func TestMigrationsRefuseNewerSchema(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "nilbbs.db")
+	s, err := database.NewSQLiteStore(dsn)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	m, _ := s.Migrator()
+	latest := m.Latest()
+	s.Close()
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	_, err = db.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, 'future', '2000-01-01 00:00:00')", latest+1)
+	db.Close()
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	_, err = database.Open(dsn)
+	var tooNew *database.SchemaTooNewError
+	if !errors.As(err, &tooNew) {
+		t.Fatalf("Open on newer schema: got %v, want SchemaTooNewError", err)
+	}
+}
+
+// 版本1的数据以中国标准时间存储，迁移后应转换为UTC
+func TestMigrateCSTTimestampsToUTC(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "nilbbs.db")
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE posts (id INTEGER PRIMARY KEY AUTOINCREMENT, content TEXT NOT NULL, author TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP, delete_at TIMESTAMP)`,
+		`CREATE TABLE comments (id INTEGER PRIMARY KEY AUTOINCREMENT, content TEXT NOT NULL, post_id INTEGER NOT NULL,
+			author TEXT NOT NULL, created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE TABLE schema_version (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TIMESTAMP NOT NULL)`,
+		`INSERT INTO schema_version VALUES (1, 'init', '2025-05-06 00:00:00')`,
+		`INSERT INTO posts (content, author, created_at, delete_at) VALUES ('p', 'a', '2025-05-06 16:31:34', '2099-01-01 08:00:00')`,
+		`INSERT INTO comments (content, post_id, author, created_at) VALUES ('c', 1, 'b', '2025-05-07 09:00:00')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	s, err := database.Open(dsn)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	post, err := s.GetPost(1, time.Date(2025, 5, 8, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetPost: %v", err)
+	}
+	if want := time.Date(2025, 5, 6, 8, 31, 34, 0, time.UTC); !post.CreatedAt.Equal(want) {
+		t.Errorf("created_at = %v, want %v", post.CreatedAt, want)
+	}
+	if want := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC); !post.DeleteAt.Equal(want) {
+		t.Errorf("delete_at = %v, want %v", post.DeleteAt, want)
+	}
+	if want := time.Date(2025, 5, 7, 1, 0, 0, 0, time.UTC); post.CommentCount != 1 || !post.LastActivityAt.Equal(want) {
+		t.Errorf("backfilled summary: comment_count=%d last_activity_at=%v", post.CommentCount, post.LastActivityAt)
+	}
+	comments, _ := s.ListComments(1, database.Page{})
+	if want := time.Date(2025, 5, 7, 1, 0, 0, 0, time.UTC); len(comments) != 1 || !comments[0].CreatedAt.Equal(want) {
+		t.Errorf("comments = %+v, want created_at %v", comments, want)
+	}
+}
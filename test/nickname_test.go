@@ -11,4 +11,4 @@ func TestNickname(t *testing.T) {
 	name := nickname.GetRandomNickname()
 	log.Println(name)
 
-}
\ No newline at end of file
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+func TestPosterIDHash(t *testing.T) {
+	key := []byte("key")
+	day := time.Date(2030, 1, 2, 23, 0, 0, 0, time.UTC)
+	id := utils.PosterID(key, "192.0.2.1", 1, day)
+	if len(id) != 8 {
+		t.Fatalf("PosterID = %q, want 8 characters", id)
+	}
+	// 日期按UTC计算，与显示时区无关
+	if got := utils.PosterID(key, "192.0.2.1", 1, day.Add(-time.Hour).In(time.FixedZone("UTC+8", 8*3600))); got != id {
+		t.Errorf("same UTC day: %q != %q", got, id)
+	}
+	if got := utils.PosterID(key, "192.0.2.1", 1, day.Add(2*time.Hour)); got == id {
+		t.Error("poster id should change with the day")
+	}
+	for name, got := range map[string]string{
+		"post":   utils.PosterID(key, "192.0.2.1", 2, day),
+		"client": utils.PosterID(key, "192.0.2.2", 1, day),
+		"key":    utils.PosterID([]byte("other"), "192.0.2.1", 1, day),
+	} {
+		if got == id {
+			t.Errorf("poster id should change with the %s", name)
+		}
+	}
+}
+
+func TestReporterHash(t *testing.T) {
+	key := []byte("key")
+	hash := utils.ReporterHash(key, "192.0.2.1", 1, 0)
+	if hash != utils.ReporterHash(key, "192.0.2.1", 1, 0) {
+		t.Fatal("reporter hash should be stable")
+	}
+	// 不带密钥的哈希可以通过穷举IP还原
+	if hash == utils.HashEditToken("192.0.2.1") {
+		t.Error("reporter hash must not be a plain hash of the client")
+	}
+	for name, got := range map[string]string{
+		"client":  utils.ReporterHash(key, "192.0.2.2", 1, 0),
+		"post":    utils.ReporterHash(key, "192.0.2.1", 2, 0),
+		"comment": utils.ReporterHash(key, "192.0.2.1", 1, 1),
+		"key":     utils.ReporterHash([]byte("other"), "192.0.2.1", 1, 0),
+	} {
+		if got == hash {
+			t.Errorf("reporter hash should change with the %s", name)
+		}
+	}
+}
+
+func TestPosterIDs(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.DefaultConfig()
+	cfg.PosterIDKey = "test-key"
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter(database.NewMemoryStore())
+	alice := map[string]string{"X-Forwarded-For": "192.0.2.1"}
+	bob := map[string]string{"X-Forwarded-For": "2001:db8::1"}
+	// 同一 /64 网段的IPv6地址视为同一客户端
+	bobAgain := map[string]string{"X-Forwarded-For": "2001:db8::2"}
+
+	var created struct {
+		PostID int64 `json:"post_id"`
+	}
+	doJSONWithHeader(t, r, "POST", "/api/posts", `{"content":"thread one"}`, alice, &created)
+	path := "/api/posts/" + strconv.FormatInt(created.PostID, 10)
+	doJSONWithHeader(t, r, "POST", path+"/comments", `{"content":"op here"}`, alice, nil)
+	doJSONWithHeader(t, r, "POST", path+"/comments", `{"content":"hi","author":"alice"}`, bob, nil)
+	doJSONWithHeader(t, r, "POST", path+"/comments", `{"content":"me again"}`, bobAgain, nil)
+
+	var got struct {
+		Post models.Post `json:"post"`
+	}
+	if code := doJSON(t, r, "GET", path, "", &got); code != http.StatusOK {
+		t.Fatalf("get post: status %d", code)
+	}
+	op := got.Post.PosterID
+	if op == "" || len(got.Post.Comments) != 3 {
+		t.Fatalf("post = %+v", got.Post)
+	}
+	comments := got.Post.Comments
+	if comments[0].PosterID != op {
+		t.Errorf("op comment id = %q, want %q", comments[0].PosterID, op)
+	}
+	// 自己填写的昵称不影响发帖人ID
+	if comments[1].PosterID == op || comments[1].PosterID != comments[2].PosterID {
+		t.Errorf("poster ids = %q %q %q", op, comments[1].PosterID, comments[2].PosterID)
+	}
+
+	// 同一客户端在另一个帖子中的ID不同
+	doJSONWithHeader(t, r, "POST", "/api/posts", `{"content":"thread two"}`, alice, &created)
+	doJSON(t, r, "GET", "/api/posts/"+strconv.FormatInt(created.PostID, 10), "", &got)
+	if got.Post.PosterID == "" || got.Post.PosterID == op {
+		t.Errorf("poster id in another thread = %q, first thread %q", got.Post.PosterID, op)
+	}
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/Mammoth777/nilbbs/pow"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestProofOfWork(t *testing.T) {
+	issuer, err := pow.NewIssuer([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c, err := issuer.Issue(12, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Difficulty != 12 || !c.ExpiresAt.Equal(now.Add(pow.ChallengeTTL)) {
+		t.Fatalf("challenge = %+v", c)
+	}
+	nonce := pow.Solve(c.Challenge, c.Difficulty)
+	if pow.LeadingZeros(c.Challenge, nonce) < 12 {
+		t.Fatalf("Solve returned insufficient nonce %q", nonce)
+	}
+
+	// 找一个不满足难度的 nonce
+	bad := "x"
+	for pow.LeadingZeros(c.Challenge, bad) >= 12 {
+		bad += "x"
+	}
+	if err := issuer.Verify(c.Challenge, bad, now); err != pow.ErrInsufficientWork {
+		t.Fatalf("insufficient work: %v", err)
+	}
+	// 修改难度或签名的挑战无效
+	encoded, sig, _ := strings.Cut(c.Challenge, ".")
+	if err := issuer.Verify(encoded+"."+sig[1:], nonce, now); err != pow.ErrInvalid {
+		t.Fatalf("tampered signature: %v", err)
+	}
+	other, _ := pow.NewIssuer([]byte("other"))
+	if err := other.Verify(c.Challenge, nonce, now); err != pow.ErrInvalid {
+		t.Fatalf("other secret: %v", err)
+	}
+	if err := issuer.Verify(c.Challenge, nonce, now.Add(pow.ChallengeTTL)); err != pow.ErrExpired {
+		t.Fatalf("expired: %v", err)
+	}
+
+	if err := issuer.Verify(c.Challenge, nonce, now.Add(time.Minute)); err != nil {
+		t.Fatalf("valid proof: %v", err)
+	}
+	// 同一挑战不能再次使用，换一个 nonce 也不行
+	if err := issuer.Verify(c.Challenge, nonce, now.Add(2*time.Minute)); err != pow.ErrReplayed {
+		t.Fatalf("replayed: %v", err)
+	}
+	var another string
+	for k := 0; another == "" || pow.LeadingZeros(c.Challenge, another) < 12; k++ {
+		another = nonce + "-" + strconv.Itoa(k)
+	}
+	if err := issuer.Verify(c.Challenge, another, now.Add(2*time.Minute)); err != pow.ErrReplayed {
+		t.Fatalf("replayed with other nonce: %v", err)
+	}
+
+	// 挑战的使用顺序与签发顺序不同，先签发的过期后，后签发的仍然不能重放
+	early, _ := issuer.Issue(0, now)
+	late, _ := issuer.Issue(0, now.Add(time.Minute))
+	for _, ch := range []pow.Challenge{late, early} {
+		if err := issuer.Verify(ch.Challenge, "1", now.Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := issuer.Verify(late.Challenge, "1", early.ExpiresAt.Add(time.Second)); err != pow.ErrReplayed {
+		t.Fatalf("replayed after an earlier challenge expired: %v", err)
+	}
+
+	// 零难度的挑战任何 nonce 都满足
+	c0, _ := issuer.Issue(0, now)
+	if err := issuer.Verify(c0.Challenge, "1", now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := issuer.Issue(pow.MaxDifficulty+1, now); err == nil {
+		t.Fatal("difficulty above maximum accepted")
+	}
+}
+
+func TestProofOfWorkDifficulty(t *testing.T) {
+	issuer, _ := pow.NewIssuer(nil)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d := issuer.Difficulty(0, 20, now); d != 0 {
+		t.Fatalf("disabled difficulty = %d", d)
+	}
+	if d := issuer.Difficulty(4, 8, now); d != 4 {
+		t.Fatalf("idle difficulty = %d", d)
+	}
+
+	// 短时间内大量发帖后难度提高，但不超过上限
+	for i := 0; i < 4*pow.BaselineLoad; i++ {
+		c, _ := issuer.Issue(0, now)
+		if err := issuer.Verify(c.Challenge, "0", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := issuer.Difficulty(4, 8, now); d != 6 {
+		t.Fatalf("busy difficulty = %d, want 6", d)
+	}
+	if d := issuer.Difficulty(4, 5, now); d != 5 {
+		t.Fatalf("capped difficulty = %d, want 5", d)
+	}
+	// 一段时间没有发帖后恢复基础难度
+	if d := issuer.Difficulty(4, 8, now.Add(time.Hour)); d != 4 {
+		t.Fatalf("difficulty after quiet hour = %d, want 4", d)
+	}
+}
+
+func TestProofOfWorkMiddleware(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.CurrentConfig()
+	cfg.PowDifficulty = 8
+	utils.SetConfig(cfg)
+
+	issuer, _ := pow.NewIssuer(nil)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/pow/challenge", handlers.PowChallenge(issuer))
+	r.POST("/api/posts", handlers.RequireProofOfWork(issuer), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	post := func(header map[string]string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	var c pow.Challenge
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/pow/challenge", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil || c.Difficulty != 8 || c.Challenge == "" {
+		t.Fatalf("challenge = %d %s", w.Code, w.Body.String())
+	}
+
+	if code := post(nil); code != http.StatusForbidden {
+		t.Fatalf("missing proof = %d, want 403", code)
+	}
+	proof := map[string]string{"X-Pow-Challenge": c.Challenge, "X-Pow-Nonce": pow.Solve(c.Challenge, c.Difficulty)}
+	if code := post(proof); code != http.StatusCreated {
+		t.Fatalf("valid proof = %d", code)
+	}
+	if code := post(proof); code != http.StatusForbidden {
+		t.Fatalf("replayed proof = %d, want 403", code)
+	}
+
+	// 关闭后不需要工作量证明
+	cfg.PowDifficulty = 0
+	utils.SetConfig(cfg)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/pow/challenge", nil))
+	if strings.TrimSpace(w.Body.String()) != `{"difficulty":0}` {
+		t.Fatalf("disabled challenge = %s", w.Body.String())
+	}
+	if code := post(nil); code != http.StatusCreated {
+		t.Fatalf("disabled = %d", code)
+	}
+}
+
+// 浏览器中的 pow-worker.js 与服务器使用相同的哈希输入
+func TestProofOfWorkMatchesWorker(t *testing.T) {
+	if n := pow.LeadingZeros("abc.def", "33029"); n < 16 {
+		t.Fatalf("LeadingZeros = %d, want >= 16", n)
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+func TestParseQuotes(t *testing.T) {
+	refs := utils.ParseQuotes(">>12 and >>>3, >>12 again, >>0, >>>>7, a>>5b")
+	want := []utils.QuoteRef{{ID: 12}, {ID: 3, Post: true}, {ID: 7, Post: true}, {ID: 5}}
+	if fmt.Sprint(refs) != fmt.Sprint(want) {
+		t.Errorf("ParseQuotes = %v, want %v", refs, want)
+	}
+}
+
+func TestQuoteLinks(t *testing.T) {
+	store := database.NewMemoryStore()
+	r := newTestRouter(store)
+	now := utils.NowUTC()
+
+	expired, _ := store.CreatePost(&models.Post{Content: "old", Author: "a", CreatedAt: now.AddDate(0, 0, -8), DeleteAt: now.Add(-time.Hour)}, nil, nil)
+	expiredComment, _ := store.CreateComment(&models.Comment{Content: "old reply", PostID: expired, Author: "b", CreatedAt: now.AddDate(0, 0, -8)}, nil)
+	postID, _ := store.CreatePost(&models.Post{Content: fmt.Sprintf("was >>>%d", expired), Author: "a", CreatedAt: now, DeleteAt: now.AddDate(0, 0, 1)}, nil, nil)
+	first, _ := store.CreateComment(&models.Comment{Content: "first", PostID: postID, Author: "b", CreatedAt: now}, nil)
+	second, _ := store.CreateComment(&models.Comment{Content: fmt.Sprintf(">>%d yes, >>%d no", first, expiredComment), PostID: postID, Author: "b", CreatedAt: now}, nil)
+
+	var resp struct {
+		Post models.Post `json:"post"`
+	}
+	if code := doJSON(t, r, "GET", fmt.Sprintf("/api/posts/%d", postID), "", &resp); code != http.StatusOK {
+		t.Fatalf("get post: status %d", code)
+	}
+	if want := []models.QuoteLink{{ID: expired, Post: true, Dead: true}}; fmt.Sprint(resp.Post.Quotes) != fmt.Sprint(want) {
+		t.Errorf("post quotes = %+v, want %+v", resp.Post.Quotes, want)
+	}
+	if len(resp.Post.Comments) != 2 {
+		t.Fatalf("unexpected comments: %+v", resp.Post.Comments)
+	}
+	if want := []models.Backlink{{PostID: postID, CommentID: second}}; fmt.Sprint(resp.Post.Comments[0].Backlinks) != fmt.Sprint(want) {
+		t.Errorf("backlinks = %+v, want %+v", resp.Post.Comments[0].Backlinks, want)
+	}
+	want := []models.QuoteLink{{ID: first, PostID: postID}, {ID: expiredComment, Dead: true}}
+	if fmt.Sprint(resp.Post.Comments[1].Quotes) != fmt.Sprint(want) {
+		t.Errorf("comment quotes = %+v, want %+v", resp.Post.Comments[1].Quotes, want)
+	}
+}
@@ -0,0 +1,184 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseRate(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want utils.Rate
+		str  string
+	}{
+		{"", utils.Rate{}, "0"},
+		{"0", utils.Rate{}, "0"},
+		{"5/10m", utils.Rate{Burst: 5, Per: 10 * time.Minute}, "5/10m"},
+		{"20/1h", utils.Rate{Burst: 20, Per: time.Hour}, "20/1h"},
+		{"3/90s", utils.Rate{Burst: 3, Per: 90 * time.Second}, "3/1m30s"},
+	} {
+		got, err := utils.ParseRate(tc.in)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseRate(%q) = %+v, %v; want %+v", tc.in, got, err, tc.want)
+		}
+		if got.String() != tc.str {
+			t.Errorf("ParseRate(%q).String() = %q, want %q", tc.in, got.String(), tc.str)
+		}
+	}
+	for _, in := range []string{"5", "x/1m", "-1/1m", "5/0s", "5/abc"} {
+		if _, err := utils.ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q) should fail", in)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := utils.NewRateLimiter(100)
+	rate := utils.Rate{Burst: 2, Per: time.Minute}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a", rate, now); !ok {
+			t.Fatalf("request %d within burst rejected", i)
+		}
+	}
+	ok, wait := l.Allow("a", rate, now)
+	if ok || wait != 30*time.Second {
+		t.Fatalf("third request = %v, wait %v; want rejected with 30s wait", ok, wait)
+	}
+	// 其他键不受影响
+	if ok, _ := l.Allow("b", rate, now); !ok {
+		t.Fatal("other key rejected")
+	}
+	// 每30秒恢复一个令牌
+	if ok, _ := l.Allow("a", rate, now.Add(30*time.Second)); !ok {
+		t.Fatal("request after refill rejected")
+	}
+	if ok, _ := l.Allow("a", rate, now.Add(30*time.Second)); ok {
+		t.Fatal("refill exceeded one token")
+	}
+	// 不限速
+	for i := 0; i < 10; i++ {
+		if ok, _ := l.Allow("c", utils.Rate{}, now); !ok {
+			t.Fatal("unlimited rate rejected")
+		}
+	}
+
+	// 空闲超过恢复时间的桶被回收
+	if n := l.Len(); n != 2 {
+		t.Fatalf("Len = %d, want 2", n)
+	}
+	l.Allow("d", rate, now.Add(5*time.Minute))
+	if n := l.Len(); n != 1 {
+		t.Fatalf("Len after idle = %d, want 1", n)
+	}
+
+	// 超过数量上限时回收最久未使用的桶
+	l = utils.NewRateLimiter(3)
+	for i := 0; i < 10; i++ {
+		l.Allow(fmt.Sprint(i), rate, now)
+	}
+	if n := l.Len(); n != 3 {
+		t.Fatalf("Len = %d, want 3", n)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.CurrentConfig()
+	cfg.RateLimitPosts = utils.Rate{Burst: 1, Per: time.Hour}
+	utils.SetConfig(cfg)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	limiter := utils.NewRateLimiter(100)
+	r.POST("/api/posts", handlers.RateLimit(limiter, "posts", func(c utils.AppConfig) utils.Rate { return c.RateLimitPosts }),
+		func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	do := func(remote, forwarded string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/posts", nil)
+		req.RemoteAddr = remote + ":1234"
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("10.0.0.1", "203.0.113.1"); w.Code != http.StatusCreated {
+		t.Fatalf("first request = %d", w.Code)
+	}
+	w := do("10.0.0.1", "203.0.113.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "3600" {
+		t.Fatalf("Retry-After = %q, want 3600", got)
+	}
+	// 经过信任的代理时按真实客户端限速
+	if w := do("10.0.0.1", "203.0.113.2"); w.Code != http.StatusCreated {
+		t.Fatalf("other client behind proxy = %d", w.Code)
+	}
+	// 不信任的来源伪造 X-Forwarded-For 无效
+	if w := do("198.51.100.1", "203.0.113.3"); w.Code != http.StatusCreated {
+		t.Fatalf("untrusted first request = %d", w.Code)
+	}
+	if w := do("198.51.100.1", "203.0.113.4"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For = %d, want 429", w.Code)
+	}
+	// 同一 IPv6 /64 网段共用限速
+	if w := do("[2001:db8::1]", ""); w.Code != http.StatusCreated {
+		t.Fatalf("ipv6 first request = %d", w.Code)
+	}
+	if w := do("[2001:db8::2]", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("same /64 = %d, want 429", w.Code)
+	}
+
+	// 重新配置为不限速后立即生效
+	cfg.RateLimitPosts = utils.Rate{}
+	utils.SetConfig(cfg)
+	if w := do("10.0.0.1", "203.0.113.1"); w.Code != http.StatusCreated {
+		t.Fatalf("unlimited = %d", w.Code)
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	loader, _, err := utils.ParseConfigFlags([]string{"-rate-limit-posts", "2/1m", "-trusted-proxies", "10.0.0.1, 192.168.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimitPosts != (utils.Rate{Burst: 2, Per: time.Minute}) {
+		t.Fatalf("RateLimitPosts = %+v", cfg.RateLimitPosts)
+	}
+	if len(cfg.TrustedProxies) != 2 || cfg.TrustedProxies[1] != "192.168.0.0/16" {
+		t.Fatalf("TrustedProxies = %q", cfg.TrustedProxies)
+	}
+
+	for _, args := range [][]string{
+		{"-rate-limit-comments", "many"},
+		{"-trusted-proxies", "10.0.0.1,not-an-ip"},
+	} {
+		loader, _, err := utils.ParseConfigFlags(args)
+		if err == nil {
+			_, _, err = loader.Load()
+		}
+		if err == nil {
+			t.Errorf("%v should fail", args)
+		}
+	}
+}
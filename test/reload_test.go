@@ -0,0 +1,134 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/Mammoth777/nilbbs/nickname"
+	"github.com/Mammoth777/nilbbs/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// 测试修改了全局配置，结束后恢复默认值
+func restoreDefaultConfig(t *testing.T) {
+	t.Cleanup(func() {
+		if err := utils.SetConfig(utils.DefaultConfig()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestReloadConfig(t *testing.T) {
+	restoreDefaultConfig(t)
+	path := writeConfigFile(t, "nilbbs.toml", "inactive_days_before_delete = 10\nport = 9000\n")
+	loader, _, err := utils.ParseConfigFlags([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新加载期间并发读取配置
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = utils.CurrentConfig().InactiveDaysBeforeDelete
+				_ = utils.NowDisplay()
+				_ = nickname.GetRandomNickname()
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	if err := os.WriteFile(path, []byte("inactive_days_before_delete = 3\nport = 9001\ntimezone = \"UTC\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	next, _, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := utils.ReloadConfig(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := utils.CurrentConfig()
+	if got.InactiveDaysBeforeDelete != 3 || got.Timezone != "UTC" || utils.DisplayZone().String() != "UTC" {
+		t.Fatalf("可以在运行中修改的配置应生效: %+v", got)
+	}
+	if got.ServerPort != "9000" {
+		t.Fatalf("端口需要重启才能生效，应保持原值: %s", got.ServerPort)
+	}
+	if len(result.RestartRequired) != 1 || result.RestartRequired[0] != "port" {
+		t.Fatalf("应报告需要重启的配置项: %+v", result)
+	}
+	if len(result.Applied) != 2 {
+		t.Fatalf("应报告已生效的配置项: %+v", result)
+	}
+
+	// 新配置无效时保持当前配置
+	if err := os.WriteFile(path, []byte("inactive_days_before_delete = -1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loader.Load(); err == nil {
+		t.Fatal("无效的配置应返回错误")
+	}
+	if utils.CurrentConfig().InactiveDaysBeforeDelete != 3 {
+		t.Fatal("重新加载失败时应保持当前配置")
+	}
+}
+
+func TestAdminReloadEndpoint(t *testing.T) {
+	restoreDefaultConfig(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	calls := 0
+	r.POST("/api/admin/reload", handlers.RequireAdmin(handlers.NewAdminSessions()), handlers.ReloadConfig(func() (utils.ReloadResult, error) {
+		calls++
+		return utils.ReloadResult{Applied: []string{"inactive_days_before_delete"}}, nil
+	}))
+
+	request := func(auth string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/reload", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request("Bearer anything"); code != http.StatusNotFound {
+		t.Fatalf("未配置令牌时管理接口应不可用: %d", code)
+	}
+
+	cfg := utils.DefaultConfig()
+	cfg.AdminToken = "s3cret"
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if code := request("Bearer wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("错误的令牌应被拒绝: %d", code)
+	}
+	if code := request("Bearer s3cret"); code != http.StatusOK || calls != 1 {
+		t.Fatalf("正确的令牌应触发重新加载: %d, %d", code, calls)
+	}
+}
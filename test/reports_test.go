@@ -0,0 +1,72 @@
+package test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+func TestReports(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.DefaultConfig()
+	cfg.AdminToken = "s3cret"
+	cfg.ReportHideThreshold = 3
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r := newAdminTestRouter(database.NewMemoryStore())
+	admin := map[string]string{"Authorization": "Bearer s3cret"}
+	from := func(ip string) map[string]string { return map[string]string{"X-Forwarded-For": ip} }
+
+	var created struct {
+		PostID int64 `json:"post_id"`
+	}
+	doJSON(t, r, "POST", "/api/posts", `{"content":"buy now"}`, &created)
+	path := "/api/posts/" + strconv.FormatInt(created.PostID, 10)
+
+	if code := doJSONWithHeader(t, r, "POST", path+"/report", `{"reason":"boring"}`, from("192.0.2.1"), nil); code != http.StatusBadRequest {
+		t.Errorf("invalid reason: status %d, want 400", code)
+	}
+	if code := doJSONWithHeader(t, r, "POST", path+"/report", `{"reason":"spam"}`, from("192.0.2.1"), nil); code != http.StatusCreated {
+		t.Fatalf("report: status %d", code)
+	}
+	if code := doJSONWithHeader(t, r, "POST", path+"/report", `{"reason":"abuse"}`, from("192.0.2.1"), nil); code != http.StatusConflict {
+		t.Errorf("duplicate report: status %d, want 409", code)
+	}
+	// 同一 /64 网段的 IPv6 客户端视为同一举报者
+	doJSONWithHeader(t, r, "POST", path+"/report", `{"reason":"spam"}`, from("2001:db8::1"), nil)
+	if code := doJSONWithHeader(t, r, "POST", path+"/report", `{"reason":"spam"}`, from("2001:db8::2"), nil); code != http.StatusConflict {
+		t.Errorf("report from same /64: status %d, want 409", code)
+	}
+
+	// 第三个客户端举报后达到阈值，帖子被自动隐藏但仍在队列中
+	if code := doJSONWithHeader(t, r, "POST", path+"/report", `{"reason":"abuse"}`, from("198.51.100.1"), nil); code != http.StatusCreated {
+		t.Fatalf("report: status %d", code)
+	}
+	if code := doJSON(t, r, "GET", path, "", nil); code != http.StatusNotFound {
+		t.Errorf("auto-hidden post: status %d, want 404", code)
+	}
+	var queue struct {
+		Reports []models.ReportSummary `json:"reports"`
+	}
+	if code := doJSON(t, r, "GET", "/api/admin/reports", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("queue without admin: status %d, want 401", code)
+	}
+	doJSONWithHeader(t, r, "GET", "/api/admin/reports", "", admin, &queue)
+	if len(queue.Reports) != 1 || queue.Reports[0].Count != 3 || queue.Reports[0].Reasons[models.ReportSpam] != 2 || !queue.Reports[0].Hidden || queue.Reports[0].Content != "buy now" {
+		t.Fatalf("queue = %+v", queue.Reports)
+	}
+
+	// 驳回举报后队列为空
+	if code := doJSONWithHeader(t, r, "DELETE", "/api/admin/posts/"+strconv.FormatInt(created.PostID, 10)+"/reports", "", admin, nil); code != http.StatusOK {
+		t.Fatalf("dismiss: status %d", code)
+	}
+	doJSONWithHeader(t, r, "GET", "/api/admin/reports", "", admin, &queue)
+	if len(queue.Reports) != 0 {
+		t.Errorf("queue after dismissing = %+v", queue.Reports)
+	}
+}
@@ -0,0 +1,183 @@
+package test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+func TestPremoderation(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.DefaultConfig()
+	cfg.AdminToken = "s3cret"
+	cfg.Premoderation = utils.PremoderationComments
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r := newAdminTestRouter(database.NewMemoryStore())
+	admin := map[string]string{"Authorization": "Bearer s3cret"}
+
+	type created struct {
+		Message   string `json:"message"`
+		PostID    int64  `json:"post_id"`
+		CommentID int64  `json:"comment_id"`
+		EditToken string `json:"edit_token"`
+	}
+	var got struct {
+		Post models.Post `json:"post"`
+	}
+
+	// 只审核评论时帖子直接公开
+	var post created
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"hello"}`, &post); code != http.StatusCreated {
+		t.Fatalf("create post: status %d", code)
+	}
+	path := "/api/posts/" + strconv.FormatInt(post.PostID, 10)
+
+	var comment created
+	if code := doJSON(t, r, "POST", path+"/comments", `{"content":"first"}`, &comment); code != http.StatusAccepted || comment.EditToken == "" {
+		t.Fatalf("pending comment: status %d %+v", code, comment)
+	}
+	commentPath := path + "/comments/" + strconv.FormatInt(comment.CommentID, 10)
+	doJSON(t, r, "GET", path, "", &got)
+	if len(got.Post.Comments) != 0 {
+		t.Errorf("pending comment listed: %+v", got.Post.Comments)
+	}
+	if code := doJSON(t, r, "POST", path+"/comments", `{"content":"reply","parent_comment_id":`+strconv.FormatInt(comment.CommentID, 10)+`}`, nil); code != http.StatusBadRequest {
+		t.Errorf("reply to pending comment: status %d, want 400", code)
+	}
+
+	// 作者可以用编辑令牌查看自己等待审核的评论
+	var own struct {
+		Comment models.Comment `json:"comment"`
+	}
+	token := map[string]string{"X-Edit-Token": comment.EditToken}
+	if code := doJSONWithHeader(t, r, "GET", commentPath, "", token, &own); code != http.StatusOK || own.Comment.Status != models.StatusPending || own.Comment.ContentHTML == "" {
+		t.Errorf("own comment: status %d %+v", code, own.Comment)
+	}
+	if code := doJSONWithHeader(t, r, "GET", commentPath, "", map[string]string{"X-Edit-Token": post.EditToken}, nil); code != http.StatusNotFound {
+		t.Errorf("comment with wrong token: status %d, want 404", code)
+	}
+
+	// 审核队列按提交顺序列出，批量审核时逐项报告结果
+	var second created
+	doJSON(t, r, "POST", path+"/comments", `{"content":"second"}`, &second)
+	var queue struct {
+		Items []models.PendingItem `json:"items"`
+	}
+	if code := doJSONWithHeader(t, r, "GET", "/api/admin/queue", "", admin, &queue); code != http.StatusOK || len(queue.Items) != 2 || queue.Items[0].CommentID != comment.CommentID {
+		t.Fatalf("queue: status %d %+v", code, queue.Items)
+	}
+
+	var review struct {
+		Done   int `json:"done"`
+		Failed []struct {
+			PostID    int64  `json:"post_id"`
+			CommentID int64  `json:"comment_id"`
+			Error     string `json:"error"`
+		} `json:"failed"`
+	}
+	items := func(ids ...int64) string {
+		body := `{"items":[`
+		for i, id := range ids {
+			if i > 0 {
+				body += ","
+			}
+			body += `{"post_id":` + strconv.FormatInt(post.PostID, 10) + `,"comment_id":` + strconv.FormatInt(id, 10) + `}`
+		}
+		return body + `],"reason":"ok"}`
+	}
+	if code := doJSON(t, r, "POST", "/api/admin/queue/approve", items(comment.CommentID), nil); code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated review: status %d, want 401", code)
+	}
+	if code := doJSONWithHeader(t, r, "POST", "/api/admin/queue/approve", items(comment.CommentID, comment.CommentID+100), admin, &review); code != http.StatusOK {
+		t.Fatalf("approve: status %d", code)
+	}
+	if review.Done != 1 || len(review.Failed) != 1 || review.Failed[0].CommentID != comment.CommentID+100 {
+		t.Errorf("approve result = %+v", review)
+	}
+	doJSONWithHeader(t, r, "POST", "/api/admin/queue/reject", items(comment.CommentID, second.CommentID), admin, &review)
+	if review.Done != 1 || len(review.Failed) != 1 || review.Failed[0].CommentID != comment.CommentID {
+		t.Errorf("reject result = %+v", review)
+	}
+
+	doJSON(t, r, "GET", path, "", &got)
+	if len(got.Post.Comments) != 1 || got.Post.Comments[0].ID != comment.CommentID || got.Post.Comments[0].Status != models.StatusApproved {
+		t.Errorf("comments after review: %+v", got.Post.Comments)
+	}
+	if code := doJSONWithHeader(t, r, "GET", "/api/admin/queue", "", admin, &queue); code != http.StatusOK || len(queue.Items) != 0 {
+		t.Errorf("queue after review: %+v", queue.Items)
+	}
+
+	// 修改后的内容重新进入审核队列
+	if code := doJSONWithHeader(t, r, "PUT", commentPath, `{"content":"first, edited"}`, token, nil); code != http.StatusAccepted {
+		t.Errorf("edit in premoderation: status %d, want 202", code)
+	}
+	got.Post = models.Post{}
+	doJSON(t, r, "GET", path, "", &got)
+	if len(got.Post.Comments) != 0 {
+		t.Errorf("edited comment still listed: %+v", got.Post.Comments)
+	}
+
+	// 审核帖子时作者可以用编辑令牌查看自己的帖子
+	cfg.Premoderation = utils.PremoderationAll
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	var held created
+	if code := doJSON(t, r, "POST", "/api/posts", `{"content":"needs review"}`, &held); code != http.StatusAccepted {
+		t.Fatalf("pending post: status %d", code)
+	}
+	heldPath := "/api/posts/" + strconv.FormatInt(held.PostID, 10)
+	adminPath := "/api/admin/posts/" + strconv.FormatInt(held.PostID, 10)
+	if code := doJSON(t, r, "GET", heldPath, "", nil); code != http.StatusNotFound {
+		t.Errorf("pending post visible: status %d", code)
+	}
+	if code := doJSONWithHeader(t, r, "GET", heldPath, "", map[string]string{"X-Edit-Token": post.EditToken}, nil); code != http.StatusNotFound {
+		t.Errorf("pending post with wrong token: status %d, want 404", code)
+	}
+	if code := doJSONWithHeader(t, r, "GET", heldPath, "", map[string]string{"X-Edit-Token": held.EditToken}, &got); code != http.StatusOK || got.Post.Status != models.StatusPending {
+		t.Errorf("own pending post: status %d %+v", code, got.Post)
+	}
+	var list struct {
+		Posts []models.Post `json:"posts"`
+	}
+	doJSON(t, r, "GET", "/api/posts", "", &list)
+	if len(list.Posts) != 1 {
+		t.Errorf("pending post listed: %+v", list.Posts)
+	}
+	if code := doJSONWithHeader(t, r, "POST", adminPath+"/approve", "", admin, nil); code != http.StatusOK {
+		t.Fatalf("approve post: status %d", code)
+	}
+	if code := doJSONWithHeader(t, r, "POST", adminPath+"/approve", "", admin, nil); code != http.StatusConflict {
+		t.Errorf("approve approved post: status %d, want 409", code)
+	}
+	if code := doJSON(t, r, "GET", heldPath, "", nil); code != http.StatusOK {
+		t.Errorf("approved post: status %d", code)
+	}
+
+	// 审核操作和自动放回队列都记录在审计日志中
+	var audit struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}
+	doJSONWithHeader(t, r, "GET", "/api/admin/audit", "", admin, &audit)
+	want := []string{models.ActionApprovePost, models.ActionHoldComment, models.ActionRejectComment, models.ActionApproveComment}
+	if len(audit.Entries) != len(want) {
+		t.Fatalf("audit log = %+v", audit.Entries)
+	}
+	for i, action := range want {
+		if audit.Entries[i].Action != action {
+			t.Errorf("audit entry %d = %+v, want %s", i, audit.Entries[i], action)
+		}
+	}
+	if e := audit.Entries[1]; e.Actor != models.ActorAuto || e.IP != "" {
+		t.Errorf("hold entry = %+v", e)
+	}
+	if e := audit.Entries[3]; e.Actor != "token" || e.Reason != "ok" {
+		t.Errorf("approve entry = %+v", e)
+	}
+}
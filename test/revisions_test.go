@@ -0,0 +1,95 @@
+package test
+
+import (
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/handlers"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// 帖子过期删除时修改历史也要一并删除
+func TestRevisionsPurgedWithPost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nilbbs.db")
+	s, err := database.NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	now := utils.NowUTC().Truncate(time.Second)
+	hash := utils.HashEditToken("token")
+	postID, _ := s.CreatePost(&models.Post{Content: "v1", Author: "a", CreatedAt: now, DeleteAt: now.Add(time.Hour), EditTokenHash: hash}, nil, nil)
+	commentID, _ := s.CreateComment(&models.Comment{Content: "c1", PostID: postID, Author: "b", CreatedAt: now, EditTokenHash: hash}, nil)
+	if err := s.UpdatePost(postID, "v2", hash, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateComment(postID, commentID, "c2", hash, nil, now); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	countRevisions := func() int {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM revisions").Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := countRevisions(); n != 2 {
+		t.Fatalf("revisions = %d, want 2", n)
+	}
+
+	if _, err := s.DeleteOldPosts(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRevisions(); n != 0 {
+		t.Errorf("revisions of expired post remain: %d", n)
+	}
+}
+
+func TestRevisionsHandler(t *testing.T) {
+	r := newTestRouter(database.NewMemoryStore())
+
+	var post struct {
+		PostID    int64  `json:"post_id"`
+		EditToken string `json:"edit_token"`
+	}
+	doJSON(t, r, "POST", "/api/posts", `{"content":"first"}`, &post)
+	path := "/api/posts/" + strconv.FormatInt(post.PostID, 10)
+	header := map[string]string{handlers.EditTokenHeader: post.EditToken}
+	if code := doJSONWithHeader(t, r, "PUT", path, `{"content":"second"}`, header, nil); code != http.StatusOK {
+		t.Fatalf("edit: status %d", code)
+	}
+
+	var detail struct {
+		Post models.Post `json:"post"`
+	}
+	doJSON(t, r, "GET", path+"?tz=UTC", "", &detail)
+	if !detail.Post.Edited || detail.Post.EditedAt == nil || detail.Post.Content != "second" {
+		t.Errorf("post not marked as edited: %+v", detail.Post)
+	}
+
+	var history struct {
+		Revisions []models.Revision `json:"revisions"`
+	}
+	if code := doJSON(t, r, "GET", path+"/revisions?tz=UTC", "", &history); code != http.StatusOK {
+		t.Fatalf("revisions: status %d", code)
+	}
+	if len(history.Revisions) != 1 || history.Revisions[0].Content != "first" {
+		t.Errorf("revisions = %+v", history.Revisions)
+	}
+	if code := doJSON(t, r, "GET", "/api/posts/999/revisions", "", nil); code != http.StatusNotFound {
+		t.Errorf("missing post: status %d, want 404", code)
+	}
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+// 全文索引需要以 sqlite_fts5 构建标签运行：go test -tags sqlite_fts5 ./...
+func TestSQLiteSearchIndexCleanup(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "nilbbs.db")
+	s, err := database.NewSQLiteStore(dsn)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	defer s.Close()
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+	var enabled bool
+	db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	if !enabled {
+		t.Skip("未启用FTS5，跳过全文索引测试")
+	}
+
+	base := utils.NowUTC().Truncate(time.Second)
+	id, _ := s.CreatePost(&models.Post{Content: "即将过期的帖子", Author: "a", CreatedAt: base.AddDate(0, 0, -8), DeleteAt: base.Add(-time.Hour)}, nil, nil)
+	s.CreateComment(&models.Comment{Content: "一条评论", PostID: id, Author: "b", CreatedAt: base.AddDate(0, 0, -8)}, nil)
+
+	var indexed int
+	db.QueryRow("SELECT COUNT(*) FROM search_index").Scan(&indexed)
+	if indexed != 2 {
+		t.Fatalf("indexed rows = %d, want 2", indexed)
+	}
+	if _, err := s.DeleteOldPosts(base); err != nil {
+		t.Fatalf("DeleteOldPosts: %v", err)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM search_index").Scan(&indexed)
+	if indexed != 0 {
+		t.Errorf("indexed rows after cleanup = %d, want 0", indexed)
+	}
+}
+
+func TestSearchHandler(t *testing.T) {
+	r := newTestRouter(database.NewMemoryStore())
+	doJSON(t, r, "POST", "/api/posts", `{"content":"<b>Hello</b> world"}`, nil)
+
+	var resp struct {
+		Results []struct {
+			Type    string `json:"type"`
+			Snippet string `json:"snippet"`
+		} `json:"results"`
+	}
+	if code := doJSON(t, r, "GET", "/api/search?q="+url.QueryEscape("hello"), "", &resp); code != http.StatusOK {
+		t.Fatalf("search: status %d", code)
+	}
+	want := "&lt;b&gt;<mark>Hello</mark>&lt;/b&gt; world"
+	if len(resp.Results) != 1 || resp.Results[0].Snippet != want || resp.Results[0].Type != "post" {
+		t.Errorf("results = %+v, want snippet %q", resp.Results, want)
+	}
+
+	if code := doJSON(t, r, "GET", "/api/search?q=+", "", nil); code != http.StatusBadRequest {
+		t.Errorf("empty query: status %d, want 400", code)
+	}
+}
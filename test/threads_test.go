@@ -0,0 +1,85 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Mammoth777/nilbbs/database"
+	"github.com/Mammoth777/nilbbs/models"
+	"github.com/Mammoth777/nilbbs/utils"
+)
+
+func TestCommentThreads(t *testing.T) {
+	restoreDefaultConfig(t)
+	cfg := utils.DefaultConfig()
+	cfg.MaxCommentDepth = 2
+	if err := utils.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter(database.NewMemoryStore())
+
+	newPost := func() string {
+		var created struct {
+			PostID int64 `json:"post_id"`
+		}
+		if code := doJSON(t, r, "POST", "/api/posts", `{"content":"post"}`, &created); code != http.StatusCreated {
+			t.Fatalf("create post: status %d", code)
+		}
+		return "/api/posts/" + strconv.FormatInt(created.PostID, 10)
+	}
+	path, otherPath := newPost(), newPost()
+
+	comment := func(path string, parent int64, want int) int64 {
+		t.Helper()
+		var created struct {
+			CommentID int64 `json:"comment_id"`
+		}
+		body := fmt.Sprintf(`{"content":"c","parent_comment_id":%d,"depth":9}`, parent)
+		if code := doJSON(t, r, "POST", path+"/comments", body, &created); code != want {
+			t.Fatalf("add comment with parent %d: status %d, want %d", parent, code, want)
+		}
+		return created.CommentID
+	}
+	root := comment(path, 0, http.StatusCreated)
+	reply := comment(path, root, http.StatusCreated)
+	nested := comment(path, reply, http.StatusCreated)
+	// 超过最大层级的回复挂到第2层的评论下
+	clamped := comment(path, nested, http.StatusCreated)
+	second := comment(path, 0, http.StatusCreated)
+	comment(otherPath, root, http.StatusBadRequest)
+	comment(path, 999, http.StatusBadRequest)
+
+	var resp struct {
+		Post       models.Post `json:"post"`
+		NextCursor string      `json:"next_cursor"`
+	}
+	if code := doJSON(t, r, "GET", path+"?limit=1", "", &resp); code != http.StatusOK {
+		t.Fatalf("get post: status %d", code)
+	}
+	var got []string
+	for _, c := range resp.Post.Comments {
+		got = append(got, fmt.Sprintf("%d@%d<%d", c.ID, c.Depth, c.ParentCommentID))
+	}
+	want := []string{
+		fmt.Sprintf("%d@0<0", root),
+		fmt.Sprintf("%d@1<%d", reply, root),
+		fmt.Sprintf("%d@2<%d", nested, reply),
+		fmt.Sprintf("%d@2<%d", clamped, reply),
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("first page = %v, want %v", got, want)
+	}
+	if resp.NextCursor == "" {
+		t.Fatal("expected next cursor after first thread")
+	}
+
+	resp.Post.Comments = nil
+	if code := doJSON(t, r, "GET", path+"?limit=1&cursor="+resp.NextCursor, "", &resp); code != http.StatusOK {
+		t.Fatalf("get second page: status %d", code)
+	}
+	if len(resp.Post.Comments) != 1 || resp.Post.Comments[0].ID != second || resp.NextCursor != "" {
+		t.Errorf("second page = %+v, next %q", resp.Post.Comments, resp.NextCursor)
+	}
+}
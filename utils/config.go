@@ -1,9 +1,17 @@
 package utils
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // AppConfig 存储应用程序的配置信息
@@ -12,54 +20,582 @@ type AppConfig struct {
 	InactiveDaysBeforeDelete int
 	// 服务器监听端口
 	ServerPort string
+	// 数据库DSN，postgres:// 开头时使用PostgreSQL，否则视为SQLite文件路径，为空使用默认路径
+	DatabaseDSN string
+	// 默认显示时区（IANA名称），客户端可以按请求覆盖
+	Timezone string
+	// 关闭服务器时等待处理中的请求完成的最长时间
+	ShutdownTimeout time.Duration
+	// 覆盖目录，其中的 templates/、static/、assets/dataset/ 文件优先于内嵌文件，为空不覆盖
+	OverrideDir string
+	// 管理接口使用的令牌，与管理密码都为空时禁用管理接口
+	AdminToken string
+	// 管理密码的 bcrypt 哈希，用于管理员登录，可以用 nilbbs hash-password 生成
+	AdminPasswordHash string
+	// 评论回复的最大嵌套层级，超过时回复挂到允许的最深一层
+	MaxCommentDepth int
+	// 附件存储，s3:// 开头时使用S3兼容的对象存储，否则视为本地目录，为空使用默认目录
+	AttachmentStore string
+	// 单个附件的最大字节数
+	MaxUploadSize int64
+	// 每个帖子或评论最多的附件数量，0 表示不允许上传附件
+	MaxAttachments int
+	// 信任的反向代理（IP或CIDR），只有来自它们的请求才使用 X-Forwarded-For 中的客户端IP
+	TrustedProxies []string
+	// 每个客户端IP发帖、评论、上传附件和举报的限速
+	RateLimitPosts    Rate
+	RateLimitComments Rate
+	RateLimitUploads  Rate
+	RateLimitReports  Rate
+	// 帖子或评论被多少个客户端举报后自动隐藏，0 表示不自动隐藏
+	ReportHideThreshold int
+	// 内容过滤规则文件（TOML或YAML），为空时不过滤
+	FilterRules string
+	// 先审后发的范围：off、posts、comments 或 all，范围内新发布和修改的内容需要管理员审核后才公开
+	Premoderation string
+	// 计算发帖人ID和举报人标识的密钥，为空时每次启动随机生成
+	PosterIDKey string
+	// 发帖和评论的工作量证明基础难度（前导零位数），0 表示关闭
+	PowDifficulty int
+	// 发帖频繁时工作量证明难度的上限
+	PowMaxDifficulty int
 }
 
 // 环境变量名常量
 const (
+	// 配置文件路径的环境变量名
+	EnvConfigFile = "NILBBS_CONFIG"
 	// 不活跃帖子删除天数的环境变量名
 	EnvInactiveDaysBeforeDelete = "NILBBS_INACTIVE_DAYS_BEFORE_DELETE"
 	// 服务器端口的环境变量名
 	EnvServerPort = "NILBBS_PORT"
+	// 数据库DSN的环境变量名
+	EnvDatabaseDSN = "NILBBS_DATABASE_DSN"
+	// 显示时区的环境变量名
+	EnvTimezone = "NILBBS_TIMEZONE"
+	// 关闭超时时间的环境变量名，格式如 30s、1m
+	EnvShutdownTimeout = "NILBBS_SHUTDOWN_TIMEOUT"
+	// 覆盖目录的环境变量名
+	EnvOverrideDir = "NILBBS_OVERRIDE_DIR"
+	// 管理令牌的环境变量名
+	EnvAdminToken = "NILBBS_ADMIN_TOKEN"
+	// 管理密码哈希的环境变量名
+	EnvAdminPasswordHash = "NILBBS_ADMIN_PASSWORD_HASH"
+	// 评论最大嵌套层级的环境变量名
+	EnvMaxCommentDepth = "NILBBS_MAX_COMMENT_DEPTH"
+	// 附件存储的环境变量名
+	EnvAttachmentStore = "NILBBS_ATTACHMENT_STORE"
+	// 附件最大字节数的环境变量名
+	EnvMaxUploadSize = "NILBBS_MAX_UPLOAD_SIZE"
+	// 每个帖子或评论最多附件数量的环境变量名
+	EnvMaxAttachments = "NILBBS_MAX_ATTACHMENTS"
+	// 信任的反向代理的环境变量名，多个用逗号分隔
+	EnvTrustedProxies = "NILBBS_TRUSTED_PROXIES"
+	// 发帖、评论、上传附件和举报限速的环境变量名
+	EnvRateLimitPosts    = "NILBBS_RATE_LIMIT_POSTS"
+	EnvRateLimitComments = "NILBBS_RATE_LIMIT_COMMENTS"
+	EnvRateLimitUploads  = "NILBBS_RATE_LIMIT_UPLOADS"
+	EnvRateLimitReports  = "NILBBS_RATE_LIMIT_REPORTS"
+	// 举报自动隐藏阈值的环境变量名
+	EnvReportHideThreshold = "NILBBS_REPORT_HIDE_THRESHOLD"
+	// 内容过滤规则文件的环境变量名
+	EnvFilterRules = "NILBBS_FILTER_RULES"
+	// 先审后发范围的环境变量名
+	EnvPremoderation = "NILBBS_PREMODERATION"
+	// 发帖人ID密钥的环境变量名
+	EnvPosterIDKey = "NILBBS_POSTER_ID_KEY"
+	// 工作量证明基础难度和难度上限的环境变量名
+	EnvPowDifficulty    = "NILBBS_POW_DIFFICULTY"
+	EnvPowMaxDifficulty = "NILBBS_POW_MAX_DIFFICULTY"
+)
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() AppConfig {
+	return AppConfig{
+		// 默认值：7天不活跃的帖子将被删除
+		InactiveDaysBeforeDelete: 7,
+		// 默认端口：8080
+		ServerPort: "8080",
+		// 默认时区：Asia/Shanghai
+		Timezone: DefaultTimezone,
+		// 默认关闭超时：15秒
+		ShutdownTimeout: 15 * time.Second,
+		// 默认最多嵌套5层回复
+		MaxCommentDepth: 5,
+		// 默认单个附件最大5MB
+		MaxUploadSize: 5 << 20,
+		// 默认每个帖子或评论最多4个附件
+		MaxAttachments: 4,
+		// 默认每个IP每10分钟最多发5个帖子、20条评论，每小时最多上传20个附件、举报10次
+		RateLimitPosts:    Rate{Burst: 5, Per: 10 * time.Minute},
+		RateLimitComments: Rate{Burst: 20, Per: 10 * time.Minute},
+		RateLimitUploads:  Rate{Burst: 20, Per: time.Hour},
+		RateLimitReports:  Rate{Burst: 10, Per: time.Hour},
+		// 默认被5个客户端举报后自动隐藏
+		ReportHideThreshold: 5,
+		// 默认不需要审核
+		Premoderation: PremoderationOff,
+		// 默认难度16，浏览器中约需计算6万多次哈希，发帖频繁时最多提高到20
+		PowDifficulty:    16,
+		PowMaxDifficulty: 20,
+	}
+}
+
+// 先审后发的范围
+const (
+	PremoderationOff      = "off"
+	PremoderationPosts    = "posts"
+	PremoderationComments = "comments"
+	PremoderationAll      = "all"
 )
 
-// Config 是应用程序配置的全局实例
-var Config = AppConfig{
-	// 默认值：7天不活跃的帖子将被删除
-	InactiveDaysBeforeDelete: 7,
-	// 默认端口：8080
-	ServerPort: "8080",
-}
-
-// LoadConfigFromEnv 从环境变量加载配置
-func LoadConfigFromEnv() {
-	// 加载不活跃帖子删除天数
-	if daysStr := os.Getenv(EnvInactiveDaysBeforeDelete); daysStr != "" {
-		if days, err := strconv.Atoi(daysStr); err == nil && days > 0 {
-			Config.InactiveDaysBeforeDelete = days
-			log.Printf("从环境变量加载配置：%s = %d", EnvInactiveDaysBeforeDelete, days)
-		} else if err != nil {
-			log.Printf("环境变量 %s 的值 '%s' 无效，使用默认值 %d", 
-				EnvInactiveDaysBeforeDelete, daysStr, Config.InactiveDaysBeforeDelete)
+// PremoderatePosts 判断新帖子是否需要审核
+func (c AppConfig) PremoderatePosts() bool {
+	return c.Premoderation == PremoderationPosts || c.Premoderation == PremoderationAll
+}
+
+// PremoderateComments 判断新评论是否需要审核
+func (c AppConfig) PremoderateComments() bool {
+	return c.Premoderation == PremoderationComments || c.Premoderation == PremoderationAll
+}
+
+// TrustsProxy 判断IP是否属于信任的反向代理，只有来自这些地址的转发请求头才可信
+func (c AppConfig) TrustsProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range c.TrustedProxies {
+		if strings.Contains(p, "/") {
+			if prefix, err := netip.ParsePrefix(p); err == nil && prefix.Contains(addr) {
+				return true
+			}
+		} else if proxy, err := netip.ParseAddr(p); err == nil && proxy.Unmap() == addr {
+			return true
 		}
 	}
-	
-	// 加载服务器端口配置
-	if portStr := os.Getenv(EnvServerPort); portStr != "" {
-		// 验证端口是有效的数字
-		if _, err := strconv.Atoi(portStr); err == nil {
-			Config.ServerPort = portStr
-			log.Printf("从环境变量加载配置：%s = %s", EnvServerPort, portStr)
-		} else {
-			log.Printf("环境变量 %s 的值 '%s' 无效，使用默认端口 %s", 
-				EnvServerPort, portStr, Config.ServerPort)
+	return false
+}
+
+// config 当前生效的配置，整体替换以保证并发读取时看到一致的配置
+var config atomic.Pointer[AppConfig]
+
+// configMu 串行化对配置的修改，读取不需要加锁
+var configMu sync.Mutex
+
+func init() {
+	cfg := DefaultConfig()
+	config.Store(&cfg)
+}
+
+// CurrentConfig 返回当前生效配置的副本，可以在任意goroutine中调用
+func CurrentConfig() AppConfig {
+	return *config.Load()
+}
+
+// SetConfig 替换全局配置并应用显示时区，cfg 应已经过校验
+func SetConfig(cfg AppConfig) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	return setConfigLocked(cfg)
+}
+
+func setConfigLocked(cfg AppConfig) error {
+	if err := SetDisplayZone(cfg.Timezone); err != nil {
+		return err
+	}
+	config.Store(&cfg)
+	return nil
+}
+
+// updateConfig 在当前配置的副本上修改后整体替换
+func updateConfig(fn func(c *AppConfig)) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	cfg := CurrentConfig()
+	fn(&cfg)
+	config.Store(&cfg)
+}
+
+// setting 描述一个配置项：配置文件中的键名、对应的环境变量以及如何解析和显示
+type setting struct {
+	// key 配置文件中的键名，命令行参数名为把下划线换成连字符的形式
+	key   string
+	env   string
+	usage string
+	// set 校验并写入配置项的值
+	set func(c *AppConfig, v string) error
+	// value 返回配置项的值，格式与配置文件一致
+	value func(c AppConfig) string
+	// display 输出时使用的值，用于隐藏密码等敏感信息，为空时使用 value
+	display func(c AppConfig) string
+	// keep 不为空表示修改后需要重启才能生效，重新加载配置时用它恢复正在使用的值
+	keep func(next *AppConfig, current AppConfig)
+}
+
+// displayValue 返回 config print 输出的值
+func (s setting) displayValue(c AppConfig) string {
+	if s.display != nil {
+		return s.display(c)
+	}
+	return s.value(c)
+}
+
+// settings 所有配置项，顺序即 config print 的输出顺序
+var settings = []setting{
+	{
+		key:   "inactive_days_before_delete",
+		env:   EnvInactiveDaysBeforeDelete,
+		usage: "帖子不活跃多少天后被删除",
+		set: func(c *AppConfig, v string) error {
+			days, err := strconv.Atoi(v)
+			if err != nil || days <= 0 {
+				return errors.New("必须是正整数")
+			}
+			c.InactiveDaysBeforeDelete = days
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Itoa(c.InactiveDaysBeforeDelete) },
+	},
+	{
+		key:   "port",
+		env:   EnvServerPort,
+		usage: "服务器监听端口",
+		set: func(c *AppConfig, v string) error {
+			port, err := strconv.Atoi(v)
+			if err != nil || port <= 0 || port > 65535 {
+				return errors.New("必须是 1-65535 之间的端口号")
+			}
+			c.ServerPort = strconv.Itoa(port)
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.ServerPort) },
+		keep:  func(next *AppConfig, current AppConfig) { next.ServerPort = current.ServerPort },
+	},
+	{
+		key:   "database_dsn",
+		env:   EnvDatabaseDSN,
+		usage: "数据库DSN，postgres:// 开头使用PostgreSQL，否则为SQLite文件路径",
+		set: func(c *AppConfig, v string) error {
+			c.DatabaseDSN = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.DatabaseDSN) },
+		// 不输出DSN中的密码
+		display: func(c AppConfig) string { return strconv.Quote(RedactDSN(c.DatabaseDSN)) },
+		keep:    func(next *AppConfig, current AppConfig) { next.DatabaseDSN = current.DatabaseDSN },
+	},
+	{
+		key:   "timezone",
+		env:   EnvTimezone,
+		usage: "默认显示时区（IANA名称）",
+		set: func(c *AppConfig, v string) error {
+			if _, err := LoadZone(v); err != nil {
+				return err
+			}
+			c.Timezone = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.Timezone) },
+	},
+	{
+		key:   "max_comment_depth",
+		env:   EnvMaxCommentDepth,
+		usage: "评论回复的最大嵌套层级",
+		set: func(c *AppConfig, v string) error {
+			depth, err := strconv.Atoi(v)
+			if err != nil || depth <= 0 {
+				return errors.New("必须是正整数")
+			}
+			c.MaxCommentDepth = depth
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Itoa(c.MaxCommentDepth) },
+	},
+	{
+		key:   "attachment_store",
+		env:   EnvAttachmentStore,
+		usage: "附件存储，s3:// 开头使用S3兼容的对象存储，否则为本地目录",
+		set: func(c *AppConfig, v string) error {
+			c.AttachmentStore = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.AttachmentStore) },
+		// 不输出对象存储的密钥
+		display: func(c AppConfig) string { return strconv.Quote(RedactDSN(c.AttachmentStore)) },
+		keep:    func(next *AppConfig, current AppConfig) { next.AttachmentStore = current.AttachmentStore },
+	},
+	{
+		key:   "max_upload_size",
+		env:   EnvMaxUploadSize,
+		usage: "单个附件的最大字节数",
+		set: func(c *AppConfig, v string) error {
+			size, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || size <= 0 {
+				return errors.New("必须是正整数")
+			}
+			c.MaxUploadSize = size
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.FormatInt(c.MaxUploadSize, 10) },
+	},
+	{
+		key:   "max_attachments",
+		env:   EnvMaxAttachments,
+		usage: "每个帖子或评论最多的附件数量，0 表示不允许上传附件",
+		set: func(c *AppConfig, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return errors.New("必须是非负整数")
+			}
+			c.MaxAttachments = n
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Itoa(c.MaxAttachments) },
+	},
+	{
+		key:   "trusted_proxies",
+		env:   EnvTrustedProxies,
+		usage: "信任的反向代理IP或CIDR，多个用逗号分隔，为空时不信任任何代理",
+		set: func(c *AppConfig, v string) error {
+			var proxies []string
+			for _, p := range strings.Split(v, ",") {
+				p = strings.TrimSpace(p)
+				if p == "" {
+					continue
+				}
+				if !validProxy(p) {
+					return fmt.Errorf("%s 不是有效的IP或CIDR", p)
+				}
+				proxies = append(proxies, p)
+			}
+			c.TrustedProxies = proxies
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(strings.Join(c.TrustedProxies, ",")) },
+		keep:  func(next *AppConfig, current AppConfig) { next.TrustedProxies = current.TrustedProxies },
+	},
+	rateSetting("rate_limit_posts", EnvRateLimitPosts, "每个IP发帖的限速", func(c *AppConfig) *Rate { return &c.RateLimitPosts }),
+	rateSetting("rate_limit_comments", EnvRateLimitComments, "每个IP评论的限速", func(c *AppConfig) *Rate { return &c.RateLimitComments }),
+	rateSetting("rate_limit_uploads", EnvRateLimitUploads, "每个IP上传附件的限速", func(c *AppConfig) *Rate { return &c.RateLimitUploads }),
+	rateSetting("rate_limit_reports", EnvRateLimitReports, "每个IP举报的限速", func(c *AppConfig) *Rate { return &c.RateLimitReports }),
+	difficultySetting("pow_difficulty", EnvPowDifficulty, "发帖和评论的工作量证明基础难度（哈希的前导零位数），0 表示关闭",
+		func(c *AppConfig) *int { return &c.PowDifficulty }),
+	difficultySetting("pow_max_difficulty", EnvPowMaxDifficulty, "发帖频繁时工作量证明难度自动提高的上限",
+		func(c *AppConfig) *int { return &c.PowMaxDifficulty }),
+	{
+		key:   "report_hide_threshold",
+		env:   EnvReportHideThreshold,
+		usage: "帖子或评论被多少个客户端举报后自动隐藏，0 表示不自动隐藏",
+		set: func(c *AppConfig, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return errors.New("必须是非负整数")
+			}
+			c.ReportHideThreshold = n
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Itoa(c.ReportHideThreshold) },
+	},
+	{
+		key:   "filter_rules",
+		env:   EnvFilterRules,
+		usage: "内容过滤规则文件（.toml、.yaml 或 .yml），为空时不过滤",
+		set: func(c *AppConfig, v string) error {
+			if v != "" {
+				if info, err := os.Stat(v); err != nil || info.IsDir() {
+					return errors.New("不是有效的文件")
+				}
+			}
+			c.FilterRules = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.FilterRules) },
+	},
+	{
+		key:   "premoderation",
+		env:   EnvPremoderation,
+		usage: "先审后发的范围：off、posts、comments 或 all",
+		set: func(c *AppConfig, v string) error {
+			switch v {
+			case PremoderationOff, PremoderationPosts, PremoderationComments, PremoderationAll:
+			default:
+				return errors.New("必须是 off、posts、comments 或 all")
+			}
+			c.Premoderation = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.Premoderation) },
+	},
+	{
+		key:   "poster_id_key",
+		env:   EnvPosterIDKey,
+		usage: "计算发帖人ID和举报人标识的密钥，为空时每次启动随机生成，重启后同一客户端在同一帖子中的ID会改变，也可以再次举报已经举报过的内容",
+		set: func(c *AppConfig, v string) error {
+			c.PosterIDKey = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.PosterIDKey) },
+		// 不输出密钥
+		display: func(c AppConfig) string {
+			if c.PosterIDKey == "" {
+				return `""`
+			}
+			return `"xxxxx"`
+		},
+	},
+	{
+		key:   "shutdown_timeout",
+		env:   EnvShutdownTimeout,
+		usage: "关闭服务器时等待请求完成的最长时间，如 30s",
+		set: func(c *AppConfig, v string) error {
+			timeout, err := time.ParseDuration(v)
+			if err != nil || timeout <= 0 {
+				return errors.New("必须是正的时长，如 30s、1m")
+			}
+			c.ShutdownTimeout = timeout
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.ShutdownTimeout.String()) },
+	},
+	{
+		key:   "override_dir",
+		env:   EnvOverrideDir,
+		usage: "覆盖内嵌模板、静态文件和词库的目录",
+		set: func(c *AppConfig, v string) error {
+			if v != "" {
+				if info, err := os.Stat(v); err != nil || !info.IsDir() {
+					return errors.New("不是有效的目录")
+				}
+			}
+			c.OverrideDir = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.OverrideDir) },
+		keep:  func(next *AppConfig, current AppConfig) { next.OverrideDir = current.OverrideDir },
+	},
+	{
+		key:   "admin_token",
+		env:   EnvAdminToken,
+		usage: "管理接口的令牌，与管理密码都为空时禁用管理接口",
+		set: func(c *AppConfig, v string) error {
+			c.AdminToken = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.AdminToken) },
+		// 不输出令牌
+		display: func(c AppConfig) string {
+			if c.AdminToken == "" {
+				return `""`
+			}
+			return `"xxxxx"`
+		},
+	},
+	{
+		key:   "admin_password_hash",
+		env:   EnvAdminPasswordHash,
+		usage: "管理密码的 bcrypt 哈希，用 nilbbs hash-password 生成，为空时只能使用管理令牌登录",
+		set: func(c *AppConfig, v string) error {
+			if v != "" {
+				if _, err := bcrypt.Cost([]byte(v)); err != nil {
+					return errors.New("不是有效的 bcrypt 哈希")
+				}
+			}
+			c.AdminPasswordHash = v
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(c.AdminPasswordHash) },
+		display: func(c AppConfig) string {
+			if c.AdminPasswordHash == "" {
+				return `""`
+			}
+			return `"xxxxx"`
+		},
+	},
+}
+
+// rateSetting 限速配置项，格式为 次数/时长，如 5/1m，0 表示不限速
+func rateSetting(key, env, usage string, field func(c *AppConfig) *Rate) setting {
+	return setting{
+		key:   key,
+		env:   env,
+		usage: usage + "，格式为 次数/时长，如 5/1m，0 表示不限速",
+		set: func(c *AppConfig, v string) error {
+			rate, err := ParseRate(v)
+			if err != nil {
+				return err
+			}
+			*field(c) = rate
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Quote(field(&c).String()) },
+	}
+}
+
+// difficultySetting 工作量证明难度配置项，取值 0-32
+func difficultySetting(key, env, usage string, field func(c *AppConfig) *int) setting {
+	return setting{
+		key:   key,
+		env:   env,
+		usage: usage,
+		set: func(c *AppConfig, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 32 {
+				return errors.New("必须是 0 到 32 之间的整数")
+			}
+			*field(c) = n
+			return nil
+		},
+		value: func(c AppConfig) string { return strconv.Itoa(*field(&c)) },
+	}
+}
+
+// validProxy 判断是否为有效的IP或CIDR
+func validProxy(s string) bool {
+	if strings.Contains(s, "/") {
+		_, err := netip.ParsePrefix(s)
+		return err == nil
+	}
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+func findSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
 		}
 	}
+	return nil
+}
+
+// errUnknownKey 配置文件中出现了未知的配置项
+var errUnknownKey = errors.New("未知的配置项")
+
+// ConfigError 配置项的值无效，包含出错的配置项和值的来源
+type ConfigError struct {
+	Key    string
+	Source string
+	Value  string
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	if errors.Is(e.Err, errUnknownKey) {
+		return fmt.Sprintf("%s 中存在未知的配置项 %s", e.Source, e.Key)
+	}
+	return fmt.Sprintf("配置项 %s 的值 '%s' 无效（来自%s）: %v", e.Key, e.Value, e.Source, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
 }
 
 // SetInactiveDaysBeforeDelete 设置帖子不活跃多少天后会被删除
 func SetInactiveDaysBeforeDelete(days int) {
 	if days > 0 {
-		Config.InactiveDaysBeforeDelete = days
+		updateConfig(func(c *AppConfig) { c.InactiveDaysBeforeDelete = days })
 	}
 }
 
@@ -67,7 +603,7 @@ func SetInactiveDaysBeforeDelete(days int) {
 func SetServerPort(port string) {
 	if port != "" {
 		if _, err := strconv.Atoi(port); err == nil {
-			Config.ServerPort = port
+			updateConfig(func(c *AppConfig) { c.ServerPort = port })
 		}
 	}
-}
\ No newline at end of file
+}
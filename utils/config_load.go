@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置项的优先级从低到高：默认值、配置文件、环境变量、命令行参数
+
+// SourceDefault 配置项未被设置时的来源
+const SourceDefault = "默认值"
+
+// ConfigSources 记录每个配置项的值来自哪里，键为配置项名称
+type ConfigSources map[string]string
+
+// ConfigLoader 按优先级合并各处的配置，可以重复调用 Load 重新读取
+type ConfigLoader struct {
+	// File 配置文件路径，为空时不读取配置文件
+	File string
+	// flags 命令行中显式指定的配置项
+	flags map[string]string
+}
+
+// flagName 配置项对应的命令行参数名
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.key, "_", "-")
+}
+
+// ParseConfigFlags 解析命令行参数，返回配置加载器和剩余的参数（子命令）
+func ParseConfigFlags(args []string) (*ConfigLoader, []string, error) {
+	fs := flag.NewFlagSet("nilbbs", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: nilbbs [参数] [migrate|config] ...\n\n")
+		fs.PrintDefaults()
+	}
+	file := fs.String("config", os.Getenv(EnvConfigFile), "配置文件路径（.toml、.yaml 或 .yml），也可以通过 "+EnvConfigFile+" 指定")
+	for _, s := range settings {
+		fs.String(s.flagName(), "", s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	l := &ConfigLoader{File: *file, flags: make(map[string]string)}
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flagName() == f.Name {
+				l.flags[s.key] = f.Value.String()
+			}
+		}
+	})
+	return l, fs.Args(), nil
+}
+
+// Load 从默认值开始依次应用配置文件、环境变量和命令行参数，遇到无效值时返回 *ConfigError
+func (l *ConfigLoader) Load() (AppConfig, ConfigSources, error) {
+	cfg := DefaultConfig()
+	sources := make(ConfigSources, len(settings))
+	for _, s := range settings {
+		sources[s.key] = SourceDefault
+	}
+
+	apply := func(s *setting, v, source string) error {
+		if err := s.set(&cfg, v); err != nil {
+			return &ConfigError{Key: s.key, Source: source, Value: v, Err: err}
+		}
+		sources[s.key] = source
+		return nil
+	}
+
+	if l.File != "" {
+		values, err := readConfigFile(l.File)
+		if err != nil {
+			return cfg, sources, err
+		}
+		source := "配置文件 " + l.File
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := findSetting(key)
+			if s == nil {
+				return cfg, sources, &ConfigError{Key: key, Source: source, Err: errUnknownKey}
+			}
+			if err := apply(s, values[key], source); err != nil {
+				return cfg, sources, err
+			}
+		}
+	}
+
+	for i := range settings {
+		if v := os.Getenv(settings[i].env); v != "" {
+			if err := apply(&settings[i], v, "环境变量 "+settings[i].env); err != nil {
+				return cfg, sources, err
+			}
+		}
+	}
+
+	for i := range settings {
+		if v, ok := l.flags[settings[i].key]; ok {
+			if err := apply(&settings[i], v, "命令行参数 -"+settings[i].flagName()); err != nil {
+				return cfg, sources, err
+			}
+		}
+	}
+
+	return cfg, sources, nil
+}
+
+// readConfigFile 读取TOML或YAML配置文件，按扩展名判断格式，返回配置项的字符串值
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("不支持的配置文件格式: %s，请使用 .toml、.yaml 或 .yml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, &ConfigError{Key: key, Source: "配置文件 " + path, Value: fmt.Sprint(v), Err: fmt.Errorf("必须是单个值")}
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
+
+// RedactDSN 隐藏数据库DSN中的密码
+func RedactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil {
+		return dsn
+	}
+	return u.Redacted()
+}
+
+// PrintConfig 以配置文件的格式输出生效的配置，并注明每一项的来源
+func PrintConfig(w io.Writer, cfg AppConfig, sources ConfigSources) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, s := range settings {
+		fmt.Fprintf(tw, "%s = %s\t# %s\n", s.key, s.displayValue(cfg), sources[s.key])
+	}
+	return tw.Flush()
+}
+
+// ReloadResult 重新加载配置的结果
+type ReloadResult struct {
+	// Applied 已经生效的配置项
+	Applied []string `json:"applied"`
+	// RestartRequired 已修改但需要重启才能生效的配置项
+	RestartRequired []string `json:"restart_required"`
+}
+
+// ReloadConfig 用重新读取并校验过的配置整体替换当前配置。需要重启的配置项保持原值，
+// 在结果中列出；替换失败时返回错误，当前配置保持不变
+func ReloadConfig(next AppConfig) (ReloadResult, error) {
+	result := ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	current := CurrentConfig()
+	for _, s := range settings {
+		if s.value(next) == s.value(current) {
+			continue
+		}
+		if s.keep != nil {
+			// 恢复原值，重启前保持与正在运行的服务一致
+			s.keep(&next, current)
+			result.RestartRequired = append(result.RestartRequired, s.key)
+		} else {
+			result.Applied = append(result.Applied, s.key)
+		}
+	}
+	return result, setConfigLocked(next)
+}
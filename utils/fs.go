@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// OverlayFS 优先读取 Upper 中的文件，不存在时回退到 Lower
+// 用于让磁盘上的覆盖目录替换内嵌的模板、静态文件和词库
+type OverlayFS struct {
+	Upper fs.FS
+	Lower fs.FS
+}
+
+// WithOverride 在 base 之上叠加磁盘目录 dir，dir 为空时直接返回 base
+func WithOverride(dir string, base fs.FS) fs.FS {
+	if dir == "" {
+		return base
+	}
+	return OverlayFS{Upper: os.DirFS(dir), Lower: base}
+}
+
+// Open 实现 fs.FS
+func (o OverlayFS) Open(name string) (fs.File, error) {
+	f, err := o.Upper.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.Lower.Open(name)
+}
+
+// ReadDir 合并两层目录的内容，同名文件以 Upper 为准
+func (o OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, upperErr := fs.ReadDir(o.Upper, name)
+	if upperErr != nil && !errors.Is(upperErr, fs.ErrNotExist) {
+		return nil, upperErr
+	}
+	lower, lowerErr := fs.ReadDir(o.Lower, name)
+	if lowerErr != nil && !errors.Is(lowerErr, fs.ErrNotExist) {
+		return nil, lowerErr
+	}
+	if upperErr != nil && lowerErr != nil {
+		return nil, lowerErr
+	}
+
+	seen := make(map[string]bool, len(upper))
+	entries := append([]fs.DirEntry(nil), upper...)
+	for _, e := range upper {
+		seen[e.Name()] = true
+	}
+	for _, e := range lower {
+		if !seen[e.Name()] {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"io"
+)
+
+// zoneLogWriter 在每条日志前加上显示时区的时间，配合 log.SetFlags(0) 使用
+type zoneLogWriter struct {
+	w io.Writer
+}
+
+// NewZoneLogWriter 创建按显示时区输出时间的日志写入器
+func NewZoneLogWriter(w io.Writer) io.Writer {
+	return zoneLogWriter{w: w}
+}
+
+func (z zoneLogWriter) Write(p []byte) (int, error) {
+	prefix := NowDisplay().Format("2006/01/02 15:04:05 ")
+	if _, err := io.WriteString(z.w, prefix); err != nil {
+		return 0, err
+	}
+	return z.w.Write(p)
+}
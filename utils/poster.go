@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// PosterID 用密钥对客户端标识、帖子ID和日期（UTC）计算 HMAC，取前6字节编码为8个字符。
+// 同一客户端当天在同一帖子中的ID相同；换一个帖子或过了一天就无法与之前的ID关联，
+// 不知道密钥也无法从ID反推客户端
+func PosterID(key []byte, client string, postID int64, now time.Time) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(client))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(postID, 10)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(now.UTC().Format(time.DateOnly)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// ReporterHash 用密钥对客户端标识和举报的内容计算 HMAC，用于判断同一客户端是否重复举报。
+// 客户端IP的取值范围很小，不带密钥的哈希可以被穷举还原，因此不能使用 HashEditToken
+func ReporterHash(key []byte, client string, postID, commentID int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(client))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(postID, 10)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(commentID, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
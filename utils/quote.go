@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// quotePattern 内容中的引用：>>id 引用评论，>>>id 引用帖子
+var quotePattern = regexp.MustCompile(`(>>>?)(\d+)`)
+
+// MaxQuotes 单条内容最多解析的引用数量，超出的部分按普通文本处理
+const MaxQuotes = 20
+
+// QuoteRef 内容中的一个引用
+type QuoteRef struct {
+	// ID 被引用的评论或帖子的ID
+	ID int64
+	// Post 为 true 表示 >>>id 引用帖子，否则为 >>id 引用评论
+	Post bool
+}
+
+// ParseQuotes 解析内容中的引用，按首次出现的顺序去重
+func ParseQuotes(content string) []QuoteRef {
+	var refs []QuoteRef
+	seen := make(map[QuoteRef]bool)
+	for _, m := range quotePattern.FindAllStringSubmatch(content, -1) {
+		id, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil || id <= 0 {
+			continue
+		}
+		ref := QuoteRef{ID: id, Post: m[1] == ">>>"}
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+		if len(refs) == MaxQuotes {
+			break
+		}
+	}
+	return refs
+}
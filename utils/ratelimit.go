@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate 令牌桶的限速：最多连续请求 Burst 次，每经过 Per 恢复 Burst 次；Burst 为 0 表示不限速
+type Rate struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseRate 解析 "次数/时长" 形式的限速，如 5/1m；空字符串或 0 表示不限速
+func ParseRate(s string) (Rate, error) {
+	if s == "" || s == "0" {
+		return Rate{}, nil
+	}
+	n, per, ok := strings.Cut(s, "/")
+	burst, err := strconv.Atoi(n)
+	if !ok || err != nil || burst <= 0 {
+		return Rate{}, errors.New("格式应为 次数/时长，如 5/1m，0 表示不限速")
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rate{}, errors.New("格式应为 次数/时长，如 5/1m，0 表示不限速")
+	}
+	return Rate{Burst: burst, Per: d}, nil
+}
+
+// String 返回与 ParseRate 相同格式的限速
+func (r Rate) String() string {
+	if r.Burst == 0 {
+		return "0"
+	}
+	per := r.Per.String()
+	switch {
+	case r.Per%time.Hour == 0:
+		per = strconv.FormatInt(int64(r.Per/time.Hour), 10) + "h"
+	case r.Per%time.Minute == 0:
+		per = strconv.FormatInt(int64(r.Per/time.Minute), 10) + "m"
+	}
+	return fmt.Sprintf("%d/%s", r.Burst, per)
+}
+
+// RateLimiter 按键（如路由和客户端IP）分别维护令牌桶。
+// 桶按最近使用的顺序排列，长时间未使用、令牌已经恢复满的桶与新桶没有区别，会被回收；
+// 桶的数量超过上限时回收最久未使用的桶，内存占用不随客户端数量无限增长
+type RateLimiter struct {
+	mu         sync.Mutex
+	buckets    map[string]*list.Element
+	lru        *list.List
+	maxBuckets int
+}
+
+// bucket 一个令牌桶，last 为上次计算令牌的时间
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+	// per 最近一次使用的限速恢复满所需的时间，空闲超过它的桶可以回收
+	per time.Duration
+}
+
+// NewRateLimiter 创建最多保留 maxBuckets 个令牌桶的限速器
+func NewRateLimiter(maxBuckets int) *RateLimiter {
+	return &RateLimiter{
+		buckets:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxBuckets: maxBuckets,
+	}
+}
+
+// Allow 从 key 的令牌桶中取出一个令牌。令牌不足时返回 false 和恢复一个令牌需要等待的时间
+func (l *RateLimiter) Allow(key string, rate Rate, now time.Time) (bool, time.Duration) {
+	if rate.Burst <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	defer l.evict(now)
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*bucket)
+		// 按经过的时间恢复令牌，限速调小后不超过新的上限
+		interval := rate.Per / time.Duration(rate.Burst)
+		b.tokens = min(float64(rate.Burst), b.tokens+float64(now.Sub(b.last))/float64(interval))
+	} else {
+		b = &bucket{key: key, tokens: float64(rate.Burst)}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+	b.last = now
+	b.per = rate.Per
+
+	if b.tokens < 1 {
+		interval := rate.Per / time.Duration(rate.Burst)
+		return false, time.Duration((1 - b.tokens) * float64(interval))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// evict 回收最久未使用的空闲桶，以及超出数量上限的桶，调用方需持有锁
+func (l *RateLimiter) evict(now time.Time) {
+	for e := l.lru.Back(); e != nil; e = l.lru.Back() {
+		b := e.Value.(*bucket)
+		if l.lru.Len() <= l.maxBuckets && now.Sub(b.last) < b.per {
+			return
+		}
+		l.lru.Remove(e)
+		delete(l.buckets, b.key)
+	}
+}
+
+// Len 返回当前保留的令牌桶数量
+func (l *RateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
@@ -1,36 +1,63 @@
 package utils
 
-import "time"
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+	// 内置时区数据库，保证在没有安装tzdata的环境中也能加载IANA时区
+	_ "time/tzdata"
+)
 
-// CSTZone 中国标准时间时区 (UTC+8)
-var CSTZone = time.FixedZone("CST", 8*3600)
+// 数据库中的时间统一以UTC存储，时区只在对外显示时转换
 
-func SetCSTZone(t time.Time) time.Time {
-	return t.In(CSTZone)
+// DefaultTimezone 默认的显示时区
+const DefaultTimezone = "Asia/Shanghai"
+
+// displayZone 显示时间使用的时区，由配置决定，重新加载配置时会被替换
+var displayZone atomic.Pointer[time.Location]
+
+func init() {
+	displayZone.Store(mustLoadZone(DefaultTimezone))
+}
+
+func mustLoadZone(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
 }
 
-// FormatTimeCST 将时间格式化为中国标准时间字符串
-func FormatTimeCST(t time.Time) string {
-	return t.In(CSTZone).Format("2006-01-02 15:04:05")
+// LoadZone 解析IANA时区名称，如 Asia/Shanghai、Europe/Berlin、UTC
+func LoadZone(name string) (*time.Location, error) {
+	// time.LoadLocation 把空字符串当作UTC，这里要求显式指定
+	if name == "" {
+		return nil, errors.New("时区名称不能为空")
+	}
+	return time.LoadLocation(name)
 }
 
-// ParseTimeCST 解析字符串为中国标准时间
-func ParseTimeCST(timeStr string) (time.Time, error) {
-	// 2025-05-06T16:31:34Z
-	t, err := time.Parse(time.RFC3339, timeStr)
-	if err == nil {
-		// 这里不使用 t.In(CSTZone)，而是创建一个新的时间，保持时间值不变，但更改时区
-		// todo 可能是存的时候有点问题
-		return time.Date(
-			t.Year(), t.Month(), t.Day(),
-			t.Hour(), t.Minute(), t.Second(), t.Nanosecond(),
-			CSTZone,
-		), nil
+// SetDisplayZone 设置默认显示时区
+func SetDisplayZone(name string) error {
+	loc, err := LoadZone(name)
+	if err != nil {
+		return err
 	}
-	return time.Time{}, err
+	displayZone.Store(loc)
+	return nil
+}
+
+// DisplayZone 返回默认显示时区
+func DisplayZone() *time.Location {
+	return displayZone.Load()
+}
+
+// NowDisplay 返回默认显示时区的当前时间
+func NowDisplay() time.Time {
+	return time.Now().In(DisplayZone())
 }
 
-// NowCST 返回当前的中国标准时间
-func NowCST() time.Time {
-	return time.Now().In(CSTZone)
+// NowUTC 返回当前的UTC时间，写入存储时使用
+func NowUTC() time.Time {
+	return time.Now().UTC()
 }
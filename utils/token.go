@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewEditToken 生成随机的编辑令牌，返回令牌和它的哈希，只有哈希会被保存
+func NewEditToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashEditToken(token), nil
+}
+
+// HashEditToken 计算编辑令牌的哈希。令牌本身是高熵随机值，使用SHA-256即可
+func HashEditToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}